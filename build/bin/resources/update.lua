@@ -4,6 +4,21 @@ local function is_windows()
 end
 
 
+-- 检测是否为 macOS（Linux 没有隔离属性，跳过相关处理）
+local function is_macos()
+    if is_windows() then
+        return false
+    end
+    local handle = io.popen("uname -s 2>/dev/null")
+    if not handle then
+        return false
+    end
+    local name = handle:read("*l")
+    handle:close()
+    return name == "Darwin"
+end
+
+
 -- 路径分隔符
 local path_sep = is_windows() and '\\' or '/'
 
@@ -16,6 +31,9 @@ local g_config = {
     }
 }
 
+-- Go 宿主注册的原生文件操作模块，旧版本宿主中不存在时回退到系统命令
+local native = rawget(_G, "hotupdater")
+
 -- 全局变量用于存储更新路径
 local g_update_path = nil
 local g_write_log_file = false  -- 控制是否写入日志文件
@@ -30,10 +48,29 @@ local function win_hide_window(cmd)
     return cmd
 end
 
+-- 消息协议版本和序号，宿主支持 JSON 编码时按 JSON 行发送消息
+local PROTOCOL_VERSION = 1
+local g_seq = 0
+
+-- 发送协议消息，旧版本宿主中退回纯文本
+local function emit(msg)
+    if not native then
+        return false
+    end
+    g_seq = g_seq + 1
+    msg.v = PROTOCOL_VERSION
+    msg.seq = g_seq
+    msg.ts = os.time() * 1000
+    log_message(native.json_encode(msg))
+    return true
+end
+
 -- 日志函数
-local function log(message)
+local function log(message, level)
     -- 调用 Go 注册的日志函数
-    log_message(message)
+    if not emit({type = "log", level = level or "info", message = message}) then
+        log_message(message)
+    end
     -- 如果启用了文件日志，则写入文件
     if g_write_log_file and g_update_path then
         local log_path = g_update_path .. path_sep .. "update.log"
@@ -64,6 +101,9 @@ end
 
 -- 检查文件是否存在
 local function check_file_exists(path)
+    if native then
+        return native.exists(path)
+    end
     if is_windows() then
         -- 直接使用 io.open 检查文件
         local file = io.open(path, "rb")
@@ -86,6 +126,13 @@ end
 
 -- 创建目录
 local function mkdir(path)
+    if native then
+        local ok, err = native.mkdir_all(path)
+        if not ok then
+            log(string.format("创建目录失败: %s (%s)", path, tostring(err)))
+        end
+        return ok
+    end
     if is_windows() then
         -- 使用 PowerShell 创建目录
         local cmd = string.format('New-Item -ItemType Directory -Path "%s" -Force', path)
@@ -117,6 +164,21 @@ end
 
 -- 备份文件
 local function backup_files(src, dst_file)
+    if native then
+        local start_time = get_time()
+        log(string.format("正在备份: %s 到 %s", src, dst_file))
+        local ok, err
+        if is_windows() then
+            ok, err = native.copy_file(src, dst_file)
+        else
+            ok, err = native.tar_create(src, dst_file)
+        end
+        log_time(start_time, "备份总耗时")
+        if not ok then
+            log(string.format("备份失败: %s", tostring(err)))
+        end
+        return ok
+    end
     if is_windows() then
         local start_time = get_time()
         log(string.format("正在备份: %s 到 %s", src, dst_file))
@@ -165,6 +227,13 @@ end
 
 -- 复制文件
 local function copy_files(src, dst)
+    if native then
+        local ok, err = native.copy_tree(src, dst)
+        if not ok then
+            log(string.format("复制失败: %s (%s)", src, tostring(err)))
+        end
+        return ok
+    end
     if is_windows() then
         -- 检查源文件
         local check_src_cmd = string.format('Test-Path "%s"', src)
@@ -230,8 +299,12 @@ local function remove_files(path)
         os_execute(taskkill_cmd)
 
         -- 删除文件
-        local cmd = string.format('del /F /Q "%s" >nul 2>&1', path)
-        local success = os_execute(cmd)
+        if native then
+            native.remove_all(path)
+        else
+            local cmd = string.format('del /F /Q "%s" >nul 2>&1', path)
+            os_execute(cmd)
+        end
 
         -- 验证删除结果
         if check_file_exists(path) then
@@ -240,11 +313,29 @@ local function remove_files(path)
         end
 
         return true
+    elseif native then
+        local ok, err = native.remove_all(path)
+        if not ok then
+            log(string.format("删除失败: %s (%s)", path, tostring(err)))
+        end
+        return ok
     else
         return os.execute(string.format('rm -rf "%s"', path))
     end
 end
 
+-- 从 tar.gz 备份恢复到原位置
+local function extract_backup(backup_file)
+    if native then
+        local ok, err = native.tar_extract(backup_file, "/")
+        if not ok then
+            log(string.format("解压备份失败: %s (%s)", backup_file, tostring(err)))
+        end
+        return ok
+    end
+    return os.execute(string.format('tar -xzf "%s" -C "/"', backup_file))
+end
+
 -- 执行命令并返回输出
 local function execute_command(cmd)
     log_message("执行命令: " .. cmd)
@@ -521,6 +612,11 @@ local function remove_quarantine(path)
     return true
 end
 
+-- 批处理中追加更新日志状态的命令，见 journal
+local function journal_batch_line(state)
+    return string.format('>>"%s" echo {"state":"%s"}\n', g_update_path .. path_sep .. "update.journal", state)
+end
+
 -- 创建更新批处理脚本 (仅 Windows)
 local function create_update_batch(src, dst, backup)
     local batch_path = g_update_path .. path_sep .. "update.bat"
@@ -536,7 +632,8 @@ local function create_update_batch(src, dst, backup)
         file:write("    goto wait\n")
         file:write(")\n")
         
-        -- 尝试删除旧文件
+        -- 尝试删除旧文件，之前先记录日志，中断后由应用从备份恢复
+        file:write(journal_batch_line("installing"))
         file:write(string.format('del /f /q "%s"\n', dst))
         
         -- 复制新文件并立即检查结果
@@ -546,11 +643,13 @@ local function create_update_batch(src, dst, backup)
         file:write(string.format('if not exist "%s" (\n', dst))
         -- 从备份恢复
         file:write(string.format('    copy /y "%s" "%s"\n', backup, dst))
+        file:write("    " .. journal_batch_line("committed"))
         file:write(string.format('    start "" "%s"\n', dst))
         file:write('    exit /b 1\n')
         file:write(')\n')
         
         -- 启动新版本
+        file:write(journal_batch_line("committed"))
         file:write(string.format('start "" "%s"\n', dst))
         
         file:close()
@@ -561,7 +660,46 @@ end
 
 -- 发送进度信息
 function send_progress(phase, percentage, detail)
-    log_message(string.format("@PROGRESS@%s|%d|%s", phase, percentage, detail))
+    if not emit({type = "progress", phase = phase, percentage = percentage, detail = detail}) then
+        log_message(string.format("@PROGRESS@%s|%d|%s", phase, percentage, detail))
+    end
+end
+
+-- 查找本地化消息，宿主没有提供 tr 时返回消息 ID
+local tr = rawget(_G, "tr") or function(id) return id end
+
+-- 按消息 ID 发送进度信息，详情按应用的语言（Config.Locale）翻译，前端也可以按 detail_id 自行翻译
+local function progress(phase, percentage, id, ...)
+    local detail = tr(id, ...)
+    if not emit({type = "progress", phase = phase, percentage = percentage, detail = detail, detail_id = id}) then
+        log_message(string.format("@PROGRESS@%s|%d|%s", phase, percentage, detail))
+    end
+end
+
+-- 触发应用的生命周期钩子（Config.Hooks），钩子否决时抛出错误；宿主没有提供时不执行
+local run_hook = rawget(_G, "run_hook") or function() end
+
+-- 发送错误信息
+local function send_error(code, message)
+    if not emit({type = "error", level = "error", code = code, message = message}) then
+        log_message("错误: " .. message)
+    end
+end
+
+-- 安装开始后设置为回滚函数，更新完成后清除
+local pending_rollback = nil
+
+-- 在更新路径中记录更新日志（update.journal），应用下次启动时 hotupdater.Recover 据此完成或回滚被中断的更新
+local function journal(state, fields)
+    if not native or not native.journal or not g_update_path then
+        return
+    end
+    local entry = fields or {}
+    entry.state = state
+    local ok, err = native.journal(g_update_path, entry)
+    if not ok then
+        log("写入更新日志失败: " .. tostring(err))
+    end
 end
 
 -- Windows更新处理函数
@@ -582,12 +720,23 @@ local function perform_windows_update(target_path, new_version, backup_path, bac
 
     if use_gui then
         -- 使用GUI更新助手
-        send_progress("install", 0, "准备安装新版本...")
+        progress("install", 0, "install.prepare")
         
         -- 创建更新信息文件
         local info_file = g_update_path .. path_sep .. "update_info.json"
-        -- 转义路径中的反斜杠
-        local info_str = string.format([[{
+        local info_str
+        if native then
+            info_str = native.json_encode({
+                app_path = target_path,
+                new_version = new_version,
+                backup_path = backup_path,
+                backup_file = backup_file,
+                current_version = current_version,
+                update_version = update_version,
+            }, true)
+        else
+            -- 转义路径中的反斜杠
+            info_str = string.format([[{
     "app_path": "%s",
     "new_version": "%s",
     "backup_path": "%s",
@@ -600,6 +749,7 @@ local function perform_windows_update(target_path, new_version, backup_path, bac
     backup_file:gsub("\\", "\\\\"),
     current_version:gsub("\\", "\\\\"),
     update_version:gsub("\\", "\\\\"))
+        end
 
         -- 写入文件
         local file = io.open(info_file, "w")
@@ -625,20 +775,20 @@ local function perform_windows_update(target_path, new_version, backup_path, bac
             return false
         end
         
-        send_progress("install", 100, "更新助手已启动")
+        progress("install", 100, "install.helper_started")
         log("更新助手已启动，程序即将重启...")
         return true
     else
         -- 使用批处理脚本
-        send_progress("install", 0, "准备安装新版本...")
+        progress("install", 0, "install.prepare")
         
         local batch_start = get_time()
-        send_progress("install", 20, "正在创建更新脚本...")
+        progress("install", 20, "install.script_create")
         local batch_file = create_update_batch(new_version, target_path, backup_file)
         log_time(batch_start, "创建批处理")
         
         -- 启动批处理
-        send_progress("install", 40, "正在准备重启程序...")
+        progress("install", 40, "install.restart_prepare")
         local start_start = get_time()
         local cmd = string.format('powershell -Command "Start-Process -FilePath \'%s\' -WindowStyle Hidden"', batch_file)
         local success = os_execute(cmd)
@@ -649,14 +799,14 @@ local function perform_windows_update(target_path, new_version, backup_path, bac
             return false
         end
         
-        send_progress("install", 80, "更新脚本已启动...")
+        progress("install", 80, "install.script_started")
         log("更新脚本已创建并启动，程序即将重启...")
         
         -- 验证更新脚本
-        send_progress("verify", 0, "正在验证更新脚本...")
+        progress("verify", 0, "verify.script")
         if check_file_exists(batch_file) then
-            send_progress("verify", 100, "验证完成")
-            send_progress("complete", 100, "更新完成，即将重启...")
+            progress("verify", 100, "verify.done")
+            progress("complete", 100, "update.restarting")
         else
             error("更新脚本创建失败")
             return false
@@ -666,7 +816,7 @@ local function perform_windows_update(target_path, new_version, backup_path, bac
 end
 
 -- 修改 perform_update 函数中的 Windows 处理部分
-function perform_update(params)
+local function do_update(params)
     local total_start = get_time()
     -- 获取参数
     local app_path = params.app_path
@@ -681,7 +831,7 @@ function perform_update(params)
     g_update_path = update_path
 
     -- 开始预检查
-    send_progress("precheck", 0, "正在检查更新环境...")
+    progress("precheck", 0, "precheck.start")
     
     log("开始更新...")
     log(string.format("应用路径: %s", app_path))
@@ -692,20 +842,40 @@ function perform_update(params)
     log(string.format("当前版本: %s", current_version))
     log(string.format("更新版本: %s", update_version))
 
-    send_progress("precheck", 50, "正在检查路径...")
+    progress("precheck", 50, "precheck.paths")
 
     -- 根据平台选择操作路径
     local target_path = is_windows() and app_path or app_root
+    -- Linux 单文件更新时只替换可执行文件
+    if not is_windows() and not is_macos() then
+        local is_dir
+        if native then
+            local info = native.stat(new_version)
+            is_dir = info and info.is_dir
+        else
+            is_dir = os.execute(string.format('test -d "%s"', new_version)) == 0
+        end
+        if not is_dir then
+            target_path = app_path
+        end
+    end
 
     -- 如果是 macOS，先处理新版本的隔离属性
-    if not is_windows() then
+    if is_macos() then
         log(string.format("移除新版本的隔离属性: %s", new_version))
         if not remove_quarantine(new_version) then
             error("移除新版本隔离属性失败")
         end
     end
 
-    send_progress("precheck", 100, "环境检查完成")
+    progress("precheck", 100, "precheck.done")
+    journal("begin", {
+        target = target_path,
+        new_version = new_version,
+        current_version = current_version,
+        update_version = update_version,
+    })
+    run_hook("before_backup")
 
     -- 如果没有备份路径，使用应用目录下的 backup 文件夹
     if not backup_path then
@@ -714,7 +884,7 @@ function perform_update(params)
     end
 
     -- 创建备份目录
-    send_progress("backup", 0, "准备备份...")
+    progress("backup", 0, "backup.prepare")
     log(string.format("创建备份目录: %s", backup_path))
     if not mkdir(backup_path) then
         error("创建备份目录失败")
@@ -734,46 +904,82 @@ function perform_update(params)
         backup_file = backup_path .. path_sep .. backup_name .. ".tar.gz"
     end
     
-    send_progress("backup", 30, "正在创建备份...")
+    progress("backup", 30, "backup.create")
     log(string.format("创建备份文件: %s", backup_file))
+    journal("backing_up", {backup_file = backup_file})
     if not backup_files(target_path, backup_file) then
         error("备份失败")
     end
 
-    send_progress("backup", 90, "正在验证备份...")
+    progress("backup", 90, "backup.verify")
     -- 再次验证备份文件
     if not check_file_exists(backup_file) then
         error("备份文件不存在: " .. backup_file)
     end
-    send_progress("backup", 100, "备份完成")
+    journal("backed_up")
+    if native and native.backup_meta then
+        local ok, err = native.backup_meta(backup_file, {
+            version = current_version,
+            target = target_path,
+            update_version = update_version,
+        })
+        if not ok then
+            log("写入备份说明文件失败: " .. tostring(err))
+        end
+    end
+    progress("backup", 100, "backup.done")
+    run_hook("after_backup", backup_file)
 
     -- 执行更新，如果失败则恢复备份
     local function restore_backup()
         log("更新失败，正在恢复备份...")
+        -- 恢复到一半中断时，应用下次启动会再次从备份恢复
+        journal("installing")
         if is_windows() then
             -- Windows 下直接复制回去
-            local cmd = string.format('Copy-Item -Path "%s" -Destination "%s" -Force', backup_file, target_path)
-            if not execute_win_cmd(cmd) then
+            local ok
+            if native then
+                ok = native.copy_file(backup_file, target_path)
+            else
+                local cmd = string.format('Copy-Item -Path "%s" -Destination "%s" -Force', backup_file, target_path)
+                ok = execute_win_cmd(cmd)
+            end
+            if not ok then
                 log("警告: 备份恢复失败，请手动恢复备份文件: " .. backup_file)
+                return ok
             end
+            journal("committed")
+            return ok
         else
             -- macOS 下解压备份
-            local cmd = string.format('tar -xzf "%s" -C "/"', backup_file)
-            if not os.execute(cmd) then
+            if not extract_backup(backup_file) then
                 log("警告: 备份恢复失败，请手动恢复备份文件: " .. backup_file)
+                return false
             end
+            journal("committed")
+            return true
         end
     end
 
+    run_hook("before_install", backup_file)
+
     if is_windows() then
+        -- 新版本在应用退出后由批处理替换，不触发 after_install
         return perform_windows_update(target_path, new_version, backup_path, backup_file, app_root, current_version, update_version)
     else
         -- macOS 平台直接更新
-        send_progress("install", 0, "准备安装新版本...")
+        progress("install", 0, "install.prepare")
+
+        -- 从这里开始修改应用，更新被取消时由 rollback_update 删除新版本并恢复备份
+        pending_rollback = function()
+            remove_files(target_path)
+            return restore_backup()
+        end
         
-        -- 删除旧版本
+        -- 删除旧版本，之前先记录日志，中断后由应用从备份恢复
+        journal("installing")
         log(string.format("删除旧版本: %s", target_path))
-        send_progress("install", 20, "正在删除旧版本...")
+        progress("install", 20, "install.remove_old")
         if not remove_files(target_path) then
             restore_backup()
             error("删除旧版本失败")
@@ -781,51 +987,92 @@ function perform_update(params)
         end
 
         -- 复制新版本
-        send_progress("install", 40, "正在复制新版本...")
+        progress("install", 40, "install.copy")
         log(string.format("复制新版本: %s 到 %s", new_version, target_path))
         if not copy_files(new_version, target_path) then
             restore_backup()
             error("复制新版本失败")
             return false
         end
-        send_progress("install", 80, "复制完成")
+        progress("install", 80, "install.copied")
 
         -- 处理隔离属性
-        send_progress("install", 90, "正在设置权限...")
-        log(string.format("移除更新后的隔离属性: %s", app_root))
-        if not remove_quarantine(app_root) then
+        progress("install", 90, "install.permission")
+        if is_macos() then
+            log(string.format("移除更新后的隔离属性: %s", app_root))
+            if not remove_quarantine(app_root) then
+                restore_backup()
+                error("移除隔离属性失败")
+                return false
+            end
+        end
+        journal("installed")
+        progress("install", 100, "install.done")
+
+        -- 钩子否决时删除新版本并恢复备份
+        local hook_ok, hook_err = pcall(run_hook, "after_install", backup_file)
+        if not hook_ok then
+            pending_rollback = nil
+            remove_files(target_path)
             restore_backup()
-            error("移除隔离属性失败")
-            return false
+            error(hook_err, 0)
         end
-        send_progress("install", 100, "安装完成")
 
         -- 验证安装
-        send_progress("verify", 0, "开始验证...")
+        progress("verify", 0, "verify.start")
         
-        if not is_windows() then
+        if is_macos() then
             -- 验证隔离属性是否已清除
             if check_quarantine(target_path) then
                 log_message("错误: 仍存在隔离属性，准备回滚...")
-                send_progress("verify", 50, "发现问题，准备回滚...")
+                progress("verify", 50, "verify.rollback")
                 
                 -- 删除更新后的文件
+                journal("installing")
                 remove_files(target_path)
                 
                 -- 恢复备份
-                os.execute(string.format('tar -xzf "%s" -C "/"', backup_file))
+                if extract_backup(backup_file) then
+                    journal("committed")
+                end
                 
-                send_progress("verify", 100, "已回滚到备份版本")
+                progress("verify", 100, "verify.rolled_back")
                 error("更新失败: 无法完全移除隔离属性")
                 return false
             end
         end
         
-        send_progress("verify", 100, "验证完成")
+        progress("verify", 100, "verify.done")
+        pending_rollback = nil
+        journal("committed")
         log("更新完成")
-        send_progress("complete", 100, "更新完成")
+        progress("complete", 100, "update.done")
     end
 
     log_time(total_start, "更新总耗时")
     return true
-end 
\ No newline at end of file
+end 
+
+-- 更新入口，失败时先发送错误消息再把错误抛给宿主
+function perform_update(params)
+    local ok, result = pcall(do_update, params)
+    if not ok then
+        send_error("script_failed", tostring(result))
+        error(result, 0)
+    end
+    return result
+end
+
+-- 更新被取消时由宿主调用，恢复安装前的版本，失败时抛出错误
+function rollback_update(params)
+    local rollback = pending_rollback
+    pending_rollback = nil
+    if not rollback then
+        return
+    end
+    log("更新已取消，正在回滚...")
+    if not rollback() then
+        error("回滚失败，请手动恢复备份", 0)
+    end
+    log("已回滚到更新前的状态")
+end
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/562589540/hotupdater/pkg/hotupdater"
+)
+
+// 增量补丁生成工具，供发布流程使用
+//
+//	delta -old app_1.0.4.exe -new app_1.0.5.exe -out app_1.0.4_1.0.5.patch
+//
+// 生成后会在临时目录中应用一次补丁进行自检，并输出写入清单 deltas 所需的大小和 SHA-256。
+func main() {
+	log.SetFlags(0)
+
+	oldPath := flag.String("old", "", "旧版本文件路径")
+	newPath := flag.String("new", "", "新版本文件路径")
+	outPath := flag.String("out", "", "补丁输出路径")
+	flag.Parse()
+
+	if *oldPath == "" || *newPath == "" || *outPath == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	if err := run(*oldPath, *newPath, *outPath); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// run 生成补丁并自检，出错时返回，由 main 在清理临时目录之后退出
+func run(oldPath, newPath, outPath string) error {
+	log.Printf("正在生成补丁: %s -> %s", oldPath, newPath)
+	if err := hotupdater.CreatePatch(oldPath, newPath, outPath); err != nil {
+		return fmt.Errorf("生成补丁失败: %w", err)
+	}
+
+	// 自检：应用补丁并比对结果
+	tmpDir, err := os.MkdirTemp("", "hotupdater-delta")
+	if err != nil {
+		return fmt.Errorf("创建临时目录失败: %w", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	rebuilt := filepath.Join(tmpDir, filepath.Base(newPath))
+	if err := hotupdater.ApplyPatch(oldPath, outPath, rebuilt); err != nil {
+		return fmt.Errorf("补丁自检失败: %w", err)
+	}
+
+	newSum, newSize, err := hotupdater.FileSHA256(newPath)
+	if err != nil {
+		return fmt.Errorf("计算新版本摘要失败: %w", err)
+	}
+	patchSum, patchSize, err := hotupdater.FileSHA256(outPath)
+	if err != nil {
+		return fmt.Errorf("计算补丁摘要失败: %w", err)
+	}
+
+	log.Printf("补丁已生成: %s", outPath)
+	log.Printf("补丁大小: %d 字节 (新版本 %d 字节)", patchSize, newSize)
+	log.Printf("补丁 SHA-256: %s", patchSum)
+	log.Printf("新版本 SHA-256: %s", newSum)
+	return nil
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"os"
+
+	"github.com/562589540/hotupdater/pkg/hotupdater"
+)
+
+// 文件级清单生成工具，供发布流程使用
+//
+//	filemanifest -root build/bin/MyApp.app -version 1.0.5 -base-url https://example.com/releases/1.0.5/MyApp.app -out files.json
+//
+// 生成后将 -root 目录按原有结构上传到 -base-url，并把清单地址写入发布清单的 files_url。
+func main() {
+	log.SetFlags(0)
+
+	root := flag.String("root", "", "新版本安装目录，如 MyApp.app")
+	version := flag.String("version", "", "版本号")
+	baseURL := flag.String("base-url", "", "文件下载地址前缀，为空时与清单位于同一目录")
+	out := flag.String("out", "files.json", "清单输出路径")
+	flag.Parse()
+
+	if *root == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	log.Printf("正在扫描: %s", *root)
+	manifest, err := hotupdater.BuildFileManifest(*root, *version)
+	if err != nil {
+		log.Fatalf("生成文件清单失败: %v", err)
+	}
+	manifest.BaseURL = *baseURL
+
+	if err := hotupdater.WriteFileManifest(manifest, *out); err != nil {
+		log.Fatalf("写入文件清单失败: %v", err)
+	}
+	log.Printf("文件清单已生成: %s (%d 个条目)", *out, len(manifest.Files))
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"runtime"
+
+	"github.com/562589540/hotupdater/pkg/hotupdater"
+)
+
+// 图形界面和命令行共用的备份操作
+
+// loadBackups 读取备份目录，按时间从新到旧排序
+// 有说明文件的备份以其中的版本、时间和原始路径为准，旧备份从文件名解析，恢复位置取自配置。
+func loadBackups() ([]BackupInfo, error) {
+	backups, err := hotupdater.ListBackups(config.BackupPath)
+	if err != nil {
+		return nil, err
+	}
+
+	infos := make([]BackupInfo, 0, len(backups))
+	for _, b := range backups {
+		if b.Meta == nil {
+			log.Printf("备份没有说明文件，按文件名解析: %s", filepath.Base(b.Path))
+		}
+		infos = append(infos, newBackupInfo(b))
+	}
+	return infos, nil
+}
+
+// newBackupInfo 转换为界面使用的备份信息
+func newBackupInfo(b hotupdater.Backup) BackupInfo {
+	info := BackupInfo{
+		Path:            b.Path,
+		Version:         b.Version,
+		BackupTime:      b.Time,
+		OriginalAppPath: b.Target,
+		Size:            b.Size,
+		Meta:            b.Meta,
+	}
+	if info.OriginalAppPath == "" {
+		info.OriginalAppPath = config.AppPath
+	}
+	return info
+}
+
+// backupInfoFromFile 读取指定的备份文件，可以不在备份目录中
+func backupInfoFromFile(path string) (BackupInfo, error) {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return BackupInfo{}, err
+	}
+	stat, err := os.Stat(path)
+	if err != nil {
+		return BackupInfo{}, err
+	}
+
+	b := hotupdater.Backup{Path: path, Size: stat.Size()}
+	b.Version, b.Time, _ = hotupdater.ParseBackupName(filepath.Base(path))
+	meta, err := hotupdater.ReadBackupMeta(path)
+	if err != nil {
+		return BackupInfo{}, fmt.Errorf("读取备份说明文件失败: %v", err)
+	}
+	if meta != nil {
+		b.Meta = meta
+		b.Version, b.Target = meta.Version, meta.Target
+		if !meta.Time.IsZero() {
+			b.Time = meta.Time
+		}
+	}
+	return newBackupInfo(b), nil
+}
+
+// findBackup 按文件路径或版本号查找备份，同一版本有多个备份时取最新的
+func findBackup(backups []BackupInfo, version, file string) (BackupInfo, bool) {
+	if file != "" {
+		abs, _ := filepath.Abs(file)
+		for _, b := range backups {
+			if b.Path == abs || filepath.Base(b.Path) == file {
+				return b, true
+			}
+		}
+		return BackupInfo{}, false
+	}
+	for _, b := range backups {
+		if b.Version == version || "v"+b.Version == version || b.Version == "v"+version {
+			return b, true
+		}
+	}
+	return BackupInfo{}, false
+}
+
+// checkTargetStopped 恢复前确认目标程序没有运行
+func checkTargetStopped(backup BackupInfo) error {
+	if isProcessRunning(filepath.Base(backup.OriginalAppPath)) {
+		return fmt.Errorf("目标程序正在运行，请先关闭")
+	}
+	return nil
+}
+
+// restoreBackup 用备份恢复应用，progress 报告 0-1 的进度和当前步骤
+func restoreBackup(backup BackupInfo, progress func(value float64, text string)) error {
+	log.Printf("开始恢复备份: %s -> %s", backup.Path, backup.OriginalAppPath)
+
+	// 根据平台执行不同的恢复逻辑
+	if runtime.GOOS == "windows" {
+		// Windows: 直接替换 exe 文件
+		progress(0.3, "正在删除旧文件...")
+
+		// 删除目标文件
+		if err := os.Remove(backup.OriginalAppPath); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("删除目标文件失败: %v", err)
+		}
+
+		// 复制备份文件
+		progress(0.6, "正在复制备份文件...")
+		if err := copyFile(backup.Path, backup.OriginalAppPath); err != nil {
+			return fmt.Errorf("复制文件失败: %v", err)
+		}
+
+		// 验证文件
+		progress(0.9, "正在验证文件...")
+		if _, err := os.Stat(backup.OriginalAppPath); err != nil {
+			return fmt.Errorf("验证文件失败: %v", err)
+		}
+	} else {
+		// macOS、Linux: 解压 tar.gz 到根目录
+		progress(0.3, "正在删除旧文件...")
+
+		// 删除目标目录
+		if err := os.RemoveAll(backup.OriginalAppPath); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("删除目标目录失败: %v", err)
+		}
+
+		// 解压备份文件
+		progress(0.6, "正在解压备份文件...")
+		cmd := RunCommand("tar", "-xzf", backup.Path, "-C", "/")
+		if output, err := cmd.CombinedOutput(); err != nil {
+			return fmt.Errorf("解压备份文件失败: %v\n%s", err, string(output))
+		}
+
+		// 验证恢复
+		progress(0.8, "正在验证文件...")
+		if _, err := os.Stat(backup.OriginalAppPath); err != nil {
+			return fmt.Errorf("验证恢复失败: %v", err)
+		}
+
+		// 修复权限
+		progress(0.9, "正在修复权限...")
+		if output, err := RunCommand("chmod", "-R", "755", backup.OriginalAppPath).CombinedOutput(); err != nil {
+			return fmt.Errorf("修复权限失败: %v\n%s", err, string(output))
+		}
+
+		// 修改所有者，staff 组只存在于 macOS
+		if runtime.GOOS == "darwin" {
+			progress(0.95, "正在修改所有者...")
+			if output, err := RunCommand("chown", "-R", os.Getenv("USER")+":staff", backup.OriginalAppPath).CombinedOutput(); err != nil {
+				return fmt.Errorf("修改所有者失败: %v\n%s", err, string(output))
+			}
+		}
+	}
+
+	log.Println("备份恢复成功")
+	return nil
+}
+
+// verifyBackup 校验备份是否完整，由 hotupdater.BackupManager 完成
+// 有说明文件时比较大小和 SHA-256，否则检查 tar.gz 能否完整读取、单文件备份是否为空。
+func verifyBackup(backup BackupInfo) error {
+	m := hotupdater.NewBackupManager(hotupdater.Config{
+		BackupPath: config.BackupPath,
+		UpdatePath: config.UpdatePath,
+	})
+	return m.Verify(hotupdater.Backup{
+		Path:    backup.Path,
+		Version: backup.Version,
+		Time:    backup.BackupTime,
+		Size:    backup.Size,
+		Target:  backup.OriginalAppPath,
+		Meta:    backup.Meta,
+	})
+}
@@ -0,0 +1,383 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/562589540/hotupdater/pkg/hotupdater"
+)
+
+// 命令行退出码
+const (
+	exitOK       = 0 // 成功
+	exitFailed   = 1 // 操作失败
+	exitUsage    = 2 // 参数错误
+	exitNotFound = 3 // 找不到指定的备份
+	exitCorrupt  = 4 // 备份校验失败
+)
+
+const cliUsage = `用法:
+  restore                                   打开图形界面
+  restore list [--json]                     列出备份
+  restore apply --version X | --file F      恢复指定的备份（先校验备份）
+  restore verify [--version X | --file F]   校验全部或指定的备份
+  restore prune [选项]                       按保留策略删除旧备份
+
+prune 选项:
+  --keep-last N        最多保留 N 个备份
+  --max-age D          删除早于 D 的备份，如 720h、30d
+  --max-bytes N        备份总大小上限（字节）
+  --keep-last-good     始终保留最新的已知可用版本
+  --dry-run            只显示将要删除的备份
+
+退出码: 0 成功，1 失败，2 参数错误，3 找不到备份，4 备份校验失败
+`
+
+// runCLI 执行命令行子命令，返回退出码
+func runCLI(args []string) int {
+	switch args[0] {
+	case "list":
+		return cliList(args[1:])
+	case "apply":
+		return cliApply(args[1:])
+	case "verify":
+		return cliVerify(args[1:])
+	case "prune":
+		return cliPrune(args[1:])
+	case "help", "-h", "--help":
+		fmt.Fprint(os.Stdout, cliUsage)
+		return exitOK
+	}
+	fmt.Fprintf(os.Stderr, "未知命令: %s\n\n%s", args[0], cliUsage)
+	return exitUsage
+}
+
+// newFlagSet 创建子命令参数解析器，参数错误时输出用法
+func newFlagSet(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(os.Stderr)
+	fs.Usage = func() { fmt.Fprint(os.Stderr, cliUsage) }
+	return fs
+}
+
+// parseFlags 解析参数，返回非负数时为应直接返回的退出码
+func parseFlags(fs *flag.FlagSet, args []string) int {
+	if err := fs.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return exitOK
+		}
+		return exitUsage
+	}
+	if fs.NArg() > 0 {
+		fmt.Fprintf(os.Stderr, "多余的参数: %s\n", strings.Join(fs.Args(), " "))
+		return exitUsage
+	}
+	return -1
+}
+
+// cliFail 输出错误并返回退出码
+func cliFail(code int, format string, args ...interface{}) int {
+	msg := fmt.Sprintf(format, args...)
+	fmt.Fprintln(os.Stderr, "错误: "+msg)
+	log.Printf("命令行操作失败: %s", msg)
+	return code
+}
+
+// listEntry list --json 输出的一项
+type listEntry struct {
+	Path    string    `json:"path"`
+	Version string    `json:"version"`
+	Time    time.Time `json:"time"`
+	Size    int64     `json:"size"`
+	Target  string    `json:"target"`
+	Format  string    `json:"format,omitempty"`
+	SHA256  string    `json:"sha256,omitempty"`
+	HasMeta bool      `json:"has_meta"`
+}
+
+func cliList(args []string) int {
+	fs := newFlagSet("list")
+	asJSON := fs.Bool("json", false, "以 JSON 输出")
+	if code := parseFlags(fs, args); code >= 0 {
+		return code
+	}
+
+	backups, err := loadBackups()
+	if err != nil {
+		return cliFail(exitFailed, "读取备份目录失败: %v", err)
+	}
+
+	if *asJSON {
+		entries := make([]listEntry, 0, len(backups))
+		for _, b := range backups {
+			e := listEntry{
+				Path:    b.Path,
+				Version: b.Version,
+				Time:    b.BackupTime,
+				Size:    b.Size,
+				Target:  b.OriginalAppPath,
+				HasMeta: b.Meta != nil,
+			}
+			if b.Meta != nil {
+				e.Format, e.SHA256 = b.Meta.Format, b.Meta.SHA256
+			}
+			entries = append(entries, e)
+		}
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(entries); err != nil {
+			return cliFail(exitFailed, "输出失败: %v", err)
+		}
+		return exitOK
+	}
+
+	if len(backups) == 0 {
+		fmt.Printf("备份目录中没有备份: %s\n", config.BackupPath)
+		return exitOK
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "版本号\t备份时间\t大小\t文件名\t恢复位置")
+	for _, b := range backups {
+		version := b.Version
+		if version == "" {
+			version = "-"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", version, b.BackupTime.Format("2006-01-02 15:04:05"),
+			formatSize(b.Size), filepath.Base(b.Path), b.OriginalAppPath)
+	}
+	w.Flush()
+	return exitOK
+}
+
+// selectFlags 注册 --version 和 --file 参数
+func selectFlags(fs *flag.FlagSet) (version, file *string) {
+	return fs.String("version", "", "按版本号选择备份，有多个时取最新的"),
+		fs.String("file", "", "备份文件路径或备份目录中的文件名")
+}
+
+// selectBackup 按 --version 或 --file 选择备份，--file 可以指向备份目录之外的文件
+func selectBackup(backups []BackupInfo, version, file string) (BackupInfo, int) {
+	if b, ok := findBackup(backups, version, file); ok {
+		return b, -1
+	}
+	if file != "" {
+		if b, err := backupInfoFromFile(file); err == nil {
+			return b, -1
+		}
+		return BackupInfo{}, cliFail(exitNotFound, "找不到备份文件: %s", file)
+	}
+	return BackupInfo{}, cliFail(exitNotFound, "找不到版本 %s 的备份", version)
+}
+
+func cliApply(args []string) int {
+	fs := newFlagSet("apply")
+	version, file := selectFlags(fs)
+	if code := parseFlags(fs, args); code >= 0 {
+		return code
+	}
+	if (*version == "") == (*file == "") {
+		fmt.Fprint(os.Stderr, "apply 需要 --version 或 --file 之一\n\n"+cliUsage)
+		return exitUsage
+	}
+
+	backups, err := loadBackups()
+	if err != nil {
+		return cliFail(exitFailed, "读取备份目录失败: %v", err)
+	}
+	backup, code := selectBackup(backups, *version, *file)
+	if code >= 0 {
+		return code
+	}
+
+	fmt.Printf("恢复备份: %s\n", filepath.Base(backup.Path))
+	fmt.Printf("版本号:   %s\n", backup.Version)
+	fmt.Printf("恢复位置: %s\n", backup.OriginalAppPath)
+
+	if err := checkTargetStopped(backup); err != nil {
+		return cliFail(exitFailed, "%v", err)
+	}
+
+	progress := newTermProgress(os.Stderr)
+	progress.update(0.05, "正在校验备份...")
+	if err := verifyBackup(backup); err != nil {
+		progress.done()
+		return cliFail(exitCorrupt, "备份校验失败，未做任何修改: %v", err)
+	}
+	err = restoreBackup(backup, progress.update)
+	if err == nil {
+		progress.update(1, "恢复完成")
+	}
+	progress.done()
+	if err != nil {
+		return cliFail(exitFailed, "恢复失败: %v", err)
+	}
+	fmt.Println("备份恢复成功")
+	return exitOK
+}
+
+func cliVerify(args []string) int {
+	fs := newFlagSet("verify")
+	version, file := selectFlags(fs)
+	if code := parseFlags(fs, args); code >= 0 {
+		return code
+	}
+
+	backups, err := loadBackups()
+	if err != nil {
+		return cliFail(exitFailed, "读取备份目录失败: %v", err)
+	}
+	if *version != "" || *file != "" {
+		backup, code := selectBackup(backups, *version, *file)
+		if code >= 0 {
+			return code
+		}
+		backups = []BackupInfo{backup}
+	}
+
+	failed := 0
+	for i, b := range backups {
+		fmt.Printf("[%d/%d] %s ... ", i+1, len(backups), filepath.Base(b.Path))
+		if err := verifyBackup(b); err != nil {
+			failed++
+			fmt.Printf("损坏: %v\n", err)
+			log.Printf("备份校验失败: %s: %v", b.Path, err)
+			continue
+		}
+		if b.Meta == nil {
+			fmt.Println("正常（没有说明文件，只检查了归档结构）")
+		} else {
+			fmt.Println("正常")
+		}
+	}
+	if failed > 0 {
+		fmt.Fprintf(os.Stderr, "%d 个备份校验失败\n", failed)
+		return exitCorrupt
+	}
+	return exitOK
+}
+
+func cliPrune(args []string) int {
+	fs := newFlagSet("prune")
+	keepLast := fs.Int("keep-last", 0, "最多保留的备份数")
+	maxAge := fs.String("max-age", "", "删除早于此时长的备份，如 720h、30d")
+	maxBytes := fs.Int64("max-bytes", 0, "备份总大小上限（字节）")
+	keepLastGood := fs.Bool("keep-last-good", false, "始终保留最新的已知可用版本")
+	dryRun := fs.Bool("dry-run", false, "只显示将要删除的备份")
+	if code := parseFlags(fs, args); code >= 0 {
+		return code
+	}
+
+	r := hotupdater.BackupRetention{
+		KeepLast:      *keepLast,
+		MaxTotalBytes: *maxBytes,
+		KeepLastGood:  *keepLastGood,
+	}
+	if *maxAge != "" {
+		d, err := parseAge(*maxAge)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "无效的 --max-age: %v\n", err)
+			return exitUsage
+		}
+		r.MaxAge = d
+	}
+	if r.IsZero() {
+		fmt.Fprint(os.Stderr, "prune 需要 --keep-last、--max-age 或 --max-bytes 中至少一项\n\n"+cliUsage)
+		return exitUsage
+	}
+
+	plan, err := hotupdater.PlanPrune(config.BackupPath, config.UpdatePath, r)
+	if err != nil {
+		return cliFail(exitFailed, "读取备份目录失败: %v", err)
+	}
+	if len(plan) == 0 {
+		fmt.Println("没有需要删除的备份")
+		return exitOK
+	}
+	if *dryRun {
+		for _, b := range plan {
+			fmt.Printf("将删除: %s (%s)\n", filepath.Base(b.Path), formatSize(b.Size))
+		}
+		return exitOK
+	}
+
+	removed, err := hotupdater.PruneBackups(config.BackupPath, config.UpdatePath, r)
+	var freed int64
+	for _, b := range removed {
+		freed += b.Size
+		fmt.Printf("已删除: %s (%s)\n", filepath.Base(b.Path), formatSize(b.Size))
+		log.Printf("已删除备份: %s", b.Path)
+	}
+	fmt.Printf("共删除 %d 个备份，释放 %s\n", len(removed), formatSize(freed))
+	if err != nil {
+		return cliFail(exitFailed, "部分备份删除失败: %v", err)
+	}
+	return exitOK
+}
+
+// parseAge 解析时长，除 time.ParseDuration 支持的格式外还支持以 d 结尾的天数
+func parseAge(s string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil {
+			return 0, err
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	return time.ParseDuration(s)
+}
+
+// formatSize 以易读的单位显示大小
+func formatSize(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+	div, exp := int64(unit), 0
+	for n := size / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(size)/float64(div), "KMGTPE"[exp])
+}
+
+// termProgress 在终端显示恢复进度，输出不是终端时（如重定向到文件）逐行输出
+type termProgress struct {
+	w   io.Writer
+	tty bool
+}
+
+func newTermProgress(f *os.File) *termProgress {
+	stat, err := f.Stat()
+	return &termProgress{w: f, tty: err == nil && stat.Mode()&os.ModeCharDevice != 0}
+}
+
+func (p *termProgress) update(value float64, text string) {
+	const width = 30
+	percent := int(value * 100)
+	if !p.tty {
+		fmt.Fprintf(p.w, "%3d%% %s\n", percent, text)
+		return
+	}
+	filled := int(value * width)
+	if filled > width {
+		filled = width
+	}
+	bar := strings.Repeat("#", filled) + strings.Repeat("-", width-filled)
+	fmt.Fprintf(p.w, "\r[%s] %3d%% %-30s", bar, percent, text)
+}
+
+// done 结束进度行
+func (p *termProgress) done() {
+	if p.tty {
+		fmt.Fprintln(p.w)
+	}
+}
@@ -9,7 +9,6 @@ import (
 	"os/exec"
 	"path/filepath"
 	"runtime"
-	"sort"
 	"strings"
 	"time"
 
@@ -20,15 +19,18 @@ import (
 	"fyne.io/fyne/v2/layout"
 	"fyne.io/fyne/v2/theme"
 	"fyne.io/fyne/v2/widget"
+	"github.com/562589540/hotupdater/pkg/hotupdater"
 	"github.com/flopp/go-findfont"
 )
 
 // BackupInfo 备份信息结构
 type BackupInfo struct {
-	Path            string    // 备份文件路径
-	Version         string    // 版本号
-	BackupTime      time.Time // 备份时间
-	OriginalAppPath string    // 原应用路径
+	Path            string                 // 备份文件路径
+	Version         string                 // 版本号
+	BackupTime      time.Time              // 备份时间
+	OriginalAppPath string                 // 原应用路径
+	Size            int64                  // 文件大小（字节）
+	Meta            *hotupdater.BackupMeta // 备份说明文件，旧备份没有时为 nil
 }
 
 // Config 配置结构
@@ -36,13 +38,9 @@ type Config struct {
 	AppPath     string `json:"app_path"`     // 应用程序路径
 	BackupPath  string `json:"backup_path"`  // 备份目录路径
 	CurrentPath string `json:"current_path"` // 当前程序路径
+	UpdatePath  string `json:"update_path"`  // 应用的更新目录，可选，prune 从中读取失败版本和等待确认的更新
 }
 
-const (
-	backupPrefix = "backup_"         // 备份文件前缀
-	timeFormat   = "20060102_150405" // 时间格式
-)
-
 var (
 	logFile     *os.File
 	mainWindow  fyne.Window
@@ -218,6 +216,11 @@ func main() {
 
 	log.Println("恢复助手启动...")
 
+	// 带子命令时以命令行方式运行，不需要图形界面
+	if len(os.Args) > 1 {
+		os.Exit(runCLI(os.Args[1:]))
+	}
+
 	// 检查权限
 	if !checkAdminPrivileges() {
 		log.Println("需要管理员权限，正在请求...")
@@ -379,38 +382,12 @@ func createMainUI() fyne.CanvasObject {
 
 // 刷新备份列表
 func refreshBackupList() {
-	backupInfos = []BackupInfo{}
-
-	// 读取备份目录
-	files, err := os.ReadDir(config.BackupPath)
+	backups, err := loadBackups()
 	if err != nil {
 		dialog.ShowError(fmt.Errorf("读取备份目录失败: %v", err), mainWindow)
 		return
 	}
-
-	// 解析备份文件
-	for _, file := range files {
-		if file.IsDir() {
-			continue
-		}
-
-		name := file.Name()
-		if !strings.HasPrefix(name, backupPrefix) {
-			continue
-		}
-
-		path := filepath.Join(config.BackupPath, name)
-		info := parseBackupName(name, path)
-		if info != nil {
-			info.OriginalAppPath = config.AppPath
-			backupInfos = append(backupInfos, *info)
-		}
-	}
-
-	// 按时间顺序
-	sort.Slice(backupInfos, func(i, j int) bool {
-		return backupInfos[i].BackupTime.After(backupInfos[j].BackupTime)
-	})
+	backupInfos = backups
 
 	// 刷新界面
 	if mainWindow != nil && mainWindow.Content() != nil {
@@ -418,57 +395,6 @@ func refreshBackupList() {
 	}
 }
 
-// 解析备份文件名
-func parseBackupName(name, path string) *BackupInfo {
-	// 移除扩展名
-	baseName := strings.TrimSuffix(name, filepath.Ext(name))
-	// 如果是 .tar.gz，需要再次移除 .tar
-	if strings.HasSuffix(baseName, ".tar") {
-		baseName = strings.TrimSuffix(baseName, ".tar")
-	}
-
-	// 检查前缀
-	if !strings.HasPrefix(baseName, backupPrefix) {
-		log.Printf("不是备份文件: %s", name)
-		return nil
-	}
-
-	// 移除前缀
-	nameWithoutPrefix := strings.TrimPrefix(baseName, backupPrefix)
-
-	// 分割版本号和时间
-	parts := strings.Split(nameWithoutPrefix, "_")
-	if len(parts) < 3 {
-		log.Printf("无效的备份文件名格式: %s", name)
-		return nil
-	}
-
-	// 获取版本号（第一个分）
-	version := parts[0]
-	if version == "" {
-		log.Printf("警告: 备份文件没有版本号: %s", name)
-	}
-
-	// 获取时间部分（最后两个部分）
-	timeStr := parts[len(parts)-2] + "_" + parts[len(parts)-1]
-	backupTime, err := time.ParseInLocation(timeFormat, timeStr, time.Local)
-	if err != nil {
-		log.Printf("解析备份时间失败: %v, 文件: %s, 时间字符串: %s", err, name, timeStr)
-		return nil
-	}
-
-	log.Printf("成功解析备份文件: 本=%s, 时间=%s, 文件=%s",
-		version,
-		backupTime.Format("2006-01-02 15:04:05"),
-		filepath.Base(path))
-
-	return &BackupInfo{
-		Path:       path,
-		Version:    version,
-		BackupTime: backupTime,
-	}
-}
-
 // 确认恢复
 func confirmRestore(backup BackupInfo) {
 	// 确认对话框
@@ -486,11 +412,9 @@ func confirmRestore(backup BackupInfo) {
 
 // 执行恢复
 func performRestore(backup BackupInfo) {
-	log.Printf("开始恢复备份: %s -> %s", backup.Path, backup.OriginalAppPath)
-
 	// 检查进程
-	if isProcessRunning(filepath.Base(backup.OriginalAppPath)) {
-		dialog.ShowError(fmt.Errorf("目标程正在运行，请先关闭"), mainWindow)
+	if err := checkTargetStopped(backup); err != nil {
+		dialog.ShowError(err, mainWindow)
 		return
 	}
 
@@ -593,72 +517,7 @@ func performRestore(backup BackupInfo) {
 			}
 		}()
 
-		// 根据平台执行不同的恢复逻辑
-		if runtime.GOOS == "windows" {
-			// Windows: 直接替换 exe 文件
-			updateProgress(0.3, "正在删除旧文件...")
-
-			// 删除目标文件
-			if err := os.Remove(backup.OriginalAppPath); err != nil && !os.IsNotExist(err) {
-				restoreErr = fmt.Errorf("删除目标文件失败: %v", err)
-				return
-			}
-
-			// 复制备份文件
-			updateProgress(0.6, "正在复制备份文件...")
-			if err := copyFile(backup.Path, backup.OriginalAppPath); err != nil {
-				restoreErr = fmt.Errorf("复制文件失败: %v", err)
-				return
-			}
-
-			// 验证文件
-			updateProgress(0.9, "正在验证文件...")
-			if _, err := os.Stat(backup.OriginalAppPath); err != nil {
-				restoreErr = fmt.Errorf("验证文件失败: %v", err)
-				return
-			}
-
-		} else {
-			// macOS: 解压 tar.gz 到根目录
-			updateProgress(0.3, "正在删除旧文件...")
-
-			// 删除目标目录
-			if err := os.RemoveAll(backup.OriginalAppPath); err != nil && !os.IsNotExist(err) {
-				restoreErr = fmt.Errorf("删除目标目录失败: %v", err)
-				return
-			}
-
-			// 解压备份文件
-			updateProgress(0.6, "正在解压备份文件...")
-			cmd := RunCommand("tar", "-xzf", backup.Path, "-C", "/")
-			if output, err := cmd.CombinedOutput(); err != nil {
-				restoreErr = fmt.Errorf("解压备份文件失败: %v\n%s", err, string(output))
-				return
-			}
-
-			// 验证恢复
-			updateProgress(0.8, "正在验证文件...")
-			if _, err := os.Stat(backup.OriginalAppPath); err != nil {
-				restoreErr = fmt.Errorf("验证恢复失败: %v", err)
-				return
-			}
-
-			// 修复权限
-			updateProgress(0.9, "正在修复权限...")
-			if output, err := RunCommand("chmod", "-R", "755", backup.OriginalAppPath).CombinedOutput(); err != nil {
-				restoreErr = fmt.Errorf("修复权限失败: %v\n%s", err, string(output))
-				return
-			}
-
-			// 修改所有者
-			updateProgress(0.95, "正在修改所有者...")
-			if output, err := RunCommand("chown", "-R", os.Getenv("USER")+":staff", backup.OriginalAppPath).CombinedOutput(); err != nil {
-				restoreErr = fmt.Errorf("修改所有者失败: %v\n%s", err, string(output))
-				return
-			}
-		}
-
-		log.Println("备份恢复成功")
+		restoreErr = restoreBackup(backup, updateProgress)
 	}()
 }
 
@@ -4,25 +4,31 @@
 package main
 
 import (
-	"bufio"
 	"context"
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"log"
 	"os"
 	"os/exec"
+	"os/signal"
 	"path/filepath"
 	"strings"
 	"syscall"
 	"time"
 
+	"github.com/562589540/hotupdater/pkg/hotupdater"
 	lua "github.com/yuin/gopher-lua"
 )
 
+// messages 按消息协议向标准输出（提权后为命名管道）写出日志、进度和错误
+var messages = hotupdater.NewMessageWriter(os.Stdout)
+
 func init() {
-	// 设置日志格式，不包含时间戳
+	// 设置日志格式，不包含时间戳，时间由消息协议携带
 	log.SetFlags(0) // 移除所有标志，包括时间戳
+	log.SetOutput(messages.LogWriter())
 }
 
 type UpdateInfo struct {
@@ -34,12 +40,19 @@ type UpdateInfo struct {
 	UpdatePath     string `json:"update_path"`
 	CurrentVersion string `json:"current_version"`
 	UpdateVersion  string `json:"update_version"`
+
+	LuaSandbox *hotupdater.LuaSandbox `json:"lua_sandbox,omitempty"` // 非空时在沙箱中执行脚本
+
+	Locale   string             `json:"locale"`   // 脚本 tr 使用的语言
+	Messages hotupdater.Catalog `json:"messages"` // 应用传入的当前语言消息
 }
 
 func main() {
 	log.Printf("更新助手启动，进程ID: %d", os.Getpid())
 	updateFile := flag.String("update", "", "更新信息文件路径")
 	pipePath := flag.String("pipe", "", "命名管道路径")
+	restoreFile := flag.String("restore", "", "要恢复的备份文件路径，安装后钩子否决更新时由应用调用")
+	restoreTarget := flag.String("target", "", "备份恢复到的路径")
 	flag.Parse()
 
 	if *updateFile == "" {
@@ -47,36 +60,47 @@ func main() {
 		os.Exit(1)
 	}
 
-	// 创建根 context 用于管理所有协程
-	ctx, cancel := context.WithCancel(context.Background())
+	// 创建根 context 用于管理所有协程，应用取消更新时会发送 SIGTERM
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGTERM, syscall.SIGINT)
 	defer cancel() // 确保在主函数退出时取消所有协程
 
 	// 添加自检测机制
 	if *pipePath != "" {
-		// 这是提权后的进程
+		// 这是提权后的进程，无法直接收到应用的信号，通过取消标记文件得知取消
 		go func() {
-			// 每30秒检查一次父进程
-			ticker := time.NewTicker(30 * time.Second)
+			// 每秒检查一次取消标记，每30秒检查一次父进程
+			ticker := time.NewTicker(1 * time.Second)
 			defer ticker.Stop()
 
 			ppid := os.Getppid()
+			lastCheck := time.Now()
 			for {
 				select {
 				case <-ctx.Done():
 					return
 				case <-ticker.C:
+					if _, err := os.Stat(cancelMarker(*updateFile)); err == nil {
+						log.Printf("更新已被取消，正在停止更新脚本")
+						cancel()
+						return
+					}
+					if time.Since(lastCheck) < 30*time.Second {
+						continue
+					}
+					lastCheck = time.Now()
+
 					// 检查父进程是否还存在
 					if _, err := os.FindProcess(ppid); err != nil {
 						log.Printf("父进程已退出，更新助手即将退出")
-						cancel() // 取消所有协程
-						os.Exit(1)
+						cancel() // 取消所有协程，脚本会回滚后退出
+						return
 					}
 
 					// 检查更新是否已完成
 					if _, err := os.Stat(*updateFile); err != nil {
 						log.Printf("更新文件已不存在，更新助手即将退出")
 						cancel() // 取消所有协程
-						os.Exit(0)
+						return
 					}
 				}
 			}
@@ -90,11 +114,22 @@ func main() {
 		defer pipe.Close()
 		// 重定向标准输出到管道
 		os.Stdout = pipe
+		messages = hotupdater.NewMessageWriter(pipe)
+		log.SetOutput(messages.LogWriter())
+	}
+
+	if *restoreFile != "" {
+		if err := runRestore(ctx, *updateFile, *restoreFile, *restoreTarget); err != nil {
+			messages.Error(hotupdater.ErrorCodeRollbackFailed, fmt.Sprintf("恢复备份失败: %v", err))
+			os.Exit(1)
+		}
+		return
 	}
 
 	// 运行更新，传入 context
 	if err := runUpdate(ctx, *updateFile); err != nil {
-		log.Printf("更新失败: %v\n", err)
+		// 错误码告诉应用是否已取消以及回滚结果
+		messages.Error(hotupdater.ErrorCode(err), fmt.Sprintf("更新失败: %v", err))
 		os.Exit(1)
 	}
 }
@@ -109,25 +144,31 @@ func runUpdate(ctx context.Context, updateFile string) error {
 	// 请求提升权限
 	if os.Geteuid() != 0 {
 		log.Println("请求管理员权限...")
-		return requestPrivileges(updateFile)
+		return requestPrivileges(ctx, updateFile)
 	}
 
 	log.Printf("当前进程已获得管理员权限")
 	log.Printf("等待原应用退出...")
-	time.Sleep(2 * time.Second)
 
 	// 在执行更新脚本前检查 context 是否已取消
 	select {
 	case <-ctx.Done():
-		return ctx.Err()
-	default:
+		return cancelled(ctx)
+	case <-time.After(2 * time.Second):
 		// 继续执行
 	}
 
+	// 上次更新被中断时先完成或回滚，脚本开始后会重新记录更新日志
+	if r, err := hotupdater.Recover(hotupdater.Config{UpdatePath: info.UpdatePath}); err != nil {
+		log.Printf("恢复中断的更新失败: %v", err)
+	} else if r.Action != hotupdater.RecoveryNone {
+		log.Printf("已处理中断的更新: %s", r.Action)
+	}
+
 	// 执行 Lua 更新脚本
 	log.Printf("开始执行更新脚本...")
 	if err := executeLuaScript(ctx, info); err != nil {
-		return fmt.Errorf("执行更新脚本失败: %v", err)
+		return fmt.Errorf("执行更新脚本失败: %w", err)
 	}
 
 	// 设置权限
@@ -140,32 +181,77 @@ func runUpdate(ctx context.Context, updateFile string) error {
 	return nil
 }
 
+// runRestore 以管理员权限从备份恢复旧版本，用于应用的安装后钩子否决已完成的更新
+// 恢复不响应取消，中途停止会留下不完整的应用。
+func runRestore(ctx context.Context, updateFile, backup, target string) error {
+	log.Printf("读取更新信息文件: %s", updateFile)
+	info, err := readUpdateInfo(updateFile)
+	if err != nil {
+		return err
+	}
+
+	if os.Geteuid() != 0 {
+		log.Println("请求管理员权限...")
+		return requestPrivileges(context.WithoutCancel(ctx), updateFile, "--restore", backup, "--target", target)
+	}
+
+	if len(info.Messages) > 0 {
+		hotupdater.RegisterCatalog(info.Locale, info.Messages)
+	}
+	m := hotupdater.NewBackupManager(hotupdater.Config{
+		BackupPath: info.BackupPath,
+		UpdatePath: info.UpdatePath,
+		Locale:     info.Locale,
+		Logger:     stdLogger{},
+	})
+	if err := m.Restore(hotupdater.Backup{Path: backup, Target: target}); err != nil {
+		return err
+	}
+
+	if strings.HasSuffix(target, ".app") {
+		if err := setPermissions(target); err != nil {
+			log.Printf("设置权限失败: %v", err)
+		}
+	}
+	log.Printf("已从备份恢复: %s", target)
+	return nil
+}
+
+// stdLogger 把 hotupdater 的日志写到 log，经消息协议转发给应用
+type stdLogger struct{}
+
+func (stdLogger) Log(message string) {
+	log.Print(message)
+}
+
+func (stdLogger) Logf(format string, args ...interface{}) {
+	log.Printf(format, args...)
+}
+
 func executeLuaScript(ctx context.Context, info *UpdateInfo) error {
 	log.Printf("验证更新信息...")
 	// 获取真实的应用路径
 	appRoot := getAppRoot(info.AppPath)
 	if _, err := os.Stat(appRoot); err != nil {
-		return fmt.Errorf("当前应用路径无效: %v", err)
+		return fmt.Errorf("当前应用路径无效: %w", err)
 	}
 	if _, err := os.Stat(info.NewVersion); err != nil {
-		return fmt.Errorf("新版本路径无效: %v", err)
+		return fmt.Errorf("新版本路径无效: %w", err)
 	}
 
 	// 使用传入的脚本路径
 	scriptPath := info.ScriptPath
 	log.Printf("更新脚本路径: %s", scriptPath)
 	if _, err := os.Stat(scriptPath); err != nil {
-		return fmt.Errorf("更新脚本不存在: %v", err)
+		return fmt.Errorf("更新脚本不存在: %w", err)
 	}
 
 	log.Printf("初始化 Lua 环境...")
-	L := lua.NewState()
-	defer L.Close()
-
-	log.Printf("执行更新脚本: %s", scriptPath)
-	if err := L.DoFile(scriptPath); err != nil {
-		return fmt.Errorf("加载脚本失败: %v", err)
+	if info.LuaSandbox != nil {
+		log.Printf("启用 Lua 沙箱")
 	}
+	L := hotupdater.NewLuaState(info.LuaSandbox)
+	defer L.Close()
 
 	// 构建参数
 	params := map[string]string{
@@ -176,63 +262,68 @@ func executeLuaScript(ctx context.Context, info *UpdateInfo) error {
 		"app_root":        appRoot,
 		"current_version": info.CurrentVersion,
 		"update_version":  info.UpdateVersion,
+		"locale":          info.Locale,
 	}
 
-	log.Printf("调用更新函数...")
-	// 创建参数表
-	paramsTable := L.NewTable()
-	for k, v := range params {
-		L.SetField(paramsTable, k, lua.LString(v))
-	}
-
-	// 注册日志函数，直接输出到标准输出
+	// 注册日志函数，按消息协议输出到标准输出，会被 mac_updater 捕获
 	L.SetGlobal("log_message", L.NewFunction(func(L *lua.LState) int {
-		msg := L.ToString(1)
-		fmt.Printf("%s\n", msg) // 输出到标准输出，会被 mac_updater 捕获
+		messages.Forward(L.ToString(1))
 		return 0
 	}))
+	L.SetGlobal("declare_phase", L.NewFunction(func(L *lua.LState) int {
+		messages.DeclarePhase(hotupdater.UpdatePhase(L.CheckString(1)), L.CheckInt(2), L.OptString(3, ""), hotupdater.UpdatePhase(L.OptString(4, "")))
+		return 0
+	}))
+	if len(info.Messages) > 0 {
+		hotupdater.RegisterCatalog(info.Locale, info.Messages)
+	}
+	hotupdater.RegisterTranslator(L, hotupdater.NewLocalizer(info.Locale))
 
-	// 在执行 Lua 脚本期间定期检查 context
-	go func() {
-		ticker := time.NewTicker(1 * time.Second)
-		defer ticker.Stop()
+	if err := info.LuaSandbox.Install(L, params); err != nil {
+		return fmt.Errorf("初始化 Lua 沙箱失败: %w", err)
+	}
+	if err := hotupdater.OpenLuaModule(L, info.LuaSandbox, params); err != nil {
+		return fmt.Errorf("注册原生模块失败: %w", err)
+	}
 
-		for {
-			select {
-			case <-ctx.Done():
-				L.Close() // 强制关闭 Lua 状态
-				return
-			case <-ticker.C:
-				// 继续检查
-			}
-		}
-	}()
+	// 通过 LState 的 context 控制超时和取消，取消时调用脚本的 rollback_update 回滚
+	log.Printf("执行更新脚本: %s", scriptPath)
+	err := hotupdater.RunLuaUpdate(ctx, L, info.LuaSandbox, scriptPath, params)
+	if errors.Is(err, hotupdater.ErrRolledBack) {
+		log.Printf("更新已取消，已回滚到更新前的状态")
+	}
+	return err
+}
 
-	return L.CallByParam(lua.P{
-		Fn:      L.GetGlobal("perform_update"),
-		NRet:    0,
-		Protect: true,
-	}, paramsTable)
+// cancelled 返回取消错误，此时尚未修改应用
+func cancelled(ctx context.Context) error {
+	return &hotupdater.UpdateError{Kind: hotupdater.ErrCancelled, Phase: hotupdater.PhasePreCheck, Err: ctx.Err()}
+}
+
+// cancelMarker 返回取消标记文件的路径，普通权限进程创建它来通知提权后的进程取消
+func cancelMarker(updateFile string) string {
+	return filepath.Join(filepath.Dir(updateFile), "update.cancel")
 }
 
 func readUpdateInfo(path string) (*UpdateInfo, error) {
 	data, err := os.ReadFile(path)
 	if err != nil {
-		return nil, fmt.Errorf("读取更新信息失败: %v", err)
+		return nil, fmt.Errorf("读取更新信息失败: %w", err)
 	}
 
 	var info UpdateInfo
 	if err := json.Unmarshal(data, &info); err != nil {
-		return nil, fmt.Errorf("解析更新信息失败: %v", err)
+		return nil, fmt.Errorf("解析更新信息失败: %w", err)
 	}
 
 	return &info, nil
 }
 
-func requestPrivileges(updateFile string) error {
+// requestPrivileges 通过 osascript 以管理员权限重新启动助手，args 为追加的参数，输出经命名管道转发
+func requestPrivileges(ctx context.Context, updateFile string, args ...string) error {
 	exe, err := os.Executable()
 	if err != nil {
-		return fmt.Errorf("获取可执行文件路径失败: %v", err)
+		return fmt.Errorf("获取可执行文件路径失败: %w", err)
 	}
 
 	// 创建命名管道前先检查并清理
@@ -247,7 +338,7 @@ func requestPrivileges(updateFile string) error {
 
 	// 创建命名管道
 	if err := syscall.Mkfifo(pipePath, 0666); err != nil {
-		return fmt.Errorf("创建命名管道失败: %v", err)
+		return fmt.Errorf("创建命名管道失败: %w", err)
 	}
 	defer os.Remove(pipePath)
 
@@ -267,7 +358,7 @@ func requestPrivileges(updateFile string) error {
 		ticker := time.NewTicker(30 * time.Second)
 		defer ticker.Stop()
 
-		scanner := bufio.NewScanner(pipe)
+		scanner := hotupdater.NewMessageScanner(pipe)
 		for {
 			select {
 			case <-pipeCtx.Done():
@@ -282,18 +373,45 @@ func requestPrivileges(updateFile string) error {
 					}
 					return
 				}
-				fmt.Println(scanner.Text())
+				// 提权进程的消息重新编号后转发给应用
+				messages.Forward(scanner.Text())
 				ticker.Reset(30 * time.Second) // 重置定时器
 			}
 		}
 	}()
 
-	script := fmt.Sprintf(
-		`do shell script "'%s' --update '%s' --pipe '%s'" with administrator privileges`,
-		exe, updateFile, pipePath)
+	// 清理上次遗留的取消标记，取消时创建标记并等待提权进程回滚退出
+	marker := cancelMarker(updateFile)
+	os.Remove(marker)
+	defer os.Remove(marker)
+
+	command := fmt.Sprintf(`'%s' --update '%s' --pipe '%s'`, exe, updateFile, pipePath)
+	for _, arg := range args {
+		command += fmt.Sprintf(` '%s'`, arg)
+	}
+	script := fmt.Sprintf(`do shell script "%s" with administrator privileges`, command)
 
 	cmd := exec.Command("osascript", "-e", script)
-	return cmd.Run()
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("请求管理员权限失败: %w", err)
+	}
+
+	waitDone := make(chan error, 1)
+	go func() {
+		waitDone <- cmd.Wait()
+	}()
+
+	select {
+	case err := <-waitDone:
+		return err
+	case <-ctx.Done():
+		log.Printf("更新已取消，通知提权进程回滚...")
+		if err := os.WriteFile(marker, nil, 0644); err != nil {
+			log.Printf("创建取消标记失败: %v", err)
+		}
+		<-waitDone
+		return cancelled(ctx)
+	}
 }
 
 // 将权限设置抽取为单独的函数
@@ -301,20 +419,20 @@ func setPermissions(appRoot string) error {
 	log.Printf("设置应用权限...")
 	// 设置整个应用包的权限
 	if err := os.Chmod(appRoot, 0755); err != nil {
-		return fmt.Errorf("设置应用权限失败: %v", err)
+		return fmt.Errorf("设置应用权限失败: %w", err)
 	}
 
 	// 特别处理 MacOS 目录
 	macosPath := filepath.Join(appRoot, "Contents", "MacOS")
 	files, err := os.ReadDir(macosPath)
 	if err != nil {
-		return fmt.Errorf("读取 MacOS 目录失败: %v", err)
+		return fmt.Errorf("读取 MacOS 目录失败: %w", err)
 	}
 
 	for _, file := range files {
 		fullPath := filepath.Join(macosPath, file.Name())
 		if err := os.Chmod(fullPath, 0755); err != nil {
-			return fmt.Errorf("设置执行权限失败 %s: %v", fullPath, err)
+			return fmt.Errorf("设置执行权限失败 %s: %w", fullPath, err)
 		}
 	}
 
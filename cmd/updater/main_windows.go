@@ -4,6 +4,7 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"flag"
 	"fmt"
@@ -16,6 +17,7 @@ import (
 	"time"
 	"unsafe"
 
+	"github.com/562589540/hotupdater/pkg/hotupdater"
 	"github.com/lxn/walk"
 	. "github.com/lxn/walk/declarative"
 	"github.com/lxn/win"
@@ -275,7 +277,7 @@ func main() {
 	go func() {
 		log.Println("开始执行新...")
 		// 更新版本
-		if err := performUpdate(info, &updater); err != nil {
+		if err := performUpdate(info, &updater, filepath.Dir(*updateFile)); err != nil {
 			log.Printf("更新失败: %v", err)
 			updater.SetStatus("更新失败, 详细请检查日志: " + logFilePath)
 			return
@@ -301,15 +303,58 @@ func main() {
 		// 等待一段时间确保程序启动
 		time.Sleep(2 * time.Second)
 
+		// 配置了健康确认时由更新助手看守，新版本需要在窗口期内调用 ConfirmHealthy
+		healthConfig := hotupdater.Config{UpdatePath: filepath.Dir(*updateFile)}
+		pending, err := hotupdater.ReadPendingHealth(healthConfig.UpdatePath)
+		if err != nil {
+			log.Printf("读取健康确认记录失败: %v", err)
+		}
+
 		// 验证程序是否成功启动
-		if isProcessRunning(filepath.Base(info.AppPath)) {
-			log.Println("新版本已成功启动")
-			updater.Close()
+		running := isProcessRunning(filepath.Base(info.AppPath))
+		if !pending.Watched() {
+			if running {
+				log.Println("新版本已成功启动")
+				updater.Close()
+			} else {
+				log.Println("新版本启动失败")
+				updater.SetStatus("新版本启动失败, 详细请检查日志: " + logFilePath)
+			}
+			return
+		}
+
+		if running {
+			log.Printf("等待新版本确认运行正常，截止时间: %s", pending.Deadline.Format("2006-01-02 15:04:05"))
+			updater.Synchronize(updater.Hide)
+			err := hotupdater.WaitHealthy(context.Background(), healthConfig)
+			if err == nil {
+				log.Println("新版本已确认运行正常")
+				updater.Close()
+				return
+			}
+			log.Printf("健康确认失败: %v", err)
+			updater.Synchronize(updater.Show)
 		} else {
 			log.Println("新版本启动失败")
-			updater.SetStatus("新版本启动失败, 详细请检查日志: " + logFilePath)
+		}
+
+		// 新版本未确认运行正常，结束新版本并恢复旧版本
+		updater.SetStatus("新版本未能正常运行，正在恢复旧版本...")
+		if err := waitProcessExit(filepath.Base(info.AppPath)); err != nil {
+			log.Printf("结束新版本失败: %v", err)
+		}
+		if err := hotupdater.RollbackUnhealthy(healthConfig); err != nil {
+			log.Printf("恢复旧版本失败: %v", err)
+			updater.SetStatus("恢复旧版本失败, 详细请检查日志: " + logFilePath)
+			return
+		}
+		log.Printf("已恢复旧版本，版本 %s 不会再次更新", pending.Version)
+		if err := RunCommand("cmd", "/c", "start", "", info.AppPath).Start(); err != nil {
+			log.Printf("启动旧版本失败: %v", err)
+			updater.SetStatus("启动旧版本失败, 详细请检查日志: " + logFilePath)
 			return
 		}
+		updater.Close()
 	}()
 
 	// 开始消息循环
@@ -338,7 +383,37 @@ func (w *UpdaterWindow) SetStatus(text string) {
 	})
 }
 
-func performUpdate(info UpdateInfo, updater *UpdaterWindow) error {
+// performUpdate 替换应用，journalDir 为更新日志所在目录（与更新信息文件相同）
+func performUpdate(info UpdateInfo, updater *UpdaterWindow, journalDir string) error {
+	// 继续记录脚本开始的更新
+	if entry, _ := hotupdater.ReadJournal(journalDir); entry != nil &&
+		(entry.State == hotupdater.JournalInstalling || entry.State == hotupdater.JournalInstalled ||
+			entry.State == hotupdater.JournalRollingBack) {
+		// 上次替换途中中断，先完成或回滚
+		log.Printf("发现中断的更新，状态: %s", entry.State)
+		if _, err := hotupdater.Recover(hotupdater.Config{UpdatePath: journalDir}); err != nil {
+			log.Printf("恢复中断的更新失败: %v", err)
+		}
+	}
+	journal, err := hotupdater.ResumeJournal(journalDir)
+	if err != nil {
+		log.Printf("打开更新日志失败: %v", err)
+	}
+	record := func(state hotupdater.JournalState, entry hotupdater.JournalEntry) {
+		entry.State = state
+		if err := journal.Record(entry); err != nil {
+			log.Printf("写入更新日志失败: %v", err)
+		}
+	}
+	// 从备份恢复，成功后更新结束，删除日志
+	restore := func() error {
+		if err := copyFile(info.BackupFile, info.AppPath); err != nil {
+			return err
+		}
+		journal.Commit()
+		return nil
+	}
+
 	// 等待原程序退出
 	updater.SetStatus("等待程序退出...")
 	processName := filepath.Base(info.AppPath)
@@ -369,12 +444,29 @@ func performUpdate(info UpdateInfo, updater *UpdaterWindow) error {
 		updater.SetStatus("创建备份...")
 		if err := copyFile(info.AppPath, backupFile); err != nil {
 			log.Printf("创建备份失败: %v", err)
-			return fmt.Errorf("创建备份失败: %v", err)
+			return fmt.Errorf("创建备份失败: %w", err)
 		}
 		log.Printf("已创建备份: %s", backupFile)
+		err := hotupdater.WriteBackupMeta(backupFile, hotupdater.BackupMeta{
+			Version:       info.CurrentVersion,
+			Target:        info.AppPath,
+			UpdateVersion: info.UpdateVersion,
+		})
+		if err != nil {
+			log.Printf("写入备份说明文件失败: %v", err)
+		}
 	}
 	updater.SetProgress(40)
 
+	// 删除旧版本之前记录日志，中断后应用下次启动时从备份恢复
+	record(hotupdater.JournalInstalling, hotupdater.JournalEntry{
+		Target:         info.AppPath,
+		NewVersion:     info.NewVersion,
+		BackupFile:     backupFile,
+		CurrentVersion: info.CurrentVersion,
+		UpdateVersion:  info.UpdateVersion,
+	})
+
 	log.Printf("删除旧版本: %s", info.AppPath)
 	// 删除旧版本前先尝试修改权限
 	updater.SetStatus("删除旧版本...")
@@ -390,7 +482,7 @@ func performUpdate(info UpdateInfo, updater *UpdaterWindow) error {
 			break
 		}
 		if i == maxRetries-1 {
-			return fmt.Errorf("删除旧版本失败: %v", err)
+			return fmt.Errorf("删除旧版本失败: %w", err)
 		}
 		log.Printf("删除文件失败，重试 %d/%d: %v", i+1, maxRetries, err)
 		time.Sleep(time.Second)
@@ -403,10 +495,10 @@ func performUpdate(info UpdateInfo, updater *UpdaterWindow) error {
 		// 恢复备份
 		log.Printf("复制新版本失败，准备回滚到备份: %s", info.BackupFile)
 		updater.SetStatus("更新失败，正在恢复...")
-		if restoreErr := copyFile(info.BackupFile, info.AppPath); restoreErr != nil {
-			return fmt.Errorf("更新失败且无法恢复备份: %v", restoreErr)
+		if restoreErr := restore(); restoreErr != nil {
+			return fmt.Errorf("复制新版本失败且无法恢复备份: %w (原始错误: %w)", restoreErr, err)
 		}
-		return fmt.Errorf("复制新版本失败: %v", err)
+		return fmt.Errorf("复制新版本失败: %w", err)
 	}
 	updater.SetProgress(80)
 
@@ -416,10 +508,10 @@ func performUpdate(info UpdateInfo, updater *UpdaterWindow) error {
 		// 验证失败，恢复备份
 		log.Printf("验证新版本失败: %v，准备回滚", err)
 		updater.SetStatus("验证失败，正在恢复...")
-		if restoreErr := copyFile(info.BackupFile, info.AppPath); restoreErr != nil {
-			return fmt.Errorf("验证失败且无法恢复备份: %v (原始错误: %v)", restoreErr, err)
+		if restoreErr := restore(); restoreErr != nil {
+			return fmt.Errorf("验证失败且无法恢复备份: %w (原始错误: %w)", restoreErr, err)
 		}
-		return fmt.Errorf("验证新版本失败: %v", err)
+		return fmt.Errorf("验证新版本失败: %w", err)
 	}
 
 	// 额外验证文件大小
@@ -427,12 +519,14 @@ func performUpdate(info UpdateInfo, updater *UpdaterWindow) error {
 	if err != nil || newFileInfo.Size() == 0 {
 		log.Printf("新版本文件异常: %v", err)
 		updater.SetStatus("验证失败，正在恢复...")
-		if restoreErr := copyFile(info.BackupFile, info.AppPath); restoreErr != nil {
-			return fmt.Errorf("验证失败且无法恢复备份: %v", restoreErr)
+		if restoreErr := restore(); restoreErr != nil {
+			return fmt.Errorf("验证失败且无法恢复备份: %w", restoreErr)
 		}
 		return fmt.Errorf("新版本文件无效")
 	}
 
+	record(hotupdater.JournalInstalled, hotupdater.JournalEntry{})
+	journal.Commit()
 	updater.SetProgress(100)
 	return nil
 }
@@ -482,7 +576,7 @@ func isProcessRunning(processName string) bool {
 func killProcess(processName string) error {
 	cmd := RunCommand("taskkill", "/F", "/IM", processName)
 	if output, err := cmd.CombinedOutput(); err != nil {
-		return fmt.Errorf("终止进程失败: %v (输出: %s)", err, string(output))
+		return fmt.Errorf("终止进程失败: %w (输出: %s)", err, string(output))
 	}
 	return nil
 }
@@ -505,7 +599,7 @@ func waitProcessExit(processName string) error {
 			// 超过5秒，强制结束所有实例
 			log.Printf("进程 %s 5秒内未退出，准备强制止所有实例", processName)
 			if err := killProcess(processName); err != nil {
-				return fmt.Errorf("强制终止进程失败: %v", err)
+				return fmt.Errorf("强制终止进程失败: %w", err)
 			}
 			// 等待1秒确认进程已终止
 			time.Sleep(time.Second)
@@ -532,7 +626,7 @@ func waitProcessExit(processName string) error {
 func copyFile(src, dst string) error {
 	cmd := RunCommand("cmd", "/c", "copy", "/Y", src, dst)
 	if output, err := cmd.CombinedOutput(); err != nil {
-		return fmt.Errorf("复制文件失败: %v (输出: %s)", err, string(output))
+		return fmt.Errorf("复制文件失败: %w (输出: %s)", err, string(output))
 	}
 	return nil
 }
@@ -0,0 +1,220 @@
+package hotupdater
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
+)
+
+// backupTimeLayout 备份文件名中的时间格式
+const backupTimeLayout = "20060102_150405"
+
+// newBackupName 生成备份文件名，命名规则与 update.lua 保持一致：
+// backup_<版本>_<时间>，Windows 单文件备份为 .exe，其他情况为 .tar.gz
+func newBackupName(version string, isDir bool, t time.Time) string {
+	name := "backup_" + t.Format(backupTimeLayout)
+	if version != "" {
+		name = fmt.Sprintf("backup_%s_%s", version, t.Format(backupTimeLayout))
+	}
+	if runtime.GOOS == "windows" && !isDir {
+		return name + ".exe"
+	}
+	return name + ".tar.gz"
+}
+
+// 备份格式
+const (
+	BackupFormatTarGz = "tar.gz" // 目录或 macOS 应用包，恢复时解压到原位置
+	BackupFormatFile  = "file"   // 单个可执行文件的副本
+)
+
+// BackupMeta 备份旁边的 JSON 说明文件（<备份文件>.json），记录恢复所需的信息
+// 没有说明文件的旧备份从文件名解析版本和时间，原始路径需要由调用方提供。
+type BackupMeta struct {
+	Version       string    `json:"version"`                  // 备份时的版本号
+	Time          time.Time `json:"time"`                     // 备份时间
+	Target        string    `json:"target"`                   // 被备份的原始路径，恢复到此处
+	Platform      string    `json:"platform"`                 // 创建备份的平台，如 darwin/arm64
+	Size          int64     `json:"size"`                     // 备份文件大小（字节）
+	SHA256        string    `json:"sha256"`                   // 备份文件的 SHA-256（十六进制）
+	Format        string    `json:"format"`                   // BackupFormatTarGz 或 BackupFormatFile
+	UpdateVersion string    `json:"update_version,omitempty"` // 创建该备份的更新要安装的版本
+}
+
+// BackupMetaPath 返回备份说明文件的路径
+func BackupMetaPath(backupFile string) string {
+	return backupFile + ".json"
+}
+
+// backupFormat 按扩展名判断备份格式
+func backupFormat(backupFile string) string {
+	if strings.HasSuffix(backupFile, ".tar.gz") {
+		return BackupFormatTarGz
+	}
+	return BackupFormatFile
+}
+
+// WriteBackupMeta 为已创建的备份写入说明文件
+// Size、SHA256、Format、Platform 由备份文件计算，Time 为空时取当前时间。
+func WriteBackupMeta(backupFile string, meta BackupMeta) error {
+	sum, size, err := FileSHA256(backupFile)
+	if err != nil {
+		return err
+	}
+	meta.Size, meta.SHA256 = size, sum
+	meta.Format = backupFormat(backupFile)
+	meta.Platform = runtime.GOOS + "/" + runtime.GOARCH
+	if meta.Time.IsZero() {
+		meta.Time = time.Now()
+	}
+	return writeJSONFile(BackupMetaPath(backupFile), meta)
+}
+
+// ReadBackupMeta 读取备份说明文件，没有时返回 nil
+func ReadBackupMeta(backupFile string) (*BackupMeta, error) {
+	data, err := os.ReadFile(BackupMetaPath(backupFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var meta BackupMeta
+	if err := json.Unmarshal(data, &meta); err != nil {
+		return nil, err
+	}
+	return &meta, nil
+}
+
+// describeBackup 为没有说明文件的备份补写说明文件，如 update.lua 未加载原生模块时创建的备份
+func (f *FastUpdater) describeBackup(backup string) {
+	if backup == "" {
+		return
+	}
+	if meta, err := ReadBackupMeta(backup); err != nil || meta != nil {
+		return
+	}
+	target, err := backupTarget(backup, f.updater.GetCurrentExe())
+	if err != nil {
+		f.config.Logger.Logf("读取备份失败: %v", err)
+		return
+	}
+	version, t, _ := ParseBackupName(filepath.Base(backup))
+	meta := BackupMeta{Version: version, Time: t, Target: target, UpdateVersion: f.config.UpdateVersion}
+	if err := WriteBackupMeta(backup, meta); err != nil {
+		f.config.Logger.Logf("写入备份说明文件失败: %v", err)
+	}
+}
+
+// Backup 备份目录中的一个备份
+type Backup struct {
+	Path    string      // 备份文件路径
+	Version string      // 备份时的版本号，备份时未配置版本号时为空
+	Time    time.Time   // 备份时间
+	Size    int64       // 文件大小（字节）
+	Target  string      // 被备份的原始路径，没有说明文件时为空
+	Meta    *BackupMeta // 说明文件，旧备份没有时为 nil
+}
+
+// ParseBackupName 解析 backup_<版本>_<时间>.exe 或 .tar.gz 形式的备份文件名，不是备份文件时 ok 为 false
+func ParseBackupName(name string) (version string, t time.Time, ok bool) {
+	stem := strings.TrimSuffix(strings.TrimSuffix(name, ".tar.gz"), ".exe")
+	if stem == name || !strings.HasPrefix(stem, "backup_") {
+		return "", time.Time{}, false
+	}
+	rest := strings.TrimPrefix(stem, "backup_")
+	if len(rest) < len(backupTimeLayout) {
+		return "", time.Time{}, false
+	}
+	t, err := time.ParseInLocation(backupTimeLayout, rest[len(rest)-len(backupTimeLayout):], time.Local)
+	if err != nil {
+		return "", time.Time{}, false
+	}
+	version = strings.TrimSuffix(rest[:len(rest)-len(backupTimeLayout)], "_")
+	return version, t, true
+}
+
+// ListBackups 返回 dir 中的备份，按备份时间从新到旧排序，目录不存在时返回空
+// 有说明文件时以其中的版本、时间和原始路径为准，否则从文件名解析。
+func ListBackups(dir string) ([]Backup, error) {
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var backups []Backup
+	for _, e := range entries {
+		if e.IsDir() {
+			continue
+		}
+		version, t, ok := ParseBackupName(e.Name())
+		if !ok {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+		b := Backup{
+			Path:    filepath.Join(dir, e.Name()),
+			Version: version,
+			Time:    t,
+			Size:    info.Size(),
+		}
+		if meta, err := ReadBackupMeta(b.Path); err == nil && meta != nil {
+			b.Meta = meta
+			b.Version, b.Target = meta.Version, meta.Target
+			if !meta.Time.IsZero() {
+				b.Time = meta.Time
+			}
+		}
+		backups = append(backups, b)
+	}
+	sort.SliceStable(backups, func(i, j int) bool { return backups[i].Time.After(backups[j].Time) })
+	return backups, nil
+}
+
+// writeBackup 把 target 备份到 backupFile，按扩展名打包为 tar.gz 或复制单个文件，失败时删除不完整的备份
+func writeBackup(target, backupFile string) error {
+	var err error
+	if strings.HasSuffix(backupFile, ".tar.gz") {
+		err = createTarGz(target, backupFile)
+	} else {
+		err = copyFile(target, backupFile)
+	}
+	if err != nil {
+		os.Remove(backupFile)
+	}
+	return err
+}
+
+// restoreBackupFile 用备份文件恢复 target
+// tar.gz 备份内记录的是去掉根目录的绝对路径，解压到 target 所在卷的根目录即回到原位置。
+func restoreBackupFile(backupFile, target string) error {
+	if strings.HasSuffix(backupFile, ".tar.gz") {
+		if err := os.RemoveAll(target); err != nil {
+			return err
+		}
+		return extractTarGz(backupFile, archiveRoot(target))
+	}
+	return copyFile(backupFile, target)
+}
+
+// archiveRoot 返回 path 所在卷的根目录，Unix 为 /，Windows 为盘符根目录
+func archiveRoot(path string) string {
+	return filepath.VolumeName(path) + string(filepath.Separator)
+}
+
+// defaultBackupDir 未配置 BackupPath 时使用被替换路径同级的 backup 目录
+func defaultBackupDir(target string) string {
+	return filepath.Join(filepath.Dir(target), "backup")
+}
@@ -0,0 +1,257 @@
+package hotupdater
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// BackupManager 管理备份目录中的备份，供应用在设置界面等处提供“回退到旧版本”
+//
+//	m := hotupdater.NewBackupManager(config)
+//	if b, err := m.Previous(); err == nil && b != nil {
+//		if err := m.Restore(*b); err != nil {
+//			log.Printf("恢复失败: %v", err)
+//		}
+//		// 恢复成功后重启应用
+//	}
+//
+// 备份文件名和说明文件与更新时创建的备份一致，恢复助手和 update.lua 创建的备份都可以管理。
+// 操作进度通过 Config.EventEmitter 发送，Percentage 为当前操作自身的进度（0-100），
+// Create 和 Delete 的阶段为 PhaseBackup，Verify 为 PhaseVerify，Restore 为 PhaseInstall。
+type BackupManager struct {
+	config Config
+	dir    string
+}
+
+// NewBackupManager 创建备份管理器
+// 备份目录为 Config.BackupPath，为空时与 BackupStep 一致，使用当前应用同级的 backup 目录。
+func NewBackupManager(config Config) *BackupManager {
+	dir := config.BackupPath
+	if dir == "" {
+		if exe, err := os.Executable(); err == nil {
+			dir = defaultBackupDir(defaultBackupTarget(exe))
+		}
+	}
+	return &BackupManager{config: config, dir: dir}
+}
+
+// Dir 返回备份目录
+func (m *BackupManager) Dir() string {
+	return m.dir
+}
+
+// Create 备份 target 并写入说明文件，target 为空时备份当前应用
+// 目录和 macOS 应用包打包为 tar.gz，Windows 上的单个文件直接复制。版本号取自 Config.CurrentVersion。
+func (m *BackupManager) Create(target string) (*Backup, error) {
+	if target == "" {
+		exe, err := os.Executable()
+		if err != nil {
+			return nil, err
+		}
+		target = defaultBackupTarget(exe)
+	}
+	target, err := filepath.Abs(target)
+	if err != nil {
+		return nil, err
+	}
+
+	m.progress(PhaseBackup, 0, MsgBackupPrepare, target)
+	info, err := os.Stat(target)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(m.dir, 0755); err != nil {
+		return nil, m.config.localizer().wrap(MsgErrBackupDir, err)
+	}
+
+	now := time.Now()
+	backupFile := filepath.Join(m.dir, newBackupName(m.config.CurrentVersion, info.IsDir(), now))
+	m.logf("创建备份文件: %s", backupFile)
+	m.progress(PhaseBackup, 30, MsgBackupCreate, backupFile)
+	if err := writeBackup(target, backupFile); err != nil {
+		return nil, err
+	}
+	meta := BackupMeta{Version: m.config.CurrentVersion, Time: now, Target: target}
+	if err := WriteBackupMeta(backupFile, meta); err != nil {
+		os.Remove(backupFile)
+		return nil, err
+	}
+
+	b, err := m.read(backupFile)
+	if err != nil {
+		return nil, err
+	}
+	m.progress(PhaseBackup, 100, MsgBackupDone, backupFile)
+	return b, nil
+}
+
+// List 返回备份目录中的备份，按备份时间从新到旧排序
+func (m *BackupManager) List() ([]Backup, error) {
+	return ListBackups(m.dir)
+}
+
+// Previous 返回可以回退到的最新备份：版本号与 Config.CurrentVersion 不同（按语义化版本比较）且不在失败版本中，没有时返回 nil
+func (m *BackupManager) Previous() (*Backup, error) {
+	backups, err := m.List()
+	if err != nil {
+		return nil, err
+	}
+	failed, _ := FailedVersions(m.config.UpdatePath)
+	for _, b := range backups {
+		if b.Version == "" || sameVersion(b.Version, m.config.CurrentVersion) || isFailedVersionString(failed, b.Version) {
+			continue
+		}
+		return &b, nil
+	}
+	return nil, nil
+}
+
+// Verify 校验备份是否完整，失败时返回的错误匹配 ErrBackupCorrupt
+// 有说明文件时比较大小和 SHA-256，否则检查 tar.gz 能否完整读取、单文件备份是否为空。
+func (m *BackupManager) Verify(b Backup) error {
+	m.progress(PhaseVerify, 0, MsgBackupVerify, b.Path)
+	if err := verifyBackup(m.config.localizer(), b); err != nil {
+		m.logf("备份校验失败: %v", err)
+		return m.config.localizer().errorOf(MsgErrBackupCorrupt, errors.Join(ErrBackupCorrupt, err), err.Error())
+	}
+	m.progress(PhaseVerify, 100, MsgBackupVerified, b.Path)
+	return nil
+}
+
+// Restore 校验备份后恢复到备份的原始路径，返回前不会重启应用
+// 恢复位置依次取 b.Target、说明文件、tar.gz 中记录的路径，没有说明文件的单文件备份恢复到当前可执行文件。
+// 当前版本先被移开，恢复失败时移回；运行中的应用也可以恢复，恢复后需要重启才能生效。
+// 恢复成功后不再等待当前版本的健康确认。
+func (m *BackupManager) Restore(b Backup) error {
+	l := m.config.localizer()
+	target := b.Target
+	if target == "" {
+		exe, err := os.Executable()
+		if err != nil {
+			return l.wrap(MsgErrBackupTarget, err, b.Path)
+		}
+		if target, err = backupTarget(b.Path, exe); err != nil {
+			return l.wrap(MsgErrBackupTarget, err, b.Path)
+		}
+	}
+
+	if err := m.Verify(b); err != nil {
+		return err
+	}
+
+	m.logf("开始恢复备份: %s -> %s", b.Path, target)
+	m.progress(PhaseInstall, 30, MsgRestoreStart, target)
+	if err := restoreRunning(b.Path, target); err != nil {
+		m.logf("恢复备份失败: %v", err)
+		return l.wrap(MsgErrRestoreBackup, err)
+	}
+	if p, _ := ReadPendingHealth(m.config.UpdatePath); p != nil {
+		if err := removeFile(filepath.Join(m.config.UpdatePath, healthFile)); err != nil {
+			m.logf("删除健康确认记录失败: %v", err)
+		}
+	}
+	m.logf("备份恢复成功: %s", target)
+	m.progress(PhaseInstall, 100, MsgRestoreDone, target)
+	return nil
+}
+
+// Delete 删除备份及其说明文件，等待健康确认的更新所用的备份不能删除
+func (m *BackupManager) Delete(b Backup) error {
+	if p, _ := ReadPendingHealth(m.config.UpdatePath); p != nil && p.BackupFile != "" &&
+		filepath.Clean(p.BackupFile) == filepath.Clean(b.Path) {
+		return m.config.localizer().newError(MsgErrBackupInUse, b.Path)
+	}
+	m.progress(PhaseBackup, 0, MsgBackupDelete, b.Path)
+	if err := os.Remove(b.Path); err != nil {
+		return err
+	}
+	if err := removeFile(BackupMetaPath(b.Path)); err != nil {
+		m.logf("删除备份说明文件失败: %v", err)
+	}
+	m.logf("已删除备份: %s", b.Path)
+	m.progress(PhaseBackup, 100, MsgBackupDeleted, b.Path)
+	return nil
+}
+
+// read 读取单个备份的信息，说明文件优先
+func (m *BackupManager) read(backupFile string) (*Backup, error) {
+	info, err := os.Stat(backupFile)
+	if err != nil {
+		return nil, err
+	}
+	b := &Backup{Path: backupFile, Size: info.Size()}
+	b.Version, b.Time, _ = ParseBackupName(filepath.Base(backupFile))
+	if meta, err := ReadBackupMeta(backupFile); err == nil && meta != nil {
+		b.Meta = meta
+		b.Version, b.Target = meta.Version, meta.Target
+		if !meta.Time.IsZero() {
+			b.Time = meta.Time
+		}
+	}
+	return b, nil
+}
+
+// progress 发送备份操作的进度，detail 为操作的文件
+func (m *BackupManager) progress(phase UpdatePhase, percentage int, id MessageID, detail string) {
+	if m.config.EventEmitter == nil {
+		return
+	}
+	m.config.EventEmitter.EmitProgress(UpdateProgress{
+		Phase:      phase,
+		Percentage: percentage,
+		MessageID:  id,
+		Message:    m.config.localizer().T(id),
+		Detail:     detail,
+	})
+}
+
+func (m *BackupManager) logf(format string, args ...interface{}) {
+	if m.config.Logger != nil {
+		m.config.Logger.Logf(format, args...)
+	}
+}
+
+// defaultBackupTarget 返回备份当前应用时的路径：macOS 为 .app 包，其他平台为可执行文件
+func defaultBackupTarget(exe string) string {
+	if strings.Contains(exe, ".app/") {
+		return installRoot(exe)
+	}
+	return exe
+}
+
+// verifyBackup 校验备份文件本身
+func verifyBackup(l *Localizer, b Backup) error {
+	meta := b.Meta
+	if meta == nil {
+		var err error
+		if meta, err = ReadBackupMeta(b.Path); err != nil {
+			return err
+		}
+	}
+	if meta != nil && meta.SHA256 != "" {
+		return VerifyPackage(b.Path, meta.SHA256, meta.Size)
+	}
+
+	if !strings.HasSuffix(b.Path, ".tar.gz") {
+		info, err := os.Stat(b.Path)
+		if err != nil {
+			return err
+		}
+		if info.Size() == 0 {
+			return l.newError(MsgErrBackupEmpty, b.Path)
+		}
+		return nil
+	}
+	// 读到归档末尾时 gzip 会检查校验和
+	names, err := listTarGz(b.Path)
+	if err != nil {
+		return err
+	}
+	if len(names) == 0 {
+		return l.newError(MsgErrArchiveEmpty, b.Path)
+	}
+	return nil
+}
@@ -0,0 +1,66 @@
+package hotupdater
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestBackupManagerPreviousComparesSemver(t *testing.T) {
+	dir := t.TempDir()
+	target := filepath.Join(dir, "app")
+	if err := os.MkdirAll(target, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(target, "bin"), []byte("bin"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	backupDir := filepath.Join(dir, "backup")
+	for _, v := range []string{"1.1.0", "v1.2.0"} {
+		m := NewBackupManager(Config{BackupPath: backupDir, CurrentVersion: v})
+		if _, err := m.Create(target); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	m := NewBackupManager(Config{BackupPath: backupDir, UpdatePath: filepath.Join(dir, "update"), CurrentVersion: "1.2.0"})
+	b, err := m.Previous()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b == nil || b.Version != "1.1.0" {
+		t.Fatalf("v1.2.0 与当前版本相同，应跳过，实际 %+v", b)
+	}
+}
+
+func TestBackupManagerVerifyEmpty(t *testing.T) {
+	dir := t.TempDir()
+	exe := filepath.Join(dir, "backup_1.0.0_20250101_120000.exe")
+	if err := os.WriteFile(exe, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	// 没有任何条目的 tar.gz
+	archive := filepath.Join(dir, "backup_1.0.0_20250101_120000.tar.gz")
+	f, err := os.Create(archive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gz := gzip.NewWriter(f)
+	tar.NewWriter(gz).Close()
+	gz.Close()
+	f.Close()
+
+	m := NewBackupManager(Config{BackupPath: dir, Locale: "en"})
+	for path, want := range map[string]string{
+		exe:     "backup is corrupt: backup file is empty: " + exe,
+		archive: "backup is corrupt: archive is empty: " + archive,
+	} {
+		err := m.Verify(Backup{Path: path})
+		if !errors.Is(err, ErrBackupCorrupt) || err.Error() != want {
+			t.Fatalf("错误信息为 %v，期望 %s", err, want)
+		}
+	}
+}
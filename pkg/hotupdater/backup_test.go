@@ -0,0 +1,102 @@
+package hotupdater
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeTree 按 files 在 root 下创建文件
+func writeTree(t *testing.T, root string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// checkTree 确认 root 下的文件内容与 files 一致
+func checkTree(t *testing.T, root string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		data, err := os.ReadFile(filepath.Join(root, filepath.FromSlash(name)))
+		if err != nil {
+			t.Fatalf("读取 %s 失败: %v", name, err)
+		}
+		if string(data) != content {
+			t.Fatalf("%s 的内容为 %q，期望 %q", name, data, content)
+		}
+	}
+}
+
+// 备份内记录的是去掉盘符的绝对路径，各平台都应能恢复到临时目录中的原位置
+func TestBackupRestoreRoundTrip(t *testing.T) {
+	files := map[string]string{"bin": "old bin", "res/data.txt": "old data", "res/deep/x": "x"}
+
+	t.Run("tar.gz", func(t *testing.T) {
+		dir := t.TempDir()
+		target := filepath.Join(dir, "App")
+		writeTree(t, target, files)
+		backupFile := filepath.Join(dir, "backup", newBackupName("1.0.0", true, time.Now()))
+		if err := writeBackup(target, backupFile); err != nil {
+			t.Fatal(err)
+		}
+
+		if got, err := backupTarget(backupFile, target); err != nil || !pathWithin(got, target) || !pathWithin(target, got) {
+			t.Fatalf("备份记录的路径为 %q，期望 %q: %v", got, target, err)
+		}
+
+		writeTree(t, target, map[string]string{"bin": "new bin", "extra": "new"})
+		if err := restoreBackupFile(backupFile, target); err != nil {
+			t.Fatalf("恢复备份失败: %v", err)
+		}
+		checkTree(t, target, files)
+		if _, err := os.Stat(filepath.Join(target, "extra")); err == nil {
+			t.Fatal("恢复后不应保留新版本的文件")
+		}
+	})
+
+	t.Run("file", func(t *testing.T) {
+		dir := t.TempDir()
+		target := filepath.Join(dir, "app.exe")
+		writeTree(t, dir, map[string]string{"app.exe": "old exe"})
+		backupFile := filepath.Join(dir, "backup", "backup_1.0.0_20240101_000000.exe")
+		if err := writeBackup(target, backupFile); err != nil {
+			t.Fatal(err)
+		}
+		writeTree(t, dir, map[string]string{"app.exe": "new exe"})
+		if err := restoreBackupFile(backupFile, target); err != nil {
+			t.Fatalf("恢复备份失败: %v", err)
+		}
+		checkTree(t, dir, map[string]string{"app.exe": "old exe"})
+	})
+
+	t.Run("BackupManager", func(t *testing.T) {
+		dir := t.TempDir()
+		target := filepath.Join(dir, "App")
+		writeTree(t, target, files)
+		m := NewBackupManager(Config{BackupPath: filepath.Join(dir, "backup"), UpdatePath: filepath.Join(dir, "update"), CurrentVersion: "1.0.0"})
+		b, err := m.Create(target)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// 不依赖说明文件中的路径，按归档内的路径恢复
+		b.Target = ""
+		if err := os.Remove(BackupMetaPath(b.Path)); err != nil {
+			t.Fatal(err)
+		}
+		b.Meta = nil
+
+		writeTree(t, target, map[string]string{"bin": "new bin"})
+		if err := m.Restore(*b); err != nil {
+			t.Fatalf("恢复备份失败: %v", err)
+		}
+		checkTree(t, target, files)
+	})
+}
@@ -3,8 +3,33 @@
 
 package hotupdater
 
-import "os/exec"
+import (
+	"context"
+	"os/exec"
+	"syscall"
+)
 
 func ExecuteCommand(cmd string) bool {
-	return exec.Command("sh", "-c", cmd).Run() == nil
+	return ExecuteCommandContext(context.Background(), cmd)
+}
+
+// ExecuteCommandContext 执行 shell 命令，ctx 取消时结束命令进程
+func ExecuteCommandContext(ctx context.Context, cmd string) bool {
+	return shellCommand(ctx, cmd).Run() == nil
+}
+
+// shellCommand 与 gopher-lua 的 os.execute 一样通过 /bin/sh -c 执行命令
+// 命令在独立的进程组中运行，取消时结束整个进程组，避免 cp、tar 等子进程继续运行
+func shellCommand(ctx context.Context, cmd string) *exec.Cmd {
+	return directCommand(ctx, "/bin/sh", "-c", cmd)
+}
+
+// directCommand 不经过 shell 直接执行程序，进程组的处理与 shellCommand 相同
+func directCommand(ctx context.Context, name string, args ...string) *exec.Cmd {
+	c := exec.CommandContext(ctx, name, args...)
+	c.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	c.Cancel = func() error {
+		return syscall.Kill(-c.Process.Pid, syscall.SIGKILL)
+	}
+	return c
 }
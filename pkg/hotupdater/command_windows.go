@@ -4,14 +4,34 @@
 package hotupdater
 
 import (
+	"context"
 	"os/exec"
 	"syscall"
 )
 
 func ExecuteCommand(cmd string) bool {
-	psCmd := exec.Command("powershell", "-Command", cmd)
+	return ExecuteCommandContext(context.Background(), cmd)
+}
+
+// ExecuteCommandContext 通过 PowerShell 执行命令，ctx 取消时结束命令进程
+func ExecuteCommandContext(ctx context.Context, cmd string) bool {
+	psCmd := exec.CommandContext(ctx, "powershell", "-Command", cmd)
 	psCmd.SysProcAttr = &syscall.SysProcAttr{
 		HideWindow: true,
 	}
 	return psCmd.Run() == nil
 }
+
+// shellCommand 与 gopher-lua 的 os.execute 一样通过 cmd.exe /c 执行命令
+func shellCommand(ctx context.Context, cmd string) *exec.Cmd {
+	return directCommand(ctx, "cmd.exe", "/c", cmd)
+}
+
+// directCommand 不经过 shell 直接执行程序
+func directCommand(ctx context.Context, name string, args ...string) *exec.Cmd {
+	c := exec.CommandContext(ctx, name, args...)
+	c.SysProcAttr = &syscall.SysProcAttr{
+		HideWindow: true,
+	}
+	return c
+}
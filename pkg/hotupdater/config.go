@@ -1,5 +1,10 @@
 package hotupdater
 
+import (
+	"crypto/ed25519"
+	"time"
+)
+
 // Config 热更新配置
 type Config struct {
 	CurrentVersion string       //当前版本号
@@ -7,10 +12,31 @@ type Config struct {
 	UpdatePath     string       // 更新文件存放路径
 	BackupPath     string       // 备份路径
 	ScriptPath     string       // Lua脚本路径
-	OnUpdate       func(error)  // 更新回调
+	OnUpdate       func(error)  // 更新回调，FastUpdater 更新结束时调用，成功时参数为 nil
 	Logger         Logger       // 日志接口
 	EventEmitter   EventEmitter // 事件发送器
 	DownloadImpl   DownloadImplementation
+	PackageSHA256  string // 更新包期望的 SHA-256（十六进制），为空不校验
+	PackageSize    int64  // 更新包期望的大小（字节），为 0 不校验
+
+	PublicKeys       []ed25519.PublicKey // 更新包签名公钥，非空时更新包必须通过签名校验
+	PackageSignature string              // 更新包的 base64 签名，为空时读取更新包同目录的 .sig 文件
+
+	AllowDowngrade bool   // 允许安装更低或相同的版本，用于回滚
+	Channel        string // 更新通道，如 stable、beta、internal，为空时为 stable
+	Locale         string // 进度提示和错误信息的语言，如 zh、en、ja，为空时为 zh
+
+	LuaSandbox *LuaSandbox // 非空时在沙箱中执行 Lua 更新脚本
+	Pipeline   *Pipeline   // 非空时使用 Go 流水线执行更新，不再执行 ScriptPath 指定的脚本
+
+	ProgressModel *ProgressModel // 进度阶段、权重和提示信息，为空时使用 DefaultProgressModel
+	Hooks         Hooks          // 生命周期钩子，见 Hooks
+
+	HealthWindow    time.Duration // 新版本需要在此时间内调用 ConfirmHealthy，否则回滚并不再提供该版本；为 0 时不检查
+	CrashLoopLimit  int           // 新版本连续启动多少次仍未 MarkStable 时由 TrackLaunch 回滚，为 0 时为 3
+	CrashLoopWindow time.Duration // 距上次启动超过此时间时上次启动不算崩溃，为 0 时为 1 分钟
+
+	BackupRetention BackupRetention // 更新成功后清理旧备份的策略，零值时不清理
 }
 
 // Logger 日志接口
@@ -37,6 +63,9 @@ type UpdateStatus struct {
 // 添加复制方法
 func (c Config) Clone() Config {
 	return Config{
+		CurrentVersion: c.CurrentVersion,
+		UpdateVersion:  c.UpdateVersion,
+
 		UpdatePath:   c.UpdatePath,
 		BackupPath:   c.BackupPath,
 		ScriptPath:   c.ScriptPath,
@@ -44,5 +73,27 @@ func (c Config) Clone() Config {
 		Logger:       c.Logger,
 		EventEmitter: c.EventEmitter,
 		DownloadImpl: c.DownloadImpl,
+
+		PackageSHA256: c.PackageSHA256,
+		PackageSize:   c.PackageSize,
+
+		PublicKeys:       c.PublicKeys,
+		PackageSignature: c.PackageSignature,
+
+		AllowDowngrade: c.AllowDowngrade,
+		Channel:        c.Channel,
+		Locale:         c.Locale,
+
+		LuaSandbox: c.LuaSandbox,
+		Pipeline:   c.Pipeline,
+
+		ProgressModel: c.ProgressModel.Clone(),
+		Hooks:         c.Hooks,
+
+		HealthWindow:    c.HealthWindow,
+		CrashLoopLimit:  c.CrashLoopLimit,
+		CrashLoopWindow: c.CrashLoopWindow,
+
+		BackupRetention: c.BackupRetention,
 	}
 }
@@ -0,0 +1,484 @@
+package hotupdater
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// 增量补丁格式（bsdiff 算法）：
+//
+//	magic   [8]byte   "HUDELTA1"
+//	oldHash [32]byte  旧文件 SHA-256
+//	newHash [32]byte  新文件 SHA-256
+//	newSize int64     新文件大小（小端）
+//	body    gzip      依次为 (x, y, z int64) 控制块、x 字节差分数据、y 字节新增数据
+//
+// 应用时对旧文件从 oldpos 起的 x 字节逐字节相加差分数据，再追加 y 字节新增数据，然后 oldpos 移动 z。
+const deltaMagic = "HUDELTA1"
+
+// maxPatchNewSize 补丁生成的新文件大小上限，防止损坏或恶意的补丁头申请过多内存
+const maxPatchNewSize = 2 << 30
+
+// ErrPatchMismatch 补丁与当前文件不匹配
+var ErrPatchMismatch = errors.New("补丁与当前文件不匹配")
+
+// PatchHeader 补丁头信息
+type PatchHeader struct {
+	OldSHA256 [32]byte
+	NewSHA256 [32]byte
+	NewSize   int64
+}
+
+// CreatePatch 根据旧文件和新文件生成增量补丁，供发布流程调用
+// 生成补丁需要把两个文件完整读入内存，并为旧文件建立后缀数组。
+func CreatePatch(oldPath, newPath, patchPath string) error {
+	oldData, err := os.ReadFile(oldPath)
+	if err != nil {
+		return fmt.Errorf("读取旧文件失败: %w", err)
+	}
+	newData, err := os.ReadFile(newPath)
+	if err != nil {
+		return fmt.Errorf("读取新文件失败: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(patchPath), 0755); err != nil {
+		return fmt.Errorf("创建补丁目录失败: %w", err)
+	}
+	out, err := os.Create(patchPath)
+	if err != nil {
+		return fmt.Errorf("创建补丁文件失败: %w", err)
+	}
+	defer out.Close()
+
+	if err := WritePatch(out, oldData, newData); err != nil {
+		return err
+	}
+	return out.Close()
+}
+
+// WritePatch 生成 oldData 到 newData 的补丁并写入 w
+func WritePatch(w io.Writer, oldData, newData []byte) error {
+	header := PatchHeader{
+		OldSHA256: sha256.Sum256(oldData),
+		NewSHA256: sha256.Sum256(newData),
+		NewSize:   int64(len(newData)),
+	}
+	if _, err := io.WriteString(w, deltaMagic); err != nil {
+		return err
+	}
+	if err := binary.Write(w, binary.LittleEndian, header); err != nil {
+		return err
+	}
+
+	gw := gzip.NewWriter(w)
+	bw := bufio.NewWriter(gw)
+	if err := bsdiff(oldData, newData, bw); err != nil {
+		return fmt.Errorf("生成补丁失败: %w", err)
+	}
+	if err := bw.Flush(); err != nil {
+		return err
+	}
+	return gw.Close()
+}
+
+// ReadPatchHeader 读取补丁头信息
+func ReadPatchHeader(patchPath string) (*PatchHeader, error) {
+	f, err := os.Open(patchPath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return readPatchHeader(f)
+}
+
+func readPatchHeader(r io.Reader) (*PatchHeader, error) {
+	magic := make([]byte, len(deltaMagic))
+	if _, err := io.ReadFull(r, magic); err != nil || string(magic) != deltaMagic {
+		return nil, errors.New("不是有效的补丁文件")
+	}
+	var header PatchHeader
+	if err := binary.Read(r, binary.LittleEndian, &header); err != nil {
+		return nil, fmt.Errorf("读取补丁头失败: %w", err)
+	}
+	if header.NewSize < 0 || header.NewSize > maxPatchNewSize {
+		return nil, fmt.Errorf("补丁头无效: 新文件大小 %d", header.NewSize)
+	}
+	return &header, nil
+}
+
+// ApplyPatch 将补丁应用到旧文件，生成新文件
+// 会校验旧文件和生成结果的 SHA-256，结果先写入临时文件，校验通过后才重命名为 newPath。
+func ApplyPatch(oldPath, patchPath, newPath string) error {
+	oldData, err := os.ReadFile(oldPath)
+	if err != nil {
+		return fmt.Errorf("读取旧文件失败: %w", err)
+	}
+
+	pf, err := os.Open(patchPath)
+	if err != nil {
+		return fmt.Errorf("打开补丁文件失败: %w", err)
+	}
+	defer pf.Close()
+
+	header, err := readPatchHeader(pf)
+	if err != nil {
+		return err
+	}
+	if sha256.Sum256(oldData) != header.OldSHA256 {
+		return fmt.Errorf("%w: %s", ErrPatchMismatch, oldPath)
+	}
+
+	gr, err := gzip.NewReader(bufio.NewReader(pf))
+	if err != nil {
+		return fmt.Errorf("读取补丁内容失败: %w", err)
+	}
+	defer gr.Close()
+
+	newData, err := bspatch(oldData, gr, header.NewSize)
+	if err != nil {
+		return err
+	}
+	if sha256.Sum256(newData) != header.NewSHA256 {
+		return &IntegrityError{
+			Path:           newPath,
+			ExpectedSHA256: fmt.Sprintf("%x", header.NewSHA256),
+			ActualSHA256:   fmt.Sprintf("%x", sha256.Sum256(newData)),
+		}
+	}
+
+	tmpPath := newPath + partSuffix
+	if err := os.WriteFile(tmpPath, newData, 0755); err != nil {
+		return fmt.Errorf("写入新文件失败: %w", err)
+	}
+	if err := os.Rename(tmpPath, newPath); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("重命名新文件失败: %w", err)
+	}
+	return nil
+}
+
+// bspatch 根据补丁内容重建新文件
+func bspatch(oldData []byte, r io.Reader, newSize int64) ([]byte, error) {
+	newData := make([]byte, newSize)
+	oldSize := int64(len(oldData))
+	var oldPos, newPos int64
+	var ctrl [3]int64
+
+	for newPos < newSize {
+		if err := binary.Read(r, binary.LittleEndian, &ctrl); err != nil {
+			return nil, fmt.Errorf("补丁已损坏: %w", err)
+		}
+		// 与剩余空间比较，避免超大的控制值相加溢出
+		if ctrl[0] < 0 || ctrl[1] < 0 || ctrl[0] > newSize-newPos {
+			return nil, errors.New("补丁已损坏: 控制块越界")
+		}
+
+		// 差分数据与旧文件相加
+		if _, err := io.ReadFull(r, newData[newPos:newPos+ctrl[0]]); err != nil {
+			return nil, fmt.Errorf("补丁已损坏: %w", err)
+		}
+		for i := int64(0); i < ctrl[0]; i++ {
+			if oldPos+i >= 0 && oldPos+i < oldSize {
+				newData[newPos+i] += oldData[oldPos+i]
+			}
+		}
+		newPos += ctrl[0]
+		oldPos += ctrl[0]
+
+		// 新增数据直接复制
+		if ctrl[1] > newSize-newPos {
+			return nil, errors.New("补丁已损坏: 新增数据越界")
+		}
+		if _, err := io.ReadFull(r, newData[newPos:newPos+ctrl[1]]); err != nil {
+			return nil, fmt.Errorf("补丁已损坏: %w", err)
+		}
+		newPos += ctrl[1]
+		oldPos += ctrl[2]
+	}
+	return newData, nil
+}
+
+// bsdiff 生成补丁主体，算法与 bsdiff 4.3 相同
+func bsdiff(oldData, newData []byte, w io.Writer) error {
+	I := qsufsort(oldData)
+	oldSize := len(oldData)
+	newSize := len(newData)
+
+	var scan, pos, length int
+	var lastScan, lastPos, lastOffset int
+
+	for scan < newSize {
+		oldScore := 0
+		scan += length
+		for scsc := scan; scan < newSize; scan++ {
+			length, pos = search(I, oldData, newData[scan:], 0, oldSize)
+
+			for ; scsc < scan+length; scsc++ {
+				if scsc+lastOffset < oldSize && oldData[scsc+lastOffset] == newData[scsc] {
+					oldScore++
+				}
+			}
+
+			if (length == oldScore && length != 0) || length > oldScore+8 {
+				break
+			}
+
+			if scan+lastOffset < oldSize && oldData[scan+lastOffset] == newData[scan] {
+				oldScore--
+			}
+		}
+
+		if length == oldScore && scan != newSize {
+			continue
+		}
+
+		// 向前扩展
+		lenF := 0
+		for i, s, sf := 0, 0, 0; lastScan+i < scan && lastPos+i < oldSize; {
+			if oldData[lastPos+i] == newData[lastScan+i] {
+				s++
+			}
+			i++
+			if s*2-i > sf*2-lenF {
+				sf = s
+				lenF = i
+			}
+		}
+
+		// 向后扩展
+		lenB := 0
+		if scan < newSize {
+			for i, s, sb := 1, 0, 0; scan >= lastScan+i && pos >= i; i++ {
+				if oldData[pos-i] == newData[scan-i] {
+					s++
+				}
+				if s*2-i > sb*2-lenB {
+					sb = s
+					lenB = i
+				}
+			}
+		}
+
+		// 处理重叠
+		if lastScan+lenF > scan-lenB {
+			overlap := (lastScan + lenF) - (scan - lenB)
+			s, ss, lenS := 0, 0, 0
+			for i := 0; i < overlap; i++ {
+				if newData[lastScan+lenF-overlap+i] == oldData[lastPos+lenF-overlap+i] {
+					s++
+				}
+				if newData[scan-lenB+i] == oldData[pos-lenB+i] {
+					s--
+				}
+				if s > ss {
+					ss = s
+					lenS = i + 1
+				}
+			}
+			lenF += lenS - overlap
+			lenB -= lenS
+		}
+
+		diff := make([]byte, lenF)
+		for i := 0; i < lenF; i++ {
+			diff[i] = newData[lastScan+i] - oldData[lastPos+i]
+		}
+		extra := newData[lastScan+lenF : scan-lenB]
+
+		ctrl := [3]int64{
+			int64(lenF),
+			int64(len(extra)),
+			int64((pos - lenB) - (lastPos + lenF)),
+		}
+		if err := binary.Write(w, binary.LittleEndian, ctrl); err != nil {
+			return err
+		}
+		if _, err := w.Write(diff); err != nil {
+			return err
+		}
+		if _, err := w.Write(extra); err != nil {
+			return err
+		}
+
+		lastScan = scan - lenB
+		lastPos = pos - lenB
+		lastOffset = pos - scan
+	}
+	return nil
+}
+
+// search 在后缀数组中二分查找与 target 最长的匹配，返回匹配长度和旧文件中的位置
+func search(I []int, oldData, target []byte, st, en int) (int, int) {
+	for en-st >= 2 {
+		x := st + (en-st)/2
+		n := min(len(oldData)-I[x], len(target))
+		if bytes.Compare(oldData[I[x]:I[x]+n], target[:n]) < 0 {
+			st = x
+		} else {
+			en = x
+		}
+	}
+
+	x := matchLen(oldData[I[st]:], target)
+	y := matchLen(oldData[I[en]:], target)
+	if x > y {
+		return x, I[st]
+	}
+	return y, I[en]
+}
+
+func matchLen(a, b []byte) int {
+	i := 0
+	for i < len(a) && i < len(b) && a[i] == b[i] {
+		i++
+	}
+	return i
+}
+
+// qsufsort 使用 Larsson-Sadakane 算法构建后缀数组
+func qsufsort(buf []byte) []int {
+	n := len(buf)
+	I := make([]int, n+1)
+	V := make([]int, n+1)
+
+	var buckets [256]int
+	for _, c := range buf {
+		buckets[c]++
+	}
+	for i := 1; i < 256; i++ {
+		buckets[i] += buckets[i-1]
+	}
+	for i := 255; i > 0; i-- {
+		buckets[i] = buckets[i-1]
+	}
+	buckets[0] = 0
+
+	for i, c := range buf {
+		buckets[c]++
+		I[buckets[c]] = i
+	}
+	I[0] = n
+	for i, c := range buf {
+		V[i] = buckets[c]
+	}
+	V[n] = 0
+	for i := 1; i < 256; i++ {
+		if buckets[i] == buckets[i-1]+1 {
+			I[buckets[i]] = -1
+		}
+	}
+	I[0] = -1
+
+	for h := 1; I[0] != -(n + 1); h += h {
+		length := 0
+		i := 0
+		for i < n+1 {
+			if I[i] < 0 {
+				length -= I[i]
+				i -= I[i]
+			} else {
+				if length != 0 {
+					I[i-length] = -length
+				}
+				length = V[I[i]] + 1 - i
+				split(I, V, i, length, h)
+				i += length
+				length = 0
+			}
+		}
+		if length != 0 {
+			I[i-length] = -length
+		}
+	}
+
+	for i := 0; i < n+1; i++ {
+		I[V[i]] = i
+	}
+	return I
+}
+
+func split(I, V []int, start, length, h int) {
+	if length < 16 {
+		for k := start; k < start+length; {
+			j := 1
+			x := V[I[k]+h]
+			for i := 1; k+i < start+length; i++ {
+				if V[I[k+i]+h] < x {
+					x = V[I[k+i]+h]
+					j = 0
+				}
+				if V[I[k+i]+h] == x {
+					I[k+j], I[k+i] = I[k+i], I[k+j]
+					j++
+				}
+			}
+			for i := 0; i < j; i++ {
+				V[I[k+i]] = k + j - 1
+			}
+			if j == 1 {
+				I[k] = -1
+			}
+			k += j
+		}
+		return
+	}
+
+	x := V[I[start+length/2]+h]
+	jj, kk := 0, 0
+	for i := start; i < start+length; i++ {
+		if V[I[i]+h] < x {
+			jj++
+		}
+		if V[I[i]+h] == x {
+			kk++
+		}
+	}
+	jj += start
+	kk += jj
+
+	i, j, k := start, 0, 0
+	for i < jj {
+		switch {
+		case V[I[i]+h] < x:
+			i++
+		case V[I[i]+h] == x:
+			I[i], I[jj+j] = I[jj+j], I[i]
+			j++
+		default:
+			I[i], I[kk+k] = I[kk+k], I[i]
+			k++
+		}
+	}
+
+	for jj+j < kk {
+		if V[I[jj+j]+h] == x {
+			j++
+		} else {
+			I[jj+j], I[kk+k] = I[kk+k], I[jj+j]
+			k++
+		}
+	}
+
+	if jj > start {
+		split(I, V, start, jj-start, h)
+	}
+
+	for i := 0; i < kk-jj; i++ {
+		V[I[jj+i]] = kk - 1
+	}
+	if jj == kk-1 {
+		I[jj] = -1
+	}
+
+	if start+length > kk {
+		split(I, V, kk, start+length-kk, h)
+	}
+}
@@ -0,0 +1,110 @@
+package hotupdater
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// DeltaDownloader 优先使用增量补丁重建新版本的下载实现
+// 先下载补丁并应用到当前可执行文件，在 UpdatePath 中生成新版本；
+// 补丁下载、应用或校验任一步失败时，自动改为下载完整更新包。
+type DeltaDownloader struct {
+	Patch    *HTTPDownloader // 补丁下载器
+	Full     *HTTPDownloader // 完整更新包下载器，生成的新版本也写到它的 Path()
+	BasePath string          // 应用补丁的旧文件，通常为当前可执行文件
+	SHA256   string          // 新版本期望的 SHA-256，为空时只依赖补丁头中的摘要
+
+	PatchSHA256 string // 补丁文件期望的 SHA-256，为空不校验
+	PatchSize   int64  // 补丁文件期望的大小，为 0 不校验
+	Logger      Logger // 日志接口，可为空
+
+	sum  string
+	size int64
+}
+
+// NewDeltaDownloader 创建增量下载器，BasePath 默认为当前可执行文件
+func NewDeltaDownloader(patchURL, fullURL, updatePath string) *DeltaDownloader {
+	exe, _ := os.Executable()
+	full := NewHTTPDownloader(fullURL, updatePath)
+	patch := NewHTTPDownloader(patchURL, updatePath)
+	patch.FileName = filepath.Base(full.Path()) + ".patch"
+	return &DeltaDownloader{
+		Patch:    patch,
+		Full:     full,
+		BasePath: exe,
+	}
+}
+
+// Path 返回新版本的文件路径
+func (d *DeltaDownloader) Path() string {
+	return d.Full.Path()
+}
+
+// Execute 执行下载，实现 DownloadImplementation 接口
+func (d *DeltaDownloader) Execute(ctx context.Context, onProgress func(current, total int64, speed float64)) error {
+	d.sum, d.size = "", 0
+
+	err := d.applyPatch(ctx, onProgress)
+	if err == nil {
+		return nil
+	}
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	d.log("增量更新失败，改为下载完整更新包: %v", err)
+	if err := d.Full.Execute(ctx, onProgress); err != nil {
+		return err
+	}
+	d.sum, d.size = d.Full.Digest()
+	return nil
+}
+
+// Digest 返回新版本的 SHA-256 和大小，实现 Digester 接口
+func (d *DeltaDownloader) Digest() (string, int64) {
+	return d.sum, d.size
+}
+
+// applyPatch 下载补丁并重建新版本
+func (d *DeltaDownloader) applyPatch(ctx context.Context, onProgress func(current, total int64, speed float64)) error {
+	if d.Patch == nil || d.Patch.URL == "" {
+		return fmt.Errorf("没有可用的补丁")
+	}
+
+	if err := d.Patch.Execute(ctx, onProgress); err != nil {
+		return fmt.Errorf("下载补丁失败: %w", err)
+	}
+	patchPath := d.Patch.Path()
+	defer os.Remove(patchPath)
+
+	patchSum, patchSize := d.Patch.Digest()
+	if err := checkDigest(patchPath, d.PatchSHA256, d.PatchSize, patchSum, patchSize); err != nil {
+		return err
+	}
+
+	d.log("正在应用补丁: %s -> %s", d.BasePath, d.Path())
+	if err := ApplyPatch(d.BasePath, patchPath, d.Path()); err != nil {
+		return fmt.Errorf("应用补丁失败: %w", err)
+	}
+
+	sum, size, err := FileSHA256(d.Path())
+	if err != nil {
+		return fmt.Errorf("计算新版本摘要失败: %w", err)
+	}
+	if err := checkDigest(d.Path(), d.SHA256, 0, sum, size); err != nil {
+		os.Remove(d.Path())
+		return err
+	}
+
+	d.sum, d.size = sum, size
+	d.log("增量更新完成")
+	return nil
+}
+
+func (d *DeltaDownloader) log(format string, args ...interface{}) {
+	if d.Logger != nil {
+		d.Logger.Logf(format, args...)
+	}
+}
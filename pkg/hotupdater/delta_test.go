@@ -0,0 +1,113 @@
+package hotupdater
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/binary"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestPatchRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	oldData := bytes.Repeat([]byte("hotupdater old version "), 200)
+	newData := append(bytes.Repeat([]byte("hotupdater new version "), 150), []byte("appended tail")...)
+
+	oldPath := filepath.Join(dir, "app.old")
+	newPath := filepath.Join(dir, "app.new")
+	patchPath := filepath.Join(dir, "app.patch")
+	outPath := filepath.Join(dir, "app.out")
+	if err := os.WriteFile(oldPath, oldData, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(newPath, newData, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := CreatePatch(oldPath, newPath, patchPath); err != nil {
+		t.Fatalf("CreatePatch: %v", err)
+	}
+	if err := ApplyPatch(oldPath, patchPath, outPath); err != nil {
+		t.Fatalf("ApplyPatch: %v", err)
+	}
+	got, err := os.ReadFile(outPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, newData) {
+		t.Fatal("补丁生成的文件与新文件不一致")
+	}
+}
+
+// writeRawPatch 按补丁格式写入任意控制块，用于构造损坏或恶意的补丁
+func writeRawPatch(t *testing.T, path string, oldData []byte, newSize int64, body func(w *bytes.Buffer)) {
+	t.Helper()
+	var buf bytes.Buffer
+	buf.WriteString(deltaMagic)
+	header := PatchHeader{OldSHA256: sha256.Sum256(oldData), NewSize: newSize}
+	if err := binary.Write(&buf, binary.LittleEndian, header); err != nil {
+		t.Fatal(err)
+	}
+	var raw bytes.Buffer
+	body(&raw)
+	gw := gzip.NewWriter(&buf)
+	gw.Write(raw.Bytes())
+	gw.Close()
+	if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestApplyPatchHostile(t *testing.T) {
+	dir := t.TempDir()
+	oldData := []byte("old")
+	oldPath := filepath.Join(dir, "app.old")
+	if err := os.WriteFile(oldPath, oldData, 0644); err != nil {
+		t.Fatal(err)
+	}
+	ctrl := func(w *bytes.Buffer, x, y, z int64) {
+		binary.Write(w, binary.LittleEndian, [3]int64{x, y, z})
+	}
+
+	cases := []struct {
+		name    string
+		newSize int64
+		body    func(w *bytes.Buffer)
+	}{
+		{"差分长度溢出", 2, func(w *bytes.Buffer) {
+			ctrl(w, 1, 0, 0)
+			w.WriteByte(0)
+			ctrl(w, math.MaxInt64, 0, 0)
+		}},
+		{"新增长度溢出", 2, func(w *bytes.Buffer) {
+			ctrl(w, 1, 0, 0)
+			w.WriteByte(0)
+			ctrl(w, 0, math.MaxInt64, 0)
+		}},
+		{"负数控制值", 2, func(w *bytes.Buffer) {
+			ctrl(w, -1, 0, 0)
+		}},
+		{"数据不足", 8, func(w *bytes.Buffer) {
+			ctrl(w, 8, 0, 0)
+			w.WriteString("ab")
+		}},
+		{"新文件过大", math.MaxInt64, func(w *bytes.Buffer) {}},
+		{"新文件大小为负", -1, func(w *bytes.Buffer) {}},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			patchPath := filepath.Join(dir, "bad.patch")
+			outPath := filepath.Join(dir, "app.out")
+			writeRawPatch(t, patchPath, oldData, c.newSize, c.body)
+			if err := ApplyPatch(oldPath, patchPath, outPath); err == nil {
+				t.Fatal("损坏的补丁应返回错误")
+			}
+			if _, err := os.Stat(outPath); err == nil {
+				t.Fatal("失败时不应生成新文件")
+			}
+		})
+	}
+}
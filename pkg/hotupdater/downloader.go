@@ -2,7 +2,6 @@ package hotupdater
 
 import (
 	"context"
-	"errors"
 )
 
 // DownloadImplementation 实际下载实现接口
@@ -30,7 +29,7 @@ func NewDownloader(ctx context.Context, config Config, impl DownloadImplementati
 // Execute 执行下载
 func (d *Downloader) Execute() error {
 	if d.downloadImpl == nil {
-		return errors.New("no download implementation provided")
+		return newUpdateError(ErrDownload, PhaseDownload, d.config.localizer().newError(MsgErrNoDownloadImpl))
 	}
 
 	// 发送下载开始进度
@@ -42,7 +41,22 @@ func (d *Downloader) Execute() error {
 	})
 
 	if err != nil {
-		return err
+		if ctxErr := d.ctx.Err(); ctxErr != nil {
+			return newUpdateError(ErrCancelled, PhaseDownload, d.config.localizer().wrap(MsgErrCancelled, ctxErr))
+		}
+		return newUpdateError(ErrDownload, PhaseDownload, d.config.localizer().wrap(MsgErrDownloadFailed, err))
+	}
+
+	// 下载实现支持边下载边计算摘要时，立即校验
+	if digester, ok := d.downloadImpl.(Digester); ok && d.config.hasIntegrityCheck() {
+		name := "下载内容"
+		if p, ok := d.downloadImpl.(interface{ Path() string }); ok {
+			name = p.Path()
+		}
+		sum, size := digester.Digest()
+		if err := checkDigest(name, d.config.PackageSHA256, d.config.PackageSize, sum, size); err != nil {
+			return newUpdateError(ErrIntegrity, PhaseDownload, err)
+		}
 	}
 
 	// 发送下载完成进度
@@ -57,14 +71,19 @@ func (d *Downloader) emitProgress(current, total int64, speed float64) {
 	}
 
 	// 计算在下载阶段的总体进度
-	percentage := CalculateProgress(PhaseDownload, current, total)
+	percentage := d.config.ProgressModel.CalculateProgress(PhaseDownload, current, total)
 
+	l := d.config.localizer()
 	d.config.EventEmitter.EmitProgress(UpdateProgress{
 		Phase:      PhaseDownload,
 		Percentage: percentage,
 		Speed:      speed,
-		Message:    PhaseMessages[PhaseDownload],
-		Detail:     "正在下载更新包...",
+		Current:    current,
+		Total:      total,
+		MessageID:  PhaseMessageID(PhaseDownload),
+		Message:    d.config.ProgressModel.Message(l, PhaseDownload),
+		Detail:     l.T(MsgDownloadProgress),
+		DetailID:   MsgDownloadProgress,
 	})
 
 	// 记录日志
@@ -0,0 +1,127 @@
+package hotupdater
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrIntegrity 更新包完整性校验失败
+var ErrIntegrity = errors.New("更新包完整性校验失败")
+
+// ErrDowngrade 目标版本不高于当前版本
+var ErrDowngrade = errors.New("目标版本不高于当前版本")
+
+// 更新失败的类别，用 errors.Is 判断，详细信息用 errors.As 取出 *UpdateError
+var (
+	ErrDownload       = errors.New("下载失败")        // 下载实现返回错误
+	ErrHelperMissing  = errors.New("更新助手不存在")     // macOS 更新助手不存在或无法启动
+	ErrScriptFailed   = errors.New("更新脚本执行失败")    // Lua 脚本不存在、出错或更新助手异常退出
+	ErrInstallFailed  = errors.New("安装失败")        // 流水线步骤或安装前检查失败
+	ErrRolledBack     = errors.New("已回滚到更新前的状态")  // 更新失败或取消后已恢复到更新前的状态
+	ErrRollbackFailed = errors.New("回滚失败")        // 恢复旧版本失败，需要人工处理
+	ErrCancelled      = errors.New("更新已取消")       // ctx 被取消，Err 链中包含 context.Canceled 或 context.DeadlineExceeded
+	ErrHookVetoed     = errors.New("生命周期钩子否决了更新") // Config.Hooks 中的钩子返回错误
+	ErrUnhealthy      = errors.New("新版本未确认运行正常")  // 新版本没有在 Config.HealthWindow 内调用 ConfirmHealthy
+	ErrCrashLoop      = errors.New("新版本连续启动失败")   // 新版本连续启动超过 Config.CrashLoopLimit 次仍未 MarkStable
+	ErrBackupCorrupt  = errors.New("备份已损坏")       // BackupManager.Verify 发现备份与说明文件不一致或无法完整读取
+)
+
+// IntegrityError 更新包摘要或大小与期望值不一致
+type IntegrityError struct {
+	Path           string // 被校验的文件
+	ExpectedSHA256 string // 期望的 SHA-256
+	ActualSHA256   string // 实际的 SHA-256
+	ExpectedSize   int64  // 期望的大小，0 表示不校验
+	ActualSize     int64  // 实际的大小
+}
+
+func (e *IntegrityError) Error() string {
+	if e.ExpectedSize > 0 && e.ExpectedSize != e.ActualSize {
+		return fmt.Sprintf("%v: %s 大小不匹配，期望 %d 字节，实际 %d 字节", ErrIntegrity, e.Path, e.ExpectedSize, e.ActualSize)
+	}
+	return fmt.Sprintf("%v: %s SHA-256 不匹配，期望 %s，实际 %s", ErrIntegrity, e.Path, e.ExpectedSHA256, e.ActualSHA256)
+}
+
+// Is 使 errors.Is(err, ErrIntegrity) 成立
+func (e *IntegrityError) Is(target error) bool {
+	return target == ErrIntegrity
+}
+
+// LocalizedError 按 Config.Locale 翻译的错误，ID 为稳定的消息 ID
+// 可用 errors.As 取出 ID 由前端自行翻译，Err 为原始错误。
+type LocalizedError struct {
+	ID   MessageID
+	Text string
+	Err  error
+}
+
+func (e *LocalizedError) Error() string {
+	return e.Text
+}
+
+func (e *LocalizedError) Unwrap() error {
+	return e.Err
+}
+
+// UpdateError 更新失败的详细信息
+//
+//	var ue *hotupdater.UpdateError
+//	if errors.As(err, &ue) {
+//		log.Printf("阶段 %s 失败: %v", ue.Phase, ue.Err)
+//	}
+//	if errors.Is(err, hotupdater.ErrRollbackFailed) {
+//		// 提示用户使用恢复助手
+//	}
+//
+// errors.Is 可以匹配 Kind、回滚结果（ErrRolledBack 或 ErrRollbackFailed）以及 Err 链中的任何错误。
+type UpdateError struct {
+	Kind        error       // 错误类别：ErrDownload、ErrIntegrity、ErrHelperMissing、ErrScriptFailed、ErrInstallFailed、ErrDowngrade、ErrCancelled、ErrHookVetoed、ErrUnhealthy 或 ErrCrashLoop
+	Phase       UpdatePhase // 失败时所处的阶段
+	Err         error       // 原始错误
+	RolledBack  bool        // 已回滚到更新前的状态
+	RollbackErr error       // 回滚失败的原因，非空时需要人工恢复
+}
+
+func (e *UpdateError) Error() string {
+	if e.Err != nil {
+		return e.Err.Error()
+	}
+	return e.Kind.Error()
+}
+
+// Unwrap 返回 Kind、回滚结果和原始错误
+func (e *UpdateError) Unwrap() []error {
+	errs := []error{e.Kind}
+	switch {
+	case e.RollbackErr != nil:
+		errs = append(errs, ErrRollbackFailed, e.RollbackErr)
+	case e.RolledBack:
+		errs = append(errs, ErrRolledBack)
+	}
+	if e.Err != nil {
+		errs = append(errs, e.Err)
+	}
+	return errs
+}
+
+// newUpdateError 创建更新错误，err 已经是 *UpdateError 时原样返回
+func newUpdateError(kind error, phase UpdatePhase, err error) error {
+	var ue *UpdateError
+	if errors.As(err, &ue) {
+		return err
+	}
+	return &UpdateError{Kind: kind, Phase: phase, Err: err}
+}
+
+// ErrorCode 返回 err 对应的消息协议错误码，更新助手据此向应用上报取消和回滚结果
+func ErrorCode(err error) string {
+	switch {
+	case errors.Is(err, ErrRollbackFailed):
+		return ErrorCodeRollbackFailed
+	case errors.Is(err, ErrRolledBack):
+		return ErrorCodeRolledBack
+	case errors.Is(err, ErrCancelled):
+		return ErrorCodeCancelled
+	}
+	return ErrorCodeUpdateFailed
+}
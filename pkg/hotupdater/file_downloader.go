@@ -0,0 +1,341 @@
+package hotupdater
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// FileDownloader 文件级差异下载实现，适用于 .app 包和安装目录
+// 根据新版本的文件清单对比已安装目录，只下载新增和变化的文件，
+// 未变化的文件从已安装目录复制到暂存目录，已删除的文件不会进入暂存目录。
+// 下载完成后暂存目录即为完整的新版本，可直接传给 FastUpdater.Update 进行替换。
+type FileDownloader struct {
+	ManifestURL string              // 文件清单地址
+	InstallRoot string              // 已安装目录，如 /Applications/MyApp.app
+	Dir         string              // 暂存目录的父目录，通常为 Config.UpdatePath
+	PublicKeys  []ed25519.PublicKey // 非空时校验文件清单签名（清单地址 + ".sig"），配置了 Config.PublicKeys 时必须设置
+	Client      *http.Client        // HTTP 客户端，为空时使用 http.DefaultClient
+	Logger      Logger              // 日志接口，可为空
+	Locale      string              // 校验错误信息的语言，为空时使用默认语言
+
+	manifest *FileManifest
+	root     string // 解析符号链接后的暂存目录，写入前据此检查路径
+	signed   bool   // 最近一次下载的文件清单已通过签名校验
+}
+
+// NewFileDownloader 创建文件级差异下载器
+func NewFileDownloader(manifestURL, installRoot, updatePath string) *FileDownloader {
+	return &FileDownloader{
+		ManifestURL: manifestURL,
+		InstallRoot: installRoot,
+		Dir:         updatePath,
+	}
+}
+
+// Path 返回暂存目录，名称与已安装目录相同
+func (d *FileDownloader) Path() string {
+	return filepath.Join(d.Dir, "staging", filepath.Base(d.InstallRoot))
+}
+
+// Execute 执行下载，实现 DownloadImplementation 接口
+func (d *FileDownloader) Execute(ctx context.Context, onProgress func(current, total int64, speed float64)) error {
+	d.manifest, d.signed = nil, false
+	manifest, err := d.fetchManifest(ctx)
+	if err != nil {
+		return err
+	}
+	d.manifest = manifest
+	d.signed = len(d.PublicKeys) > 0
+
+	d.log("正在对比已安装文件: %s", d.InstallRoot)
+	diff, err := DiffTree(d.InstallRoot, manifest)
+	if err != nil {
+		return fmt.Errorf("扫描已安装文件失败: %w", err)
+	}
+	d.log("文件差异: 新增 %d，变化 %d，删除 %d，未变化 %d，需下载 %d 字节",
+		len(diff.Added), len(diff.Changed), len(diff.Removed), len(diff.Unchanged), diff.DownloadSize())
+
+	staging := d.Path()
+	if err := os.RemoveAll(staging); err != nil {
+		return fmt.Errorf("清理暂存目录失败: %w", err)
+	}
+	if d.root, err = resolveRoot(staging); err != nil {
+		return fmt.Errorf("创建暂存目录失败: %w", err)
+	}
+
+	// 未变化的文件直接从已安装目录取
+	for _, f := range diff.Unchanged {
+		if err := d.stageLocal(f); err != nil {
+			return err
+		}
+	}
+
+	// 新增和变化的文件从服务器下载
+	total := diff.DownloadSize()
+	var done int64
+	start := time.Now()
+	for _, list := range [][]FileEntry{diff.Added, diff.Changed} {
+		for _, f := range list {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			if !f.Mode.IsRegular() {
+				if err := d.stageSpecial(f); err != nil {
+					return err
+				}
+				continue
+			}
+
+			base := done
+			err := d.stageRemote(ctx, f, func(current, _ int64, _ float64) {
+				if onProgress != nil {
+					speed := 0.0
+					if elapsed := time.Since(start).Seconds(); elapsed > 0 {
+						speed = float64(base+current) / elapsed / 1024 / 1024
+					}
+					onProgress(base+current, total, speed)
+				}
+			})
+			if err != nil {
+				return err
+			}
+			done += f.Size
+		}
+	}
+
+	// 补齐目录权限（写入文件时可能以默认权限创建）
+	for _, f := range manifest.Files {
+		if f.Mode.IsDir() {
+			os.Chmod(d.stagedPath(f), f.Mode.Perm())
+		}
+	}
+	return nil
+}
+
+// ManifestVerified 返回最近一次下载的文件清单是否通过了签名校验，实现 ManifestVerifier 接口
+func (d *FileDownloader) ManifestVerified() bool {
+	return d.signed
+}
+
+// VerifyPackage 按文件清单重新校验暂存目录，实现 PackageVerifier 接口
+// 每个条目的类型必须与清单一致：普通文件比较大小和 SHA-256，符号链接比较链接目标；
+// 清单之外的文件（如被放入的符号链接、残留的临时文件）同样视为校验失败。
+func (d *FileDownloader) VerifyPackage(root string) error {
+	l := NewLocalizer(d.Locale)
+	if d.manifest == nil {
+		return l.newError(MsgErrManifestNotLoaded)
+	}
+
+	listed := make(map[string]bool, len(d.manifest.Files))
+	for _, f := range d.manifest.Files {
+		listed[f.Path] = true
+		// 手写的清单可能省略上级目录
+		for dir := path.Dir(f.Path); dir != "." && dir != "/"; dir = path.Dir(dir) {
+			if _, ok := listed[dir]; !ok {
+				listed[dir] = false
+			}
+		}
+
+		target := filepath.Join(root, filepath.FromSlash(f.Path))
+		info, err := os.Lstat(target)
+		if err != nil {
+			return l.errorOf(MsgErrFileMissing, ErrIntegrity, f.Path)
+		}
+		if info.Mode().Type() != f.Mode.Type() {
+			return l.errorOf(MsgErrFileType, ErrIntegrity, f.Path, info.Mode().Type(), f.Mode.Type())
+		}
+		switch {
+		case f.Mode&os.ModeSymlink != 0:
+			link, err := os.Readlink(target)
+			if err != nil {
+				return l.errorOf(MsgErrSymlinkRead, errors.Join(ErrIntegrity, err), f.Path, err)
+			}
+			if link != f.Link {
+				return l.errorOf(MsgErrSymlinkTarget, ErrIntegrity, f.Path, link, f.Link)
+			}
+		case f.Mode.IsRegular():
+			if err := VerifyPackage(target, f.SHA256, f.Size); err != nil {
+				return err
+			}
+		}
+	}
+
+	return filepath.Walk(root, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if p == root {
+			return nil
+		}
+		rel, err := filepath.Rel(root, p)
+		if err != nil {
+			return err
+		}
+		if _, ok := listed[filepath.ToSlash(rel)]; !ok {
+			return l.errorOf(MsgErrUnlistedFile, ErrIntegrity, filepath.ToSlash(rel))
+		}
+		return nil
+	})
+}
+
+// fetchManifest 下载并解析文件清单
+func (d *FileDownloader) fetchManifest(ctx context.Context) (*FileManifest, error) {
+	data, err := d.get(ctx, d.ManifestURL)
+	if err != nil {
+		return nil, fmt.Errorf("获取文件清单失败: %w", err)
+	}
+
+	if len(d.PublicKeys) > 0 {
+		sig, err := d.get(ctx, d.ManifestURL+SignatureSuffix)
+		if err != nil {
+			return nil, fmt.Errorf("%w: 获取文件清单签名失败: %w", ErrSignature, err)
+		}
+		if err := VerifySignature(data, string(sig), d.PublicKeys); err != nil {
+			return nil, fmt.Errorf("文件清单%w", err)
+		}
+	}
+
+	var manifest FileManifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("解析文件清单失败: %w", err)
+	}
+	for _, f := range manifest.Files {
+		if _, err := safeJoin(d.Path(), f.Path); err != nil {
+			return nil, err
+		}
+	}
+	if manifest.BaseURL == "" {
+		// 默认与清单位于同一目录
+		manifest.BaseURL = d.ManifestURL[:strings.LastIndex(d.ManifestURL, "/")+1]
+	}
+	return &manifest, nil
+}
+
+// stageLocal 将已安装目录中未变化的条目放入暂存目录
+func (d *FileDownloader) stageLocal(f FileEntry) error {
+	if !f.Mode.IsRegular() {
+		return d.stageSpecial(f)
+	}
+
+	// 复制而不是硬链接，否则替换后对已安装文件的原地修改会同时改变新版本
+	src := filepath.Join(d.InstallRoot, filepath.FromSlash(f.Path))
+	dst := d.stagedPath(f)
+	if err := d.prepare(dst); err != nil {
+		return err
+	}
+	if err := copyFile(src, dst); err != nil {
+		return fmt.Errorf("暂存文件失败 %s: %w", f.Path, err)
+	}
+	return nil
+}
+
+// stageSpecial 在暂存目录中创建目录或符号链接
+func (d *FileDownloader) stageSpecial(f FileEntry) error {
+	dst := d.stagedPath(f)
+	if err := d.prepare(dst); err != nil {
+		return err
+	}
+	switch {
+	case f.Mode.IsDir():
+		return os.MkdirAll(dst, 0755)
+	case f.Mode&os.ModeSymlink != 0:
+		os.Remove(dst)
+		return os.Symlink(f.Link, dst)
+	}
+	return nil
+}
+
+// stageRemote 下载单个文件到暂存目录并校验
+func (d *FileDownloader) stageRemote(ctx context.Context, f FileEntry, onProgress func(current, total int64, speed float64)) error {
+	dst := d.stagedPath(f)
+	if err := d.prepare(dst); err != nil {
+		return err
+	}
+	downloader := &HTTPDownloader{
+		URL:      d.fileURL(f.Path),
+		Dir:      filepath.Dir(dst),
+		FileName: filepath.Base(dst),
+		Client:   d.Client,
+	}
+	if err := downloader.Execute(ctx, onProgress); err != nil {
+		return fmt.Errorf("下载文件失败 %s: %w", f.Path, err)
+	}
+
+	sum, size := downloader.Digest()
+	if err := checkDigest(dst, f.SHA256, f.Size, sum, size); err != nil {
+		return err
+	}
+	return os.Chmod(dst, f.Mode.Perm())
+}
+
+// prepare 写入 dst 之前确认其上级目录解析符号链接后仍在暂存目录中，并删除同名的符号链接
+// 清单可以先声明指向外部的符号链接，再声明链接下的文件，只检查条目名无法发现。
+func (d *FileDownloader) prepare(dst string) error {
+	if err := checkWithin(d.root, filepath.Dir(dst)); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+	return removeSymlink(dst)
+}
+
+// stagedPath 返回条目在暂存目录中的路径
+func (d *FileDownloader) stagedPath(f FileEntry) string {
+	return filepath.Join(d.Path(), filepath.FromSlash(f.Path))
+}
+
+// fileURL 拼接单个文件的下载地址
+func (d *FileDownloader) fileURL(path string) string {
+	parts := strings.Split(path, "/")
+	for i, p := range parts {
+		parts[i] = url.PathEscape(p)
+	}
+	return strings.TrimRight(d.manifest.BaseURL, "/") + "/" + strings.Join(parts, "/")
+}
+
+// get 请求 URL 并返回响应内容
+func (d *FileDownloader) get(ctx context.Context, rawURL string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	client := d.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("HTTP 状态码: %d", resp.StatusCode)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, manifestMaxSize*16))
+}
+
+func (d *FileDownloader) log(format string, args ...interface{}) {
+	if d.Logger != nil {
+		d.Logger.Logf(format, args...)
+	}
+}
+
+// defaultInstallRoot 返回当前应用的安装目录
+func defaultInstallRoot() string {
+	exe, _ := os.Executable()
+	return installRoot(exe)
+}
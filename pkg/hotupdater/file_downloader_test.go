@@ -0,0 +1,177 @@
+package hotupdater
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestFileDownloaderPrepareRejectsSymlinkEscape(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("创建符号链接需要额外权限")
+	}
+	dir := t.TempDir()
+	outside := filepath.Join(dir, "outside")
+	if err := os.MkdirAll(outside, 0755); err != nil {
+		t.Fatal(err)
+	}
+	d := &FileDownloader{Dir: dir, InstallRoot: filepath.Join(dir, "App.app")}
+	staging := d.Path()
+	var err error
+	if d.root, err = resolveRoot(staging); err != nil {
+		t.Fatal(err)
+	}
+
+	// 清单中先出现指向外部的符号链接条目，再出现链接下的文件
+	if err := d.stageSpecial(FileEntry{Path: "lnk", Mode: os.ModeSymlink | 0777, Link: outside}); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.prepare(filepath.Join(staging, "lnk", "evil")); err == nil {
+		t.Fatal("经符号链接写到暂存目录之外时应返回错误")
+	}
+	if err := d.prepare(filepath.Join(staging, "sub", "ok")); err != nil {
+		t.Fatalf("暂存目录内的路径应允许写入: %v", err)
+	}
+}
+
+func TestFileDownloaderVerifyPackage(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("创建符号链接需要额外权限")
+	}
+	// build 创建与清单一致的暂存目录，再由 tamper 修改
+	build := func(t *testing.T, tamper func(root string)) (*FileDownloader, string) {
+		t.Helper()
+		root := filepath.Join(t.TempDir(), "App.app")
+		writeTree(t, root, map[string]string{"Contents/MacOS/app": "binary"})
+		if err := os.Symlink("MacOS/app", filepath.Join(root, "Contents", "current")); err != nil {
+			t.Fatal(err)
+		}
+		manifest, err := BuildFileManifest(root, "1.0.0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if tamper != nil {
+			tamper(root)
+		}
+		return &FileDownloader{manifest: manifest}, root
+	}
+
+	cases := []struct {
+		name   string
+		tamper func(root string)
+	}{
+		{"普通文件被换成符号链接", func(root string) {
+			os.Remove(filepath.Join(root, "Contents", "MacOS", "app"))
+			os.Symlink("/etc/passwd", filepath.Join(root, "Contents", "MacOS", "app"))
+		}},
+		{"符号链接目标不同", func(root string) {
+			os.Remove(filepath.Join(root, "Contents", "current"))
+			os.Symlink("/bin/sh", filepath.Join(root, "Contents", "current"))
+		}},
+		{"目录被换成文件", func(root string) {
+			os.RemoveAll(filepath.Join(root, "Contents", "MacOS"))
+			os.WriteFile(filepath.Join(root, "Contents", "MacOS"), []byte("x"), 0644)
+		}},
+		{"清单之外的文件", func(root string) {
+			os.WriteFile(filepath.Join(root, "Contents", "extra"), []byte("x"), 0644)
+		}},
+		{"清单之外的符号链接", func(root string) {
+			os.Symlink("/", filepath.Join(root, "Contents", "escape"))
+		}},
+	}
+
+	t.Run("一致", func(t *testing.T) {
+		d, root := build(t, nil)
+		if err := d.VerifyPackage(root); err != nil {
+			t.Fatalf("与清单一致时应通过: %v", err)
+		}
+	})
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			d, root := build(t, c.tamper)
+			if err := d.VerifyPackage(root); !errors.Is(err, ErrIntegrity) {
+				t.Fatalf("应返回 ErrIntegrity，实际 %v", err)
+			}
+		})
+	}
+
+	t.Run("按 Locale 返回错误信息", func(t *testing.T) {
+		d, root := build(t, cases[3].tamper)
+		d.Locale = "en"
+		err := d.VerifyPackage(root)
+		var le *LocalizedError
+		if !errors.As(err, &le) || le.ID != MsgErrUnlistedFile || err.Error() != "file not in the manifest: Contents/extra" {
+			t.Fatalf("错误信息为 %v", err)
+		}
+	})
+}
+
+// newManifestServer 提供 root 目录的文件清单、清单签名和文件内容
+func newManifestServer(t *testing.T, root string, priv ed25519.PrivateKey) *httptest.Server {
+	t.Helper()
+	manifest, err := BuildFileManifest(root, "1.0.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := json.Marshal(manifest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/files.json", func(w http.ResponseWriter, r *http.Request) { w.Write(data) })
+	mux.HandleFunc("/files.json.sig", func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, SignBytes(data, priv))
+	})
+	mux.Handle("/", http.FileServer(http.Dir(root)))
+	s := httptest.NewServer(mux)
+	t.Cleanup(s.Close)
+	return s
+}
+
+func TestFileDownloaderManifestVerified(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	release := filepath.Join(dir, "release")
+	writeTree(t, release, map[string]string{"bin": "new"})
+	writeTree(t, filepath.Join(dir, "installed"), map[string]string{"bin": "old"})
+	s := newManifestServer(t, release, priv)
+	config := Config{PublicKeys: []ed25519.PublicKey{pub}, ProgressModel: DefaultProgressModel()}
+
+	// 下载器没有设置公钥：不会被 Config 改写，也不视为校验过签名
+	unsigned := NewFileDownloader(s.URL+"/files.json", filepath.Join(dir, "installed"), filepath.Join(dir, "update"))
+	if err := NewDownloader(context.Background(), config, unsigned).Execute(); err != nil {
+		t.Fatal(err)
+	}
+	if unsigned.PublicKeys != nil || unsigned.ManifestVerified() {
+		t.Fatal("下载器的公钥不应被 Config 改写")
+	}
+	f := &FastUpdater{config: config}
+	f.config.DownloadImpl = unsigned
+	if err := f.verifySignature(unsigned.Path()); !errors.Is(err, ErrSignature) {
+		t.Fatalf("清单未校验签名时应返回 ErrSignature，实际 %v", err)
+	}
+
+	signed := NewFileDownloader(s.URL+"/files.json", filepath.Join(dir, "installed"), filepath.Join(dir, "update"))
+	signed.PublicKeys = config.PublicKeys
+	if err := NewDownloader(context.Background(), config, signed).Execute(); err != nil {
+		t.Fatal(err)
+	}
+	if !signed.ManifestVerified() {
+		t.Fatal("清单签名校验通过后应报告已校验")
+	}
+	f.config.DownloadImpl = signed
+	if err := f.verifySignature(signed.Path()); err != nil {
+		t.Fatalf("清单签名已校验时应通过: %v", err)
+	}
+}
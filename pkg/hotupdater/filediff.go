@@ -0,0 +1,180 @@
+package hotupdater
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// FileManifest 文件级更新清单，描述一个版本安装目录（如 .app 包）中的全部文件
+//
+//	{
+//	  "version": "1.0.5",
+//	  "base_url": "https://example.com/releases/1.0.5/MyApp.app",
+//	  "files": [
+//	    {"path": "Contents/MacOS/MyApp", "size": 52428800, "sha256": "...", "mode": 493},
+//	    {"path": "Contents/Frameworks/Foo.framework/Foo", "mode": 134218221, "link": "Versions/A/Foo"}
+//	  ]
+//	}
+//
+// 文件通过 base_url + "/" + path 下载。
+type FileManifest struct {
+	Version string      `json:"version"`  // 版本号
+	BaseURL string      `json:"base_url"` // 文件下载地址前缀
+	Files   []FileEntry `json:"files"`    // 文件列表，按路径排序
+}
+
+// FileEntry 单个文件的描述
+type FileEntry struct {
+	Path   string      `json:"path"`             // 相对安装目录的路径，使用 / 分隔
+	Size   int64       `json:"size"`             // 文件大小
+	SHA256 string      `json:"sha256,omitempty"` // 文件 SHA-256，目录和符号链接为空
+	Mode   os.FileMode `json:"mode"`             // 文件类型和权限
+	Link   string      `json:"link,omitempty"`   // 符号链接目标
+}
+
+// FileDiff 已安装目录与新版本清单的差异
+type FileDiff struct {
+	Added     []FileEntry // 新增的文件
+	Changed   []FileEntry // 内容、权限或类型变化的文件
+	Removed   []FileEntry // 新版本中已删除的文件
+	Unchanged []FileEntry // 未变化的文件
+}
+
+// DownloadSize 需要下载的字节数
+func (d *FileDiff) DownloadSize() int64 {
+	var total int64
+	for _, list := range [][]FileEntry{d.Added, d.Changed} {
+		for _, f := range list {
+			if f.Mode.IsRegular() {
+				total += f.Size
+			}
+		}
+	}
+	return total
+}
+
+// BuildFileManifest 扫描目录生成文件清单，供发布流程调用
+func BuildFileManifest(root, version string) (*FileManifest, error) {
+	entries, err := scanTree(root, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &FileManifest{Version: version, Files: entries}, nil
+}
+
+// WriteFileManifest 将文件清单写入 JSON 文件
+func WriteFileManifest(m *FileManifest, path string) error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+// DiffTree 对比已安装目录和新版本清单
+// 只有大小一致的普通文件才会计算摘要，减少大包的扫描时间。
+func DiffTree(root string, remote *FileManifest) (*FileDiff, error) {
+	want := make(map[string]FileEntry, len(remote.Files))
+	for _, f := range remote.Files {
+		want[f.Path] = f
+	}
+
+	local, err := scanTree(root, func(rel string, info os.FileInfo) bool {
+		f, ok := want[rel]
+		return ok && f.Mode.IsRegular() && f.Size == info.Size()
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	have := make(map[string]FileEntry, len(local))
+	for _, f := range local {
+		have[f.Path] = f
+	}
+
+	diff := &FileDiff{}
+	for _, f := range remote.Files {
+		old, ok := have[f.Path]
+		switch {
+		case !ok:
+			diff.Added = append(diff.Added, f)
+		case sameEntry(old, f):
+			diff.Unchanged = append(diff.Unchanged, f)
+		default:
+			diff.Changed = append(diff.Changed, f)
+		}
+	}
+	for _, f := range local {
+		if _, ok := want[f.Path]; !ok {
+			diff.Removed = append(diff.Removed, f)
+		}
+	}
+	return diff, nil
+}
+
+// sameEntry 判断两个条目是否一致
+func sameEntry(a, b FileEntry) bool {
+	if a.Mode != b.Mode {
+		return false
+	}
+	switch {
+	case a.Mode.IsDir():
+		return true
+	case a.Mode&os.ModeSymlink != 0:
+		return a.Link == b.Link
+	default:
+		return a.Size == b.Size && a.SHA256 != "" && a.SHA256 == b.SHA256
+	}
+}
+
+// scanTree 扫描目录，hashFilter 为 nil 时计算所有普通文件的摘要，否则只计算返回 true 的文件
+func scanTree(root string, hashFilter func(rel string, info os.FileInfo) bool) ([]FileEntry, error) {
+	var entries []FileEntry
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if path == root {
+			return nil
+		}
+
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		entry := FileEntry{
+			Path: filepath.ToSlash(rel),
+			Mode: info.Mode(),
+		}
+
+		switch {
+		case info.IsDir():
+		case info.Mode()&os.ModeSymlink != 0:
+			if entry.Link, err = os.Readlink(path); err != nil {
+				return err
+			}
+		case info.Mode().IsRegular():
+			entry.Size = info.Size()
+			if hashFilter == nil || hashFilter(entry.Path, info) {
+				if entry.SHA256, _, err = FileSHA256(path); err != nil {
+					return fmt.Errorf("计算文件摘要失败 %s: %w", path, err)
+				}
+			}
+		default:
+			// 忽略设备文件、管道等特殊文件
+			return nil
+		}
+
+		entries = append(entries, entry)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Path < entries[j].Path })
+	return entries, nil
+}
@@ -0,0 +1,483 @@
+package hotupdater
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// copyFile 复制单个文件，保留文件权限
+func copyFile(src, dst string) error {
+	srcFile, err := os.Open(src)
+	if err != nil {
+		return fmt.Errorf("打开源文件失败: %w", err)
+	}
+	defer srcFile.Close()
+
+	info, err := srcFile.Stat()
+	if err != nil {
+		return fmt.Errorf("读取源文件信息失败: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return fmt.Errorf("创建目标目录失败: %w", err)
+	}
+
+	dstFile, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, info.Mode().Perm())
+	if err != nil {
+		return fmt.Errorf("创建目标文件失败: %w", err)
+	}
+
+	if _, err := io.Copy(dstFile, srcFile); err != nil {
+		dstFile.Close()
+		return fmt.Errorf("复制文件内容失败: %w", err)
+	}
+	if err := dstFile.Close(); err != nil {
+		return fmt.Errorf("关闭目标文件失败: %w", err)
+	}
+	return os.Chmod(dst, info.Mode().Perm())
+}
+
+// copyDir 递归复制目录，保留文件权限和符号链接
+func copyDir(src, dst string) error {
+	return filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+
+		switch {
+		case info.IsDir():
+			return os.MkdirAll(target, info.Mode().Perm())
+		case info.Mode()&os.ModeSymlink != 0:
+			link, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+			os.Remove(target)
+			return os.Symlink(link, target)
+		default:
+			return copyFile(path, target)
+		}
+	})
+}
+
+// copyPath 根据源路径类型复制文件或目录
+func copyPath(src, dst string) error {
+	info, err := os.Stat(src)
+	if err != nil {
+		return err
+	}
+	if info.IsDir() {
+		return copyDir(src, dst)
+	}
+	return copyFile(src, dst)
+}
+
+// createTarGz 将 src 打包为 tar.gz
+// 归档内路径与 `tar -czf dst src` 一致（去掉开头的 / 和盘符），便于恢复时解压到根目录
+func createTarGz(src, dst string) error {
+	src, err := filepath.Abs(src)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return fmt.Errorf("创建备份目录失败: %w", err)
+	}
+
+	out, err := os.Create(dst)
+	if err != nil {
+		return fmt.Errorf("创建归档文件失败: %w", err)
+	}
+	defer out.Close()
+
+	gw := gzip.NewWriter(out)
+	tw := tar.NewWriter(gw)
+
+	walkErr := filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		link := ""
+		if info.Mode()&os.ModeSymlink != 0 {
+			if link, err = os.Readlink(path); err != nil {
+				return err
+			}
+		}
+
+		header, err := tar.FileInfoHeader(info, link)
+		if err != nil {
+			return err
+		}
+		header.Name = strings.TrimPrefix(filepath.ToSlash(strings.TrimPrefix(path, filepath.VolumeName(path))), "/")
+		if info.IsDir() {
+			header.Name += "/"
+		}
+
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		_, err = io.Copy(tw, f)
+		return err
+	})
+	if walkErr != nil {
+		return fmt.Errorf("写入归档失败: %w", walkErr)
+	}
+
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gw.Close()
+}
+
+// extractTarGz 将 tar.gz 解压到 dstRoot
+func extractTarGz(archive, dstRoot string) error {
+	f, err := os.Open(archive)
+	if err != nil {
+		return fmt.Errorf("打开归档文件失败: %w", err)
+	}
+	defer f.Close()
+
+	gr, err := gzip.NewReader(f)
+	if err != nil {
+		return fmt.Errorf("读取归档文件失败: %w", err)
+	}
+	defer gr.Close()
+
+	realRoot, err := resolveRoot(dstRoot)
+	if err != nil {
+		return err
+	}
+
+	tr := tar.NewReader(gr)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("读取归档条目失败: %w", err)
+		}
+
+		target, err := safeJoin(dstRoot, header.Name)
+		if err != nil {
+			return err
+		}
+		if err := checkWithin(realRoot, filepath.Dir(target)); err != nil {
+			return err
+		}
+
+		switch header.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(target, os.FileMode(header.Mode).Perm()); err != nil {
+				return err
+			}
+		case tar.TypeSymlink:
+			if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+				return err
+			}
+			os.Remove(target)
+			if err := os.Symlink(header.Linkname, target); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+				return err
+			}
+			if err := removeSymlink(target); err != nil {
+				return err
+			}
+			out, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, os.FileMode(header.Mode).Perm())
+			if err != nil {
+				return err
+			}
+			if _, err := io.Copy(out, tr); err != nil {
+				out.Close()
+				return err
+			}
+			if err := out.Close(); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// listTarGz 返回 tar.gz 中的全部条目名
+func listTarGz(archive string) ([]string, error) {
+	f, err := os.Open(archive)
+	if err != nil {
+		return nil, fmt.Errorf("打开归档文件失败: %w", err)
+	}
+	defer f.Close()
+
+	gr, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, fmt.Errorf("读取归档文件失败: %w", err)
+	}
+	defer gr.Close()
+
+	var names []string
+	tr := tar.NewReader(gr)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return names, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("读取归档条目失败: %w", err)
+		}
+		names = append(names, header.Name)
+	}
+}
+
+// firstTarGzEntry 返回 tar.gz 的第一个条目名，备份归档中即为被备份的路径
+func firstTarGzEntry(archive string) (string, error) {
+	f, err := os.Open(archive)
+	if err != nil {
+		return "", fmt.Errorf("打开归档文件失败: %w", err)
+	}
+	defer f.Close()
+
+	gr, err := gzip.NewReader(f)
+	if err != nil {
+		return "", fmt.Errorf("读取归档文件失败: %w", err)
+	}
+	defer gr.Close()
+
+	header, err := tar.NewReader(gr).Next()
+	if err != nil {
+		return "", fmt.Errorf("读取归档条目失败: %w", err)
+	}
+	return header.Name, nil
+}
+
+// createZip 将 src 打包为 zip，归档内以 src 的最后一级名称为顶层目录
+func createZip(src, dst string) error {
+	src, err := filepath.Abs(src)
+	if err != nil {
+		return err
+	}
+	base := filepath.Dir(src)
+
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return fmt.Errorf("创建目标目录失败: %w", err)
+	}
+
+	out, err := os.Create(dst)
+	if err != nil {
+		return fmt.Errorf("创建归档文件失败: %w", err)
+	}
+	defer out.Close()
+
+	zw := zip.NewWriter(out)
+	walkErr := filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(base, path)
+		if err != nil {
+			return err
+		}
+		header, err := zip.FileInfoHeader(info)
+		if err != nil {
+			return err
+		}
+		header.Name = filepath.ToSlash(rel)
+
+		switch {
+		case info.IsDir():
+			header.Name += "/"
+			_, err = zw.CreateHeader(header)
+			return err
+		case info.Mode()&os.ModeSymlink != 0:
+			// 符号链接以链接目标作为内容保存
+			link, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+			w, err := zw.CreateHeader(header)
+			if err != nil {
+				return err
+			}
+			_, err = io.WriteString(w, link)
+			return err
+		case info.Mode().IsRegular():
+			header.Method = zip.Deflate
+			w, err := zw.CreateHeader(header)
+			if err != nil {
+				return err
+			}
+			f, err := os.Open(path)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			_, err = io.Copy(w, f)
+			return err
+		}
+		return nil
+	})
+	if walkErr != nil {
+		return fmt.Errorf("写入归档失败: %w", walkErr)
+	}
+	return zw.Close()
+}
+
+// extractZip 将 zip 解压到 dstRoot
+func extractZip(archive, dstRoot string) error {
+	r, err := zip.OpenReader(archive)
+	if err != nil {
+		return fmt.Errorf("打开归档文件失败: %w", err)
+	}
+	defer r.Close()
+
+	realRoot, err := resolveRoot(dstRoot)
+	if err != nil {
+		return err
+	}
+
+	for _, f := range r.File {
+		target, err := safeJoin(dstRoot, f.Name)
+		if err != nil {
+			return err
+		}
+		if err := checkWithin(realRoot, filepath.Dir(target)); err != nil {
+			return err
+		}
+		if err := extractZipEntry(f, target); err != nil {
+			return fmt.Errorf("解压 %s 失败: %w", f.Name, err)
+		}
+	}
+	return nil
+}
+
+// extractZipEntry 解压单个 zip 条目
+func extractZipEntry(f *zip.File, target string) error {
+	mode := f.Mode()
+	if mode.IsDir() {
+		return os.MkdirAll(target, mode.Perm()|0700)
+	}
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return err
+	}
+
+	rc, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
+	if mode&os.ModeSymlink != 0 {
+		link, err := io.ReadAll(io.LimitReader(rc, 4096))
+		if err != nil {
+			return err
+		}
+		os.Remove(target)
+		return os.Symlink(string(link), target)
+	}
+
+	perm := mode.Perm()
+	if perm == 0 {
+		perm = 0644
+	}
+	if err := removeSymlink(target); err != nil {
+		return err
+	}
+	out, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, rc); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// listZip 返回 zip 中的全部条目名
+func listZip(archive string) ([]string, error) {
+	r, err := zip.OpenReader(archive)
+	if err != nil {
+		return nil, fmt.Errorf("打开归档文件失败: %w", err)
+	}
+	defer r.Close()
+
+	names := make([]string, 0, len(r.File))
+	for _, f := range r.File {
+		names = append(names, f.Name)
+	}
+	return names, nil
+}
+
+// safeJoin 拼接路径并确保结果不会跳出 root
+// 只检查路径文本，写入前还需要用 checkWithin 检查路径中的符号链接。
+func safeJoin(root, name string) (string, error) {
+	target := filepath.Join(root, filepath.FromSlash(name))
+	if !pathWithin(root, target) {
+		return "", fmt.Errorf("非法的归档路径: %s", name)
+	}
+	return target, nil
+}
+
+// resolveRoot 创建解压目录并返回解析符号链接后的路径
+func resolveRoot(root string) (string, error) {
+	if err := os.MkdirAll(root, 0755); err != nil {
+		return "", err
+	}
+	return filepath.EvalSymlinks(root)
+}
+
+// checkWithin 确认 dir 解析符号链接后仍在 realRoot 中，dir 不存在时检查最近的已存在上级目录
+// 归档可以先创建指向外部的符号链接，再写入链接下的文件，只检查条目名无法发现。
+func checkWithin(realRoot, dir string) error {
+	existing := dir
+	for {
+		if _, err := os.Lstat(existing); err == nil {
+			break
+		}
+		parent := filepath.Dir(existing)
+		if parent == existing {
+			break
+		}
+		existing = parent
+	}
+	resolved, err := filepath.EvalSymlinks(existing)
+	if err != nil {
+		return fmt.Errorf("解析路径失败: %w", err)
+	}
+	if !pathWithin(realRoot, resolved) {
+		return fmt.Errorf("非法的归档路径，经符号链接指向目标目录之外: %s", dir)
+	}
+	return nil
+}
+
+// removeSymlink 写入文件前删除同名的符号链接，避免写到链接指向的文件
+func removeSymlink(path string) error {
+	info, err := os.Lstat(path)
+	if err != nil || info.Mode()&os.ModeSymlink == 0 {
+		return nil
+	}
+	return os.Remove(path)
+}
@@ -0,0 +1,109 @@
+package hotupdater
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+// writeSymlinkTarGz 写入先创建指向 outside 的符号链接、再写入链接下文件的归档
+func writeSymlinkTarGz(t *testing.T, path, outside string) {
+	t.Helper()
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	gw := gzip.NewWriter(f)
+	tw := tar.NewWriter(gw)
+	tw.WriteHeader(&tar.Header{Name: "lnk", Typeflag: tar.TypeSymlink, Linkname: outside, Mode: 0777})
+	data := []byte("evil")
+	tw.WriteHeader(&tar.Header{Name: "lnk/evil", Typeflag: tar.TypeReg, Mode: 0644, Size: int64(len(data))})
+	tw.Write(data)
+	tw.Close()
+	gw.Close()
+}
+
+func writeSymlinkZip(t *testing.T, path, outside string) {
+	t.Helper()
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	zw := zip.NewWriter(f)
+	h := &zip.FileHeader{Name: "lnk"}
+	h.SetMode(os.ModeSymlink | 0777)
+	w, _ := zw.CreateHeader(h)
+	w.Write([]byte(outside))
+	w, _ = zw.Create("lnk/evil")
+	w.Write([]byte("evil"))
+	zw.Close()
+}
+
+func TestExtractRejectsSymlinkEscape(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("创建符号链接需要额外权限")
+	}
+	cases := []struct {
+		name    string
+		write   func(t *testing.T, path, outside string)
+		extract func(archive, dst string) error
+	}{
+		{"tar.gz", writeSymlinkTarGz, extractTarGz},
+		{"zip", writeSymlinkZip, extractZip},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			dir := t.TempDir()
+			outside := filepath.Join(dir, "outside")
+			dst := filepath.Join(dir, "dst")
+			if err := os.MkdirAll(outside, 0755); err != nil {
+				t.Fatal(err)
+			}
+			archive := filepath.Join(dir, "evil."+c.name)
+			c.write(t, archive, outside)
+
+			if err := c.extract(archive, dst); err == nil {
+				t.Fatal("经符号链接写到目标目录之外时应返回错误")
+			}
+			if _, err := os.Stat(filepath.Join(outside, "evil")); err == nil {
+				t.Fatal("文件被写到了目标目录之外")
+			}
+		})
+	}
+}
+
+func TestExtractKeepsInternalSymlink(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("创建符号链接需要额外权限")
+	}
+	dir := t.TempDir()
+	src := filepath.Join(dir, "src", "App.app")
+	if err := os.MkdirAll(filepath.Join(src, "Versions", "A"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(src, "Versions", "A", "bin"), []byte("bin"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("A", filepath.Join(src, "Versions", "Current")); err != nil {
+		t.Fatal(err)
+	}
+
+	archive := filepath.Join(dir, "app.zip")
+	if err := createZip(src, archive); err != nil {
+		t.Fatal(err)
+	}
+	dst := filepath.Join(dir, "dst")
+	if err := extractZip(archive, dst); err != nil {
+		t.Fatalf("目标目录内的符号链接应能正常解压: %v", err)
+	}
+	data, err := os.ReadFile(filepath.Join(dst, "App.app", "Versions", "Current", "bin"))
+	if err != nil || string(data) != "bin" {
+		t.Fatalf("读取解压结果失败: %v %q", err, data)
+	}
+}
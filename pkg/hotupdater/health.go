@@ -0,0 +1,459 @@
+package hotupdater
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/562589540/hotupdater/pkg/version"
+)
+
+const (
+	healthFile         = "health.json"          // 等待确认的更新，保存在 UpdatePath 下
+	failedVersionsFile = "failed_versions.json" // 未通过健康确认的版本，保存在 UpdatePath 下
+)
+
+// healthPollInterval WaitHealthy 检查确认结果的间隔
+const healthPollInterval = 500 * time.Millisecond
+
+// defaultCrashLoopLimit 未配置 Config.CrashLoopLimit 时允许新版本连续启动失败的次数
+const defaultCrashLoopLimit = 3
+
+// defaultCrashLoopWindow 未配置 Config.CrashLoopWindow 时，两次启动间隔小于此时间才把上次启动算作崩溃
+const defaultCrashLoopWindow = time.Minute
+
+// PendingHealth 刚安装、尚未确认运行正常的更新
+// 更新成功后由 FastUpdater 写入，ConfirmHealthy 或 MarkStable 删除。
+type PendingHealth struct {
+	Version         string    `json:"version"`          // 新版本号
+	PreviousVersion string    `json:"previous_version"` // 更新前的版本号
+	Target          string    `json:"target"`           // 被替换的路径
+	BackupFile      string    `json:"backup_file"`      // 本次更新创建的备份
+	InstalledAt     time.Time `json:"installed_at"`
+	Deadline        time.Time `json:"deadline"`           // 超过此时间仍未确认时回滚，未配置 HealthWindow 时为零值
+	Launches        int       `json:"launches,omitempty"` // TrackLaunch 记录的连续未稳定的启动次数
+	LastLaunch      time.Time `json:"last_launch"`        // TrackLaunch 记录的上次启动时间
+}
+
+// Watched 是否配置了健康确认窗口
+func (p *PendingHealth) Watched() bool {
+	return p != nil && !p.Deadline.IsZero()
+}
+
+// FailedVersion 未通过健康确认而被回滚的版本
+type FailedVersion struct {
+	Version string    `json:"version"`
+	Time    time.Time `json:"time"`
+}
+
+// ReadPendingHealth 读取 updatePath 中等待确认的更新，没有时返回 nil
+func ReadPendingHealth(updatePath string) (*PendingHealth, error) {
+	if updatePath == "" {
+		return nil, nil
+	}
+	data, err := os.ReadFile(filepath.Join(updatePath, healthFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var p PendingHealth
+	if err := json.Unmarshal(data, &p); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// ConfirmHealthy 由新版本在启动并确认运行正常后调用，配置了 Config.HealthWindow 时
+// 必须在窗口期内调用，否则更新助手或下一次启动时的 CheckHealth 会恢复旧版本：
+//
+//	if err := app.Start(); err == nil {
+//		hotupdater.ConfirmHealthy(config)
+//	}
+//
+// 没有等待确认的更新时不做任何事；config.CurrentVersion 与待确认的版本不一致时不确认，版本号按语义化版本比较。
+func ConfirmHealthy(config Config) error {
+	p, err := ReadPendingHealth(config.UpdatePath)
+	if err != nil || p == nil {
+		return err
+	}
+	if config.CurrentVersion != "" && p.Version != "" && !sameVersion(config.CurrentVersion, p.Version) {
+		return nil
+	}
+	return removeFile(filepath.Join(config.UpdatePath, healthFile))
+}
+
+// CheckHealth 在应用启动时检查上次更新是否已确认运行正常，应在 ConfirmHealthy 之前尽早调用
+// 超过窗口期仍未确认时从备份恢复旧版本并记录失败的版本，返回 true，调用方应重启应用（启动旧版本）并退出：
+//
+//	if rolledBack, err := hotupdater.CheckHealth(config); err != nil {
+//		log.Printf("恢复旧版本失败，请使用恢复助手: %v", err)
+//	} else if rolledBack {
+//		hotupdater.New(config, ctx).Restart()
+//		os.Exit(0)
+//	}
+//
+// 窗口期内不做任何事，等待应用自行确认。
+func CheckHealth(config Config) (bool, error) {
+	p, err := ReadPendingHealth(config.UpdatePath)
+	if err != nil || !p.Watched() {
+		return false, err
+	}
+	if time.Now().Before(p.Deadline) {
+		return false, nil
+	}
+	if err := rollbackUnhealthy(config, p, ErrUnhealthy); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// WaitHealthy 等待新版本调用 ConfirmHealthy，供启动新版本的更新助手使用
+// 已确认、没有等待确认的更新或未配置窗口时返回 nil，超过窗口期时返回匹配 ErrUnhealthy 的错误，
+// 此时调用方应结束新版本进程并调用 RollbackUnhealthy。
+func WaitHealthy(ctx context.Context, config Config) error {
+	ticker := time.NewTicker(healthPollInterval)
+	defer ticker.Stop()
+	for {
+		p, err := ReadPendingHealth(config.UpdatePath)
+		if err != nil || !p.Watched() {
+			return err
+		}
+		if !time.Now().Before(p.Deadline) {
+			window := p.Deadline.Sub(p.InstalledAt).Round(time.Second)
+			return config.localizer().errorOf(MsgErrUnhealthy, ErrUnhealthy, p.Version, window)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// RollbackUnhealthy 从备份恢复未确认运行正常的更新，并记录失败的版本
+// 恢复失败时保留待确认记录以便下次重试，返回的错误匹配 ErrUnhealthy 和 ErrRollbackFailed。
+func RollbackUnhealthy(config Config) error {
+	p, err := ReadPendingHealth(config.UpdatePath)
+	if err != nil || p == nil {
+		return err
+	}
+	return rollbackUnhealthy(config, p, ErrUnhealthy)
+}
+
+// TrackLaunch 记录刚更新的版本又启动了一次，应在 main 的最开始、任何可能崩溃的初始化之前调用，
+// 运行稳定后调用 MarkStable：
+//
+//	if rolledBack, err := hotupdater.TrackLaunch(config); err != nil {
+//		log.Printf("恢复旧版本失败，请使用恢复助手: %v", err)
+//	} else if rolledBack {
+//		hotupdater.New(config, ctx).Restart()
+//		os.Exit(0)
+//	}
+//	...
+//	time.AfterFunc(time.Minute, func() { hotupdater.MarkStable(config) })
+//	defer hotupdater.TrackExit(config)
+//
+// 新版本连续启动超过 Config.CrashLoopLimit 次（默认 3 次）仍未 MarkStable 时，说明每次都在稳定之前崩溃，
+// 从本次更新创建的备份恢复旧版本并记录失败的版本，返回 true，调用方应重启应用（启动旧版本）并退出。
+// 距上次启动超过 Config.CrashLoopWindow（默认 1 分钟）或上次调用了 TrackExit 时，上次启动不算崩溃，重新计数。
+// 恢复失败时返回的错误匹配 ErrCrashLoop 和 ErrRollbackFailed。
+func TrackLaunch(config Config) (bool, error) {
+	p, err := ReadPendingHealth(config.UpdatePath)
+	if err != nil || p == nil {
+		return false, err
+	}
+	if config.CurrentVersion != "" && p.Version != "" && !sameVersion(config.CurrentVersion, p.Version) {
+		return false, nil
+	}
+
+	limit := config.CrashLoopLimit
+	if limit <= 0 {
+		limit = defaultCrashLoopLimit
+	}
+	window := config.CrashLoopWindow
+	if window <= 0 {
+		window = defaultCrashLoopWindow
+	}
+	now := time.Now()
+	if !p.LastLaunch.IsZero() && now.Sub(p.LastLaunch) > window {
+		p.Launches = 0
+	}
+	p.Launches++
+	p.LastLaunch = now
+	if p.Launches <= limit {
+		return false, writeJSONFile(filepath.Join(config.UpdatePath, healthFile), p)
+	}
+
+	if config.Logger != nil {
+		config.Logger.Logf("新版本 %s 连续 %d 次启动后未能稳定运行", p.Version, p.Launches-1)
+	}
+	if err := rollbackUnhealthy(config, p, ErrCrashLoop); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// MarkStable 标记刚更新的版本已稳定运行，停止 TrackLaunch 计数，同时视为 ConfirmHealthy
+func MarkStable(config Config) error {
+	return ConfirmHealthy(config)
+}
+
+// TrackExit 在应用正常退出时调用，本次启动不计入 TrackLaunch 的崩溃次数
+// 用户在 MarkStable 之前关闭应用、随后又打开时，不会被误判为崩溃循环。
+func TrackExit(config Config) error {
+	p, err := ReadPendingHealth(config.UpdatePath)
+	if err != nil || p == nil || p.Launches == 0 {
+		return err
+	}
+	if config.CurrentVersion != "" && p.Version != "" && !sameVersion(config.CurrentVersion, p.Version) {
+		return nil
+	}
+	p.Launches--
+	return writeJSONFile(filepath.Join(config.UpdatePath, healthFile), p)
+}
+
+// rollbackUnhealthy 恢复旧版本，reason 为 ErrUnhealthy 或 ErrCrashLoop
+func rollbackUnhealthy(config Config, p *PendingHealth, reason error) error {
+	logf := func(format string, args ...interface{}) {
+		if config.Logger != nil {
+			config.Logger.Logf(format, args...)
+		}
+	}
+	logf("新版本 %s %v，从备份恢复: %s", p.Version, reason, p.BackupFile)
+
+	if p.Version != "" {
+		if err := recordFailedVersion(config.UpdatePath, p.Version); err != nil {
+			logf("记录失败版本失败: %v", err)
+		}
+	}
+
+	l := config.localizer()
+	if err := restoreRunning(p.BackupFile, p.Target); err != nil {
+		logf("恢复旧版本失败: %v", err)
+		return &UpdateError{Kind: reason, Phase: PhaseVerify, Err: l.wrap(MsgErrHealthRollback, err), RollbackErr: err}
+	}
+	logf("已恢复到 %s", p.PreviousVersion)
+
+	if fn := config.Hooks.OnRollback; fn != nil {
+		hc := config.hookContext(context.Background(), HookRollback)
+		hc.CurrentVersion, hc.UpdateVersion = p.PreviousVersion, p.Version
+		hc.Target, hc.BackupFile = p.Target, p.BackupFile
+		hc.Err = reason
+		fn(hc)
+	}
+	return removeFile(filepath.Join(config.UpdatePath, healthFile))
+}
+
+// restoreRunning 用备份恢复可能仍在运行的 target
+// 先把 target 重命名移开再恢复，失败时移回，不会留下不完整的 target。
+// Windows 上运行中的可执行文件不能覆盖但可以重命名，移开的文件在进程退出后才能删除。
+func restoreRunning(backupFile, target string) error {
+	if backupFile == "" || target == "" {
+		return errors.New("缺少备份文件或目标路径")
+	}
+	old := target + ".replaced"
+	os.RemoveAll(old)
+	if err := os.Rename(target, old); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	var err error
+	if strings.HasSuffix(backupFile, ".tar.gz") {
+		if err = extractTarGz(backupFile, archiveRoot(target)); err == nil {
+			// 备份中记录的路径与 target 不同时解压不会产生 target
+			if _, statErr := os.Lstat(target); statErr != nil {
+				err = fmt.Errorf("备份中没有 %s", target)
+			}
+		}
+	} else {
+		err = copyFile(backupFile, target)
+	}
+	if err != nil {
+		os.RemoveAll(target)
+		os.Rename(old, target)
+		return err
+	}
+	os.RemoveAll(old)
+	return nil
+}
+
+// FailedVersions 返回 updatePath 中记录的未通过健康确认的版本
+func FailedVersions(updatePath string) ([]FailedVersion, error) {
+	if updatePath == "" {
+		return nil, nil
+	}
+	data, err := os.ReadFile(filepath.Join(updatePath, failedVersionsFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var versions []FailedVersion
+	if err := json.Unmarshal(data, &versions); err != nil {
+		return nil, err
+	}
+	return versions, nil
+}
+
+// sameVersion 按语义化版本比较 a 和 b（如 v1.2.0 与 1.2.0 相同），无法解析时比较原始字符串
+func sameVersion(a, b string) bool {
+	if c, err := version.Compare(a, b); err == nil {
+		return c == 0
+	}
+	return a == b
+}
+
+// isFailedVersion 判断 v 是否在失败版本列表中
+func isFailedVersion(failed []FailedVersion, v version.Version) bool {
+	for _, f := range failed {
+		if fv, err := version.Parse(f.Version); err == nil && fv.Equal(v) {
+			return true
+		}
+	}
+	return false
+}
+
+// recordFailedVersion 把版本加入失败列表
+func recordFailedVersion(updatePath, v string) error {
+	failed, err := FailedVersions(updatePath)
+	if err != nil {
+		return err
+	}
+	for _, f := range failed {
+		if f.Version == v {
+			return nil
+		}
+	}
+	failed = append(failed, FailedVersion{Version: v, Time: time.Now()})
+	return writeJSONFile(filepath.Join(updatePath, failedVersionsFile), failed)
+}
+
+// updateBackup 返回本次更新创建的备份，since 为本次更新开始的时间，找不到时返回空
+func (f *FastUpdater) updateBackup(since time.Time) string {
+	return findUpdateBackup(backupDirs(f.config.BackupPath, f.updater.GetCurrentExe()), f.config.CurrentVersion, since)
+}
+
+// markPendingHealth 更新成功后记录等待确认的新版本，供健康确认和 TrackLaunch 使用
+// 找不到本次更新创建的备份时无法回滚，只记录日志。
+func (f *FastUpdater) markPendingHealth(backup string) {
+	if f.config.UpdatePath == "" {
+		return
+	}
+	if backup == "" {
+		f.config.Logger.Log("未找到本次更新的备份，跳过健康确认")
+		return
+	}
+	exe := f.updater.GetCurrentExe()
+	target, err := backupTarget(backup, exe)
+	if err != nil {
+		f.config.Logger.Logf("读取备份失败，跳过健康确认: %v", err)
+		return
+	}
+
+	now := time.Now()
+	p := PendingHealth{
+		Version:         f.config.UpdateVersion,
+		PreviousVersion: f.config.CurrentVersion,
+		Target:          target,
+		BackupFile:      backup,
+		InstalledAt:     now,
+	}
+	if f.config.HealthWindow > 0 {
+		p.Deadline = now.Add(f.config.HealthWindow)
+	}
+	if err := writeJSONFile(filepath.Join(f.config.UpdatePath, healthFile), p); err != nil {
+		f.config.Logger.Logf("记录健康确认失败: %v", err)
+		return
+	}
+	if p.Watched() {
+		f.config.Logger.Logf("新版本需要在 %s 内确认运行正常", f.config.HealthWindow)
+	}
+}
+
+// backupDirs 返回 Go 流水线和 update.lua 可能使用的备份目录
+func backupDirs(backupPath, exe string) []string {
+	root := installRoot(exe)
+	candidates := []string{backupPath, defaultBackupDir(exe), defaultBackupDir(root), filepath.Join(root, "backup")}
+	var dirs []string
+	seen := make(map[string]bool)
+	for _, dir := range candidates {
+		if dir != "" && !seen[dir] {
+			seen[dir] = true
+			dirs = append(dirs, dir)
+		}
+	}
+	return dirs
+}
+
+// findUpdateBackup 在 dirs 中查找 since 之后为 currentVersion 创建的最新备份，按文件名中的时间判断
+func findUpdateBackup(dirs []string, currentVersion string, since time.Time) string {
+	since = since.Truncate(time.Second)
+	var found *Backup
+	for _, dir := range dirs {
+		backups, err := ListBackups(dir)
+		if err != nil {
+			continue
+		}
+		for i, b := range backups {
+			if b.Version != currentVersion || b.Time.Before(since) {
+				continue
+			}
+			if found == nil || b.Time.After(found.Time) {
+				found = &backups[i]
+			}
+			break
+		}
+	}
+	if found == nil {
+		return ""
+	}
+	return found.Path
+}
+
+// backupTarget 返回备份对应的被替换路径：优先读取说明文件，
+// 否则 tar.gz 备份的第一个条目即为被备份的路径，单文件备份对应 exe
+func backupTarget(backupFile, exe string) (string, error) {
+	if meta, err := ReadBackupMeta(backupFile); err == nil && meta != nil && meta.Target != "" {
+		return meta.Target, nil
+	}
+	if !strings.HasSuffix(backupFile, ".tar.gz") {
+		return exe, nil
+	}
+	name, err := firstTarGzEntry(backupFile)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(archiveRoot(exe), filepath.FromSlash(strings.TrimSuffix(name, "/"))), nil
+}
+
+// writeJSONFile 先写临时文件再重命名，避免中断时留下不完整的文件
+func writeJSONFile(path string, v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// removeFile 删除文件，文件不存在时不报错
+func removeFile(path string) error {
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+package hotupdater
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writePending 在 dir 中写入等待确认的更新
+func writePending(t *testing.T, dir string, p PendingHealth) {
+	t.Helper()
+	if err := writeJSONFile(filepath.Join(dir, healthFile), &p); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestConfirmHealthyComparesSemver(t *testing.T) {
+	dir := t.TempDir()
+	writePending(t, dir, PendingHealth{Version: "1.2.0"})
+
+	// 版本不同时不确认
+	if err := ConfirmHealthy(Config{UpdatePath: dir, CurrentVersion: "1.1.0"}); err != nil {
+		t.Fatal(err)
+	}
+	if p, _ := ReadPendingHealth(dir); p == nil {
+		t.Fatal("版本不同时不应确认")
+	}
+
+	// v 前缀不影响比较
+	if err := ConfirmHealthy(Config{UpdatePath: dir, CurrentVersion: "v1.2.0"}); err != nil {
+		t.Fatal(err)
+	}
+	if p, _ := ReadPendingHealth(dir); p != nil {
+		t.Fatal("v1.2.0 与 1.2.0 是同一版本，应确认")
+	}
+}
+
+func TestTrackLaunchComparesSemver(t *testing.T) {
+	dir := t.TempDir()
+	writePending(t, dir, PendingHealth{Version: "1.2.0"})
+	if _, err := TrackLaunch(Config{UpdatePath: dir, CurrentVersion: "v1.2.0"}); err != nil {
+		t.Fatal(err)
+	}
+	p, err := ReadPendingHealth(dir)
+	if err != nil || p == nil || p.Launches != 1 {
+		t.Fatalf("v1.2.0 的启动应被记录: %+v %v", p, err)
+	}
+}
+
+func TestTrackLaunchCountsOnlyQuickRelaunches(t *testing.T) {
+	dir := t.TempDir()
+	config := Config{UpdatePath: dir, CrashLoopLimit: 3}
+
+	// 上次启动已是两分钟前，说明它运行了一段时间，不算崩溃
+	writePending(t, dir, PendingHealth{Version: "1.2.0", Launches: 3, LastLaunch: time.Now().Add(-2 * time.Minute)})
+	if rolledBack, err := TrackLaunch(config); err != nil || rolledBack {
+		t.Fatalf("不应回滚: %v %v", rolledBack, err)
+	}
+	if p, _ := ReadPendingHealth(dir); p == nil || p.Launches != 1 {
+		t.Fatalf("应重新计数: %+v", p)
+	}
+
+	// 正常退出的启动不计入
+	writePending(t, dir, PendingHealth{Version: "1.2.0", Launches: 3, LastLaunch: time.Now()})
+	if err := TrackExit(config); err != nil {
+		t.Fatal(err)
+	}
+	if rolledBack, err := TrackLaunch(config); err != nil || rolledBack {
+		t.Fatalf("正常退出后再次启动不应回滚: %v %v", rolledBack, err)
+	}
+	if p, _ := ReadPendingHealth(dir); p == nil || p.Launches != 3 {
+		t.Fatalf("启动次数应为 3: %+v", p)
+	}
+}
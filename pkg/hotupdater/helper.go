@@ -1,50 +1,105 @@
 package hotupdater
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"os"
-	"strings"
 
 	lua "github.com/yuin/gopher-lua"
 )
 
 // helper 提供共享的辅助函数
 type helper struct {
-	logger  Logger
-	emitter EventEmitter
+	config     Config
+	logger     Logger
+	emitter    EventEmitter
+	model      *ProgressModel
+	l          *Localizer
+	dispatcher *messageDispatcher
 }
 
-func newHelper(logger Logger, emitter EventEmitter) *helper {
+func newHelper(config Config) *helper {
+	l := config.localizer()
 	return &helper{
-		logger:  logger,
-		emitter: emitter,
+		config:     config,
+		logger:     config.Logger,
+		emitter:    config.EventEmitter,
+		model:      config.ProgressModel,
+		l:          l,
+		dispatcher: &messageDispatcher{logger: config.Logger, emitter: config.EventEmitter, model: config.ProgressModel, l: l},
 	}
 }
 
-// 日志函数注册到 Lua
+// 日志函数注册到 Lua，消息按协议解析（JSON 行、旧版 @PROGRESS@ 或纯文本日志）
 func (h *helper) registerLogger(L *lua.LState) {
 	L.SetGlobal("log_message", L.NewFunction(func(L *lua.LState) int {
-		msg := L.ToString(1)
-
-		// 检查是否是进度消息
-		if strings.HasPrefix(msg, "@PROGRESS@") {
-			if h.emitter != nil {
-				if progress := ParseProgressMessage(strings.TrimPrefix(msg, "@PROGRESS@")); progress != nil {
-					h.emitter.EmitProgress(*progress)
-				}
-			}
-		} else {
-			// 普通日志消息
-			if h.logger != nil {
-				h.logger.Logf("%s", msg)
-			}
+		h.dispatcher.dispatch(ParseMessage(L.ToString(1)))
+		return 0
+	}))
+}
+
+// registerPhases 注册 declare_phase(name, weight[, message[, before]])，供脚本声明自定义进度阶段
+func (h *helper) registerPhases(L *lua.LState) {
+	L.SetGlobal("declare_phase", L.NewFunction(func(L *lua.LState) int {
+		h.dispatcher.dispatch(Message{
+			Type:    MessagePhase,
+			Phase:   UpdatePhase(L.CheckString(1)),
+			Weight:  L.CheckInt(2),
+			Message: L.OptString(3, ""),
+			Before:  UpdatePhase(L.OptString(4, "")),
+		})
+		return 0
+	}))
+}
+
+// logf 输出日志，没有 Logger 时忽略
+func (h *helper) logf(format string, args ...interface{}) {
+	if h.logger != nil {
+		h.logger.Logf(format, args...)
+	}
+}
+
+// registerHooks 注册 run_hook(point[, backup_file])，供脚本在备份和安装前后触发 Config.Hooks
+// 钩子否决时抛出 Lua 错误，veto 记录否决的错误
+func (h *helper) registerHooks(ctx context.Context, L *lua.LState, params map[string]string, veto *error) {
+	L.SetGlobal("run_hook", L.NewFunction(func(L *lua.LState) int {
+		point := HookPoint(L.CheckString(1))
+		if phase := point.phase(); phase != PhaseBackup && phase != PhaseInstall {
+			L.ArgError(1, "unknown hook point: "+string(point))
+		}
+		hc := h.config.hookContext(ctx, point)
+		hc.NewVersion = params["new_version"]
+		hc.Target = params["app_path"]
+		hc.BackupFile = L.OptString(2, "")
+		if err := h.config.runHook(hc); err != nil {
+			*veto = err
+			L.RaiseError("%s", err.Error())
 		}
 		return 0
 	}))
 }
 
+// emitProgress 发送阶段内进度（percentage 为阶段内 0-100）
+func (h *helper) emitProgress(phase UpdatePhase, percentage int, detail string) {
+	if h.emitter == nil {
+		return
+	}
+	h.emitter.EmitProgress(h.model.Progress(h.l, phase, percentage, detail))
+}
+
+// emitProgressID 发送阶段内进度，详情为消息 id 翻译后的文本
+func (h *helper) emitProgressID(phase UpdatePhase, percentage int, id MessageID, args ...interface{}) {
+	if h.emitter == nil {
+		return
+	}
+	progress := h.model.Progress(h.l, phase, percentage, h.l.T(id, args...))
+	progress.DetailID = id
+	h.emitter.EmitProgress(progress)
+}
+
 // writeUpdateInfo 写入更新信息到文件
-func (h *helper) writeUpdateInfo(path string, info map[string]string) error {
+func (h *helper) writeUpdateInfo(path string, info interface{}) error {
 	data, err := json.Marshal(info)
 	if err != nil {
 		return err
@@ -52,34 +107,89 @@ func (h *helper) writeUpdateInfo(path string, info map[string]string) error {
 	return os.WriteFile(path, data, 0644)
 }
 
-// executeLuaScript 执行 Lua 更新脚本
-func (h *helper) executeLuaScript(L *lua.LState, scriptPath string, params map[string]string) error {
+// executeLuaScript 执行 Lua 更新脚本，sandbox 非空时在沙箱中执行
+func (h *helper) executeLuaScript(ctx context.Context, L *lua.LState, scriptPath string, params map[string]string, sandbox *LuaSandbox) error {
 	// 注册日志函数
 	h.registerLogger(L)
+	h.registerPhases(L)
+	RegisterTranslator(L, h.l)
+	var veto error
+	h.registerHooks(ctx, L, params, &veto)
 
 	// 注册系统命令执行函数
 	L.SetGlobal("os_execute", L.NewFunction(func(L *lua.LState) int {
 		cmd := L.ToString(1)
-		result := ExecuteCommand(cmd)
+		result := ExecuteCommandContext(luaContext(L), cmd)
 		L.Push(lua.LBool(result))
 		return 1
 	}))
 
-	// 加载并执行脚本
-	if err := L.DoFile(scriptPath); err != nil {
+	// 安装沙箱限制
+	if err := sandbox.Install(L, params); err != nil {
 		return err
 	}
 
-	// 创建参数表
-	paramsTable := L.NewTable()
-	for k, v := range params {
-		L.SetField(paramsTable, k, lua.LString(v))
+	// 注册原生文件操作模块
+	if err := OpenLuaModule(L, sandbox, params); err != nil {
+		return err
+	}
+
+	err := RunLuaUpdate(ctx, L, sandbox, scriptPath, params)
+	var ue *UpdateError
+	if errors.As(err, &ue) && ue.Kind == ErrCancelled {
+		ue.Phase = h.dispatcher.failedPhase()
+		ue.Err = h.l.wrap(MsgErrCancelled, ue.Err)
+		return err
+	}
+	if err != nil && veto != nil {
+		// 脚本因钩子否决而失败，内置 update.lua 在安装后被否决时会先恢复备份
+		return veto
+	}
+	return err
+}
+
+// LuaRollbackFunc 更新被取消时调用的脚本函数名
+const LuaRollbackFunc = "rollback_update"
+
+// RunLuaUpdate 加载脚本并调用 perform_update(params)，ctx 取消或沙箱超时时中止脚本
+// ctx 被取消时返回 Kind 为 ErrCancelled 的 *UpdateError；脚本定义了 rollback_update(params) 时，
+// 会先解除取消再调用它恢复旧版本，结果记录在 RolledBack 和 RollbackErr 中。
+func RunLuaUpdate(ctx context.Context, L *lua.LState, sandbox *LuaSandbox, scriptPath string, params map[string]string) error {
+	err := sandbox.Run(ctx, L, func() error {
+		// 加载并执行脚本
+		if err := L.DoFile(scriptPath); err != nil {
+			return err
+		}
+
+		// 调用更新函数，传入参数表
+		return L.CallByParam(lua.P{
+			Fn:      L.GetGlobal("perform_update"),
+			NRet:    0,
+			Protect: true,
+		}, luaParams(L, params))
+	})
+	if err == nil || ctx.Err() == nil {
+		return err
 	}
 
-	// 调用更新函数，传入参数表
-	return L.CallByParam(lua.P{
-		Fn:      L.GetGlobal("perform_update"),
-		NRet:    0,
-		Protect: true,
-	}, paramsTable)
+	ue := &UpdateError{Kind: ErrCancelled, Err: ctx.Err()}
+	rollback, ok := L.GetGlobal(LuaRollbackFunc).(*lua.LFunction)
+	if !ok {
+		return ue
+	}
+	// 回滚不能再被取消，仍受沙箱的超时限制
+	ue.RollbackErr = sandbox.Run(context.Background(), L, func() error {
+		return L.CallByParam(lua.P{Fn: rollback, NRet: 0, Protect: true}, luaParams(L, params))
+	})
+	ue.RolledBack = ue.RollbackErr == nil
+	return ue
+}
+
+// luaParams 创建脚本的参数表
+func luaParams(L *lua.LState, params map[string]string) *lua.LTable {
+	t := L.NewTable()
+	for k, v := range params {
+		L.SetField(t, k, lua.LString(v))
+	}
+	return t
 }
@@ -0,0 +1,124 @@
+package hotupdater
+
+import "context"
+
+// HookPoint 生命周期钩子的触发点
+type HookPoint string
+
+const (
+	HookBeforeDownload HookPoint = "before_download" // 下载更新包之前
+	HookBeforeBackup   HookPoint = "before_backup"   // 备份当前版本之前，如刷新数据库
+	HookAfterBackup    HookPoint = "after_backup"    // 备份完成之后
+	HookBeforeInstall  HookPoint = "before_install"  // 替换应用之前，如停止后台任务
+	HookAfterInstall   HookPoint = "after_install"   // 新版本替换完成之后
+	HookRollback       HookPoint = "rollback"        // 更新失败或取消并回滚之后
+	HookComplete       HookPoint = "complete"        // 更新结束（成功时在重启之前）
+)
+
+// HookContext 传给钩子的更新信息
+type HookContext struct {
+	Context        context.Context
+	Point          HookPoint
+	CurrentVersion string
+	UpdateVersion  string
+	NewVersion     string // 更新包路径
+	Target         string // 被替换的路径
+	BackupFile     string // 备份文件路径，AfterBackup 起可用（macOS 更新助手执行脚本时为空）
+	Err            error  // OnRollback、OnComplete 时为更新失败的原因，成功时为 nil
+}
+
+// Hooks 更新生命周期钩子，为空的钩子不执行
+//
+// Before*、After* 钩子返回错误会否决更新：Update 返回 Kind 为 ErrHookVetoed 的 *UpdateError，
+// 尚未修改应用时直接中止，已经修改时先回滚。OnRollback 和 OnComplete 只是通知，不能否决。
+//
+//	config.Hooks = hotupdater.Hooks{
+//		BeforeBackup:  func(hc hotupdater.HookContext) error { return db.Checkpoint() },
+//		BeforeInstall: func(hc hotupdater.HookContext) error { return workers.Stop(hc.Context) },
+//		OnRollback:    func(hc hotupdater.HookContext) { ui.Notify("更新失败，已恢复旧版本") },
+//	}
+//
+// BeforeDownload（仅在配置了 DownloadImpl 时）、OnRollback 和 OnComplete 由 FastUpdater 触发。
+// 备份和安装钩子在 Go 流水线的对应阶段前后触发；Lua 脚本通过 run_hook(point) 触发，内置 update.lua 已调用。
+// macOS 更新助手在独立进程中执行脚本，BeforeBackup、BeforeInstall 在启动助手之前触发，
+// AfterBackup、AfterInstall 在助手完成（新版本已安装）之后触发，否决时从本次更新的备份恢复旧版本。
+// Windows 上内置 update.lua 在应用退出后由批处理替换可执行文件，AfterInstall 不会触发；
+// 需要在替换后检查时使用 Config.Pipeline，或在新版本启动后用 ConfirmHealthy 确认。
+type Hooks struct {
+	BeforeDownload func(hc HookContext) error
+	BeforeBackup   func(hc HookContext) error
+	AfterBackup    func(hc HookContext) error
+	BeforeInstall  func(hc HookContext) error
+	AfterInstall   func(hc HookContext) error
+	OnRollback     func(hc HookContext)
+	OnComplete     func(hc HookContext)
+}
+
+// hook 返回触发点对应的可否决钩子
+func (hs Hooks) hook(point HookPoint) func(HookContext) error {
+	switch point {
+	case HookBeforeDownload:
+		return hs.BeforeDownload
+	case HookBeforeBackup:
+		return hs.BeforeBackup
+	case HookAfterBackup:
+		return hs.AfterBackup
+	case HookBeforeInstall:
+		return hs.BeforeInstall
+	case HookAfterInstall:
+		return hs.AfterInstall
+	}
+	return nil
+}
+
+// phaseHooks 返回流水线进入和离开阶段时执行的钩子，没有时为空
+func phaseHooks(phase UpdatePhase) (before, after HookPoint) {
+	switch phase {
+	case PhaseBackup:
+		return HookBeforeBackup, HookAfterBackup
+	case PhaseInstall:
+		return HookBeforeInstall, HookAfterInstall
+	}
+	return "", ""
+}
+
+// phase 返回触发点所属的更新阶段
+func (p HookPoint) phase() UpdatePhase {
+	switch p {
+	case HookBeforeDownload:
+		return PhaseDownload
+	case HookBeforeBackup, HookAfterBackup:
+		return PhaseBackup
+	case HookBeforeInstall, HookAfterInstall:
+		return PhaseInstall
+	}
+	return PhaseComplete
+}
+
+// hookContext 以配置中的版本信息构造钩子参数
+func (c Config) hookContext(ctx context.Context, point HookPoint) HookContext {
+	return HookContext{
+		Context:        ctx,
+		Point:          point,
+		CurrentVersion: c.CurrentVersion,
+		UpdateVersion:  c.UpdateVersion,
+	}
+}
+
+// runHook 执行可否决的钩子，钩子返回错误时返回 Kind 为 ErrHookVetoed 的 *UpdateError
+func (c Config) runHook(hc HookContext) error {
+	fn := c.Hooks.hook(hc.Point)
+	if fn == nil {
+		return nil
+	}
+	if c.Logger != nil {
+		c.Logger.Logf("执行钩子: %s", hc.Point)
+	}
+	if err := fn(hc); err != nil {
+		if c.Logger != nil {
+			c.Logger.Logf("钩子 %s 否决了更新: %v", hc.Point, err)
+		}
+		return newUpdateError(ErrHookVetoed, hc.Point.phase(), c.localizer().wrap(MsgErrHookVetoed, err, hc.Point))
+	}
+	return nil
+}
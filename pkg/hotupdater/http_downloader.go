@@ -0,0 +1,279 @@
+package hotupdater
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// partSuffix 未完成下载的临时文件后缀
+const partSuffix = ".part"
+
+// validatorSuffix 保存临时文件对应的 ETag 或 Last-Modified，续传时作为 If-Range 发送
+const validatorSuffix = ".part.validator"
+
+// progressInterval 下载进度回调的最小间隔
+const progressInterval = 200 * time.Millisecond
+
+// HTTPDownloader 内置的 HTTP 下载实现
+// 下载内容先写入 UpdatePath 下的 .part 临时文件，中断后再次执行会通过 Range 请求续传，
+// 下载完成后重命名为最终文件。续传时以 If-Range 附带上次响应的 ETag 或 Last-Modified，
+// 远端文件已变化时服务器返回完整内容，从头下载；服务器没有提供二者时不续传。
+type HTTPDownloader struct {
+	URL      string       // 下载地址
+	Dir      string       // 保存目录，通常为 Config.UpdatePath
+	FileName string       // 保存文件名，为空时使用 URL 路径的最后一段
+	Client   *http.Client // HTTP 客户端，为空时使用 http.DefaultClient
+	Header   http.Header  // 额外的请求头
+
+	sum  string // 最近一次成功下载的 SHA-256
+	size int64  // 最近一次成功下载的字节数
+}
+
+// NewHTTPDownloader 创建 HTTP 下载器
+func NewHTTPDownloader(rawURL, updatePath string) *HTTPDownloader {
+	return &HTTPDownloader{
+		URL: rawURL,
+		Dir: updatePath,
+	}
+}
+
+// Path 返回下载完成后的文件路径，可直接传给 FastUpdater.Update
+func (d *HTTPDownloader) Path() string {
+	name := d.FileName
+	if name == "" {
+		if u, err := url.Parse(d.URL); err == nil {
+			name = path.Base(u.Path)
+		}
+		if name == "" || name == "." || name == "/" {
+			name = "update.pkg"
+		}
+	}
+	return filepath.Join(d.Dir, name)
+}
+
+// Execute 执行下载，实现 DownloadImplementation 接口
+func (d *HTTPDownloader) Execute(ctx context.Context, onProgress func(current, total int64, speed float64)) error {
+	d.sum, d.size = "", 0
+	if d.URL == "" {
+		return errors.New("下载地址为空")
+	}
+	if err := os.MkdirAll(d.Dir, 0755); err != nil {
+		return fmt.Errorf("创建下载目录失败: %w", err)
+	}
+
+	finalPath := d.Path()
+	partPath := finalPath + partSuffix
+	validatorPath := finalPath + validatorSuffix
+
+	var offset int64
+	validator, _ := os.ReadFile(validatorPath)
+	if info, err := os.Stat(partPath); err == nil && len(validator) > 0 {
+		offset = info.Size()
+	}
+
+	resp, err := d.request(ctx, offset, string(validator))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	flags := os.O_CREATE | os.O_WRONLY
+	h := sha256.New()
+	switch resp.StatusCode {
+	case http.StatusPartialContent:
+		start, err := parseContentRangeStart(resp.Header.Get("Content-Range"))
+		if err != nil || start != offset {
+			return fmt.Errorf("服务器返回的续传范围无效: %s", resp.Header.Get("Content-Range"))
+		}
+		// 续传时先把已下载部分计入摘要
+		if err := hashFile(h, partPath); err != nil {
+			return fmt.Errorf("读取临时文件失败: %w", err)
+		}
+		flags |= os.O_APPEND
+	case http.StatusOK:
+		// 服务器不支持续传或远端文件已变化，从头开始，并记录本次内容的校验值供下次续传
+		offset = 0
+		flags |= os.O_TRUNC
+		if err := writeValidator(validatorPath, resp); err != nil {
+			return fmt.Errorf("写入续传校验值失败: %w", err)
+		}
+	case http.StatusRequestedRangeNotSatisfiable:
+		if offset == 0 {
+			return fmt.Errorf("下载失败，HTTP 状态码: %d", resp.StatusCode)
+		}
+		// 临时文件可能已损坏或比远端文件大，删除后重新下载
+		resp.Body.Close()
+		if err := removeFile(partPath); err != nil {
+			return fmt.Errorf("清理临时文件失败: %w", err)
+		}
+		if err := removeFile(validatorPath); err != nil {
+			return fmt.Errorf("清理临时文件失败: %w", err)
+		}
+		return d.Execute(ctx, onProgress)
+	default:
+		return fmt.Errorf("下载失败，HTTP 状态码: %d", resp.StatusCode)
+	}
+
+	total := int64(0)
+	if resp.ContentLength >= 0 {
+		total = offset + resp.ContentLength
+	}
+
+	file, err := os.OpenFile(partPath, flags, 0644)
+	if err != nil {
+		return fmt.Errorf("打开临时文件失败: %w", err)
+	}
+
+	current, err := d.copy(ctx, io.MultiWriter(file, h), resp.Body, offset, total, onProgress)
+	if err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return fmt.Errorf("写入临时文件失败: %w", err)
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("关闭临时文件失败: %w", err)
+	}
+
+	if total > 0 && current != total {
+		return fmt.Errorf("下载不完整: %d/%d 字节", current, total)
+	}
+
+	if err := os.Rename(partPath, finalPath); err != nil {
+		return fmt.Errorf("重命名下载文件失败: %w", err)
+	}
+	os.Remove(validatorPath)
+
+	d.sum = hex.EncodeToString(h.Sum(nil))
+	d.size = current
+	return nil
+}
+
+// Digest 返回最近一次成功下载内容的 SHA-256 和大小，实现 Digester 接口
+func (d *HTTPDownloader) Digest() (string, int64) {
+	return d.sum, d.size
+}
+
+// request 发送下载请求，offset 大于 0 时附带 Range 和 If-Range 头
+func (d *HTTPDownloader) request(ctx context.Context, offset int64, validator string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, d.URL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("创建下载请求失败: %w", err)
+	}
+	for k, values := range d.Header {
+		for _, v := range values {
+			req.Header.Add(k, v)
+		}
+	}
+	if offset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+		req.Header.Set("If-Range", validator)
+	}
+
+	client := d.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("下载请求失败: %w", err)
+	}
+	return resp, nil
+}
+
+// copy 将响应内容写入文件，并按间隔报告进度和速度(MB/s)
+func (d *HTTPDownloader) copy(ctx context.Context, dst io.Writer, src io.Reader, current, total int64, onProgress func(current, total int64, speed float64)) (int64, error) {
+	buf := make([]byte, 32*1024)
+	start := time.Now()
+	lastReport := start
+	received := int64(0)
+
+	report := func() {
+		if onProgress == nil {
+			return
+		}
+		speed := 0.0
+		if elapsed := time.Since(start).Seconds(); elapsed > 0 {
+			speed = float64(received) / elapsed / 1024 / 1024
+		}
+		onProgress(current, total, speed)
+	}
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return current, err
+		}
+
+		n, readErr := src.Read(buf)
+		if n > 0 {
+			if _, err := dst.Write(buf[:n]); err != nil {
+				return current, fmt.Errorf("写入临时文件失败: %w", err)
+			}
+			current += int64(n)
+			received += int64(n)
+			if time.Since(lastReport) >= progressInterval {
+				report()
+				lastReport = time.Now()
+			}
+		}
+		if readErr == io.EOF {
+			report()
+			return current, nil
+		}
+		if readErr != nil {
+			if ctx.Err() != nil {
+				return current, ctx.Err()
+			}
+			return current, fmt.Errorf("读取下载内容失败: %w", readErr)
+		}
+	}
+}
+
+// writeValidator 保存响应的强 ETag，没有时保存 Last-Modified；二者都没有时删除已有的校验值，下次不续传
+// 弱 ETag 不能用于 If-Range。
+func writeValidator(path string, resp *http.Response) error {
+	validator := resp.Header.Get("ETag")
+	if validator == "" || strings.HasPrefix(validator, "W/") {
+		validator = resp.Header.Get("Last-Modified")
+	}
+	if validator == "" {
+		return removeFile(path)
+	}
+	return os.WriteFile(path, []byte(validator), 0644)
+}
+
+// hashFile 将文件内容写入 h
+func hashFile(h hash.Hash, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = io.Copy(h, f)
+	return err
+}
+
+// parseContentRangeStart 解析 "bytes start-end/total" 中的 start
+func parseContentRangeStart(value string) (int64, error) {
+	value = strings.TrimPrefix(value, "bytes ")
+	dash := strings.Index(value, "-")
+	if dash <= 0 {
+		return 0, fmt.Errorf("无效的 Content-Range: %s", value)
+	}
+	return strconv.ParseInt(value[:dash], 10, 64)
+}
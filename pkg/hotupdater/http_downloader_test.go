@@ -0,0 +1,171 @@
+package hotupdater
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testServer 以 etag 提供 content，支持 Range 和 If-Range，并记录每次请求的 Range 头
+type testServer struct {
+	*httptest.Server
+	mu      sync.Mutex
+	content []byte
+	etag    string
+	ranges  []string
+	// abortAt 大于 0 时，第一个完整请求只发送这么多字节后断开连接
+	abortAt int
+}
+
+func newTestServer(t *testing.T, content []byte, etag string) *testServer {
+	s := &testServer{content: content, etag: etag}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		s.ranges = append(s.ranges, r.Header.Get("Range"))
+		abortAt := s.abortAt
+		s.abortAt = 0
+		content, etag := s.content, s.etag
+		s.mu.Unlock()
+
+		w.Header().Set("ETag", etag)
+		if abortAt > 0 && r.Header.Get("Range") == "" {
+			w.Header().Set("Content-Length", strconv.Itoa(len(content)))
+			w.WriteHeader(http.StatusOK)
+			w.Write(content[:abortAt])
+			w.(http.Flusher).Flush()
+			panic(http.ErrAbortHandler)
+		}
+		http.ServeContent(w, r, "update.pkg", time.Time{}, bytes.NewReader(content))
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+// writePart 写入未完成的临时文件和续传校验值
+func writePart(t *testing.T, d *HTTPDownloader, data []byte, validator string) {
+	t.Helper()
+	if err := os.WriteFile(d.Path()+partSuffix, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(d.Path()+validatorSuffix, []byte(validator), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func hexSum(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// checkDownload 确认下载结果与 content 一致且临时文件已清理
+func checkDownload(t *testing.T, d *HTTPDownloader, content []byte) {
+	t.Helper()
+	got, err := os.ReadFile(d.Path())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, content) {
+		t.Fatalf("下载内容不一致: %q", got)
+	}
+	for _, suffix := range []string{partSuffix, validatorSuffix} {
+		if _, err := os.Stat(d.Path() + suffix); err == nil {
+			t.Fatalf("下载完成后应删除 %s", suffix)
+		}
+	}
+	sum, size := d.Digest()
+	if size != int64(len(content)) || sum != hexSum(content) {
+		t.Fatalf("摘要不一致: %s %d", sum, size)
+	}
+}
+
+func TestHTTPDownloaderResume(t *testing.T) {
+	content := []byte(strings.Repeat("hotupdater package ", 100))
+	s := newTestServer(t, content, `"v1"`)
+	d := NewHTTPDownloader(s.URL+"/update.pkg", t.TempDir())
+	writePart(t, d, content[:100], `"v1"`)
+
+	if err := d.Execute(context.Background(), nil); err != nil {
+		t.Fatal(err)
+	}
+	checkDownload(t, d, content)
+	if len(s.ranges) != 1 || s.ranges[0] != "bytes=100-" {
+		t.Fatalf("应从第 100 字节续传，请求为 %q", s.ranges)
+	}
+}
+
+func TestHTTPDownloaderRemoteChanged(t *testing.T) {
+	content := []byte(strings.Repeat("new package ", 100))
+	s := newTestServer(t, content, `"v2"`)
+	d := NewHTTPDownloader(s.URL+"/update.pkg", t.TempDir())
+	// 临时文件来自旧版本的包，If-Range 不匹配时服务器返回完整内容
+	writePart(t, d, []byte(strings.Repeat("old package ", 10)), `"v1"`)
+
+	if err := d.Execute(context.Background(), nil); err != nil {
+		t.Fatal(err)
+	}
+	checkDownload(t, d, content)
+}
+
+func TestHTTPDownloaderRangeNotSatisfiable(t *testing.T) {
+	content := []byte("short")
+	s := newTestServer(t, content, `"v1"`)
+	d := NewHTTPDownloader(s.URL+"/update.pkg", t.TempDir())
+	writePart(t, d, []byte("longer than remote"), `"v1"`)
+
+	if err := d.Execute(context.Background(), nil); err != nil {
+		t.Fatal(err)
+	}
+	checkDownload(t, d, content)
+	if len(s.ranges) != 2 || s.ranges[1] != "" {
+		t.Fatalf("416 后应从头下载，请求为 %q", s.ranges)
+	}
+}
+
+func TestHTTPDownloaderRetryAfterInterrupt(t *testing.T) {
+	content := []byte(strings.Repeat("interrupted package ", 100))
+	s := newTestServer(t, content, `"v1"`)
+	s.abortAt = 300
+	d := NewHTTPDownloader(s.URL+"/update.pkg", t.TempDir())
+
+	if err := d.Execute(context.Background(), nil); err == nil {
+		t.Fatal("连接中断时应返回错误")
+	}
+	if info, err := os.Stat(d.Path() + partSuffix); err != nil || info.Size() != 300 {
+		t.Fatalf("应保留已下载的部分: %v", err)
+	}
+
+	if err := d.Execute(context.Background(), nil); err != nil {
+		t.Fatal(err)
+	}
+	checkDownload(t, d, content)
+	if len(s.ranges) != 2 || s.ranges[1] != "bytes=300-" {
+		t.Fatalf("重试时应续传，请求为 %q", s.ranges)
+	}
+}
+
+func TestHTTPDownloaderNoValidator(t *testing.T) {
+	content := []byte(strings.Repeat("package ", 50))
+	s := newTestServer(t, content, `"v1"`)
+	d := NewHTTPDownloader(s.URL+"/update.pkg", t.TempDir())
+	// 没有校验值的临时文件无法确认来自同一个文件，不续传
+	if err := os.WriteFile(filepath.Join(d.Dir, "update.pkg"+partSuffix), []byte("garbage"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Execute(context.Background(), nil); err != nil {
+		t.Fatal(err)
+	}
+	checkDownload(t, d, content)
+	if s.ranges[0] != "" {
+		t.Fatalf("没有校验值时不应续传，请求为 %q", s.ranges)
+	}
+}
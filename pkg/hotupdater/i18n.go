@@ -0,0 +1,591 @@
+package hotupdater
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// 内置语言
+const (
+	LocaleZh = "zh"
+	LocaleEn = "en"
+	LocaleJa = "ja"
+
+	// DefaultLocale Config.Locale 为空或没有对应目录时使用的语言
+	DefaultLocale = LocaleZh
+)
+
+// MessageID 稳定的消息 ID，前端可以据此自行翻译
+type MessageID string
+
+// 进度提示
+const (
+	MsgDownloadProgress     MessageID = "download.progress"
+	MsgVerifyFile           MessageID = "verify.file"
+	MsgVerifyFileFailed     MessageID = "verify.file_failed"
+	MsgVerifyPackage        MessageID = "verify.package"
+	MsgVerifyPackageFailed  MessageID = "verify.package_failed"
+	MsgVerifySignature      MessageID = "verify.signature"
+	MsgVerifySignatureFail  MessageID = "verify.signature_failed"
+	MsgVerifyPassed         MessageID = "verify.passed"
+	MsgVerifyStart          MessageID = "verify.start"
+	MsgVerifyScript         MessageID = "verify.script"
+	MsgVerifyCustom         MessageID = "verify.custom"
+	MsgVerifyRollback       MessageID = "verify.rollback"
+	MsgVerifyRolledBack     MessageID = "verify.rolled_back"
+	MsgVerifyDone           MessageID = "verify.done"
+	MsgPrecheckStart        MessageID = "precheck.start"
+	MsgPrecheckPaths        MessageID = "precheck.paths"
+	MsgPrecheckPermission   MessageID = "precheck.permission"
+	MsgPrecheckDone         MessageID = "precheck.done"
+	MsgBackupPrepare        MessageID = "backup.prepare"
+	MsgBackupCreate         MessageID = "backup.create"
+	MsgBackupVerify         MessageID = "backup.verify"
+	MsgBackupDone           MessageID = "backup.done"
+	MsgBackupVerified       MessageID = "backup.verified"
+	MsgBackupDelete         MessageID = "backup.delete"
+	MsgBackupDeleted        MessageID = "backup.deleted"
+	MsgRestoreStart         MessageID = "restore.start"
+	MsgRestoreDone          MessageID = "restore.done"
+	MsgInstallPrepare       MessageID = "install.prepare"
+	MsgInstallRemoveOld     MessageID = "install.remove_old"
+	MsgInstallCopy          MessageID = "install.copy"
+	MsgInstallCopied        MessageID = "install.copied"
+	MsgInstallPermission    MessageID = "install.permission"
+	MsgInstallReplace       MessageID = "install.replace"
+	MsgInstallRestore       MessageID = "install.restore"
+	MsgInstallDone          MessageID = "install.done"
+	MsgInstallHelperStarted MessageID = "install.helper_started"
+	MsgInstallScriptCreate  MessageID = "install.script_create"
+	MsgInstallRestartPrep   MessageID = "install.restart_prepare"
+	MsgInstallScriptStarted MessageID = "install.script_started"
+	MsgCleanupStart         MessageID = "cleanup.start"
+	MsgCleanupDone          MessageID = "cleanup.done"
+	MsgUpdateFailed         MessageID = "update.failed"
+	MsgUpdateDone           MessageID = "update.done"
+	MsgUpdateRestarting     MessageID = "update.restarting"
+)
+
+// 流水线内置步骤名称
+const (
+	MsgStagePrecheck MessageID = "stage.precheck"
+	MsgStageBackup   MessageID = "stage.backup"
+	MsgStageInstall  MessageID = "stage.install"
+	MsgStageVerify   MessageID = "stage.verify"
+	MsgStageCleanup  MessageID = "stage.cleanup"
+)
+
+// 错误
+const (
+	MsgErrNoDownloadImpl     MessageID = "error.no_download_impl"
+	MsgErrDownloadFailed     MessageID = "error.download_failed"
+	MsgErrPackageMissing     MessageID = "error.package_missing"
+	MsgErrVersionCompare     MessageID = "error.version_compare"
+	MsgErrDowngrade          MessageID = "error.downgrade"
+	MsgErrScriptMissing      MessageID = "error.script_missing"
+	MsgErrScriptFailed       MessageID = "error.script_failed"
+	MsgErrBundleUnknown      MessageID = "error.bundle_unknown"
+	MsgErrAppRoot            MessageID = "error.app_root"
+	MsgErrHelperMissing      MessageID = "error.helper_missing"
+	MsgErrHelperFailed       MessageID = "error.helper_failed"
+	MsgErrStepFailed         MessageID = "error.step_failed"
+	MsgErrStepRollbackFailed MessageID = "error.step_rollback_failed"
+	MsgErrCancelRollback     MessageID = "error.cancel_rollback_failed"
+	MsgErrRollbackStep       MessageID = "error.rollback_step"
+	MsgErrNewVersionInvalid  MessageID = "error.new_version_invalid"
+	MsgErrCurrentInvalid     MessageID = "error.current_version_invalid"
+	MsgErrTypeMismatch       MessageID = "error.type_mismatch"
+	MsgErrPathInTarget       MessageID = "error.path_in_target"
+	MsgErrBackupDir          MessageID = "error.backup_dir"
+	MsgErrCopyNew            MessageID = "error.copy_new"
+	MsgErrChmod              MessageID = "error.chmod"
+	MsgErrMoveOld            MessageID = "error.move_old"
+	MsgErrReplace            MessageID = "error.replace"
+	MsgErrRestorePrevious    MessageID = "error.restore_previous"
+	MsgErrRestoreBackup      MessageID = "error.restore_backup"
+	MsgErrEmptyDir           MessageID = "error.empty_dir"
+	MsgErrEmptyFile          MessageID = "error.empty_file"
+	MsgErrNotExecutable      MessageID = "error.not_executable"
+	MsgErrNotWritable        MessageID = "error.not_writable"
+	MsgErrCheckWritable      MessageID = "error.check_writable"
+	MsgErrCancelled          MessageID = "error.cancelled"
+	MsgErrHookVetoed         MessageID = "error.hook_vetoed"
+	MsgErrRecover            MessageID = "error.recover"
+	MsgErrUnhealthy          MessageID = "error.unhealthy"
+	MsgErrHealthRollback     MessageID = "error.health_rollback"
+	MsgErrBackupCorrupt      MessageID = "error.backup_corrupt"
+	MsgErrBackupTarget       MessageID = "error.backup_target"
+	MsgErrBackupInUse        MessageID = "error.backup_in_use"
+	MsgErrBackupEmpty        MessageID = "error.backup_empty"
+	MsgErrArchiveEmpty       MessageID = "error.archive_empty"
+	MsgErrManifestUnsigned   MessageID = "error.manifest_unsigned"
+	MsgErrManifestNotLoaded  MessageID = "error.manifest_not_loaded"
+	MsgErrFileMissing        MessageID = "error.file_missing"
+	MsgErrFileType           MessageID = "error.file_type"
+	MsgErrSymlinkRead        MessageID = "error.symlink_read"
+	MsgErrSymlinkTarget      MessageID = "error.symlink_target"
+	MsgErrUnlistedFile       MessageID = "error.unlisted_file"
+)
+
+// PhaseMessageID 返回阶段提示信息的消息 ID，如 phase.download
+func PhaseMessageID(phase UpdatePhase) MessageID {
+	return MessageID("phase." + string(phase))
+}
+
+// Catalog 一种语言的消息目录，值为 fmt 格式串
+type Catalog map[MessageID]string
+
+var (
+	catalogMu sync.RWMutex
+	catalogs  = map[string]Catalog{
+		LocaleZh: catalogZh,
+		LocaleEn: catalogEn,
+		LocaleJa: catalogJa,
+	}
+)
+
+// RegisterCatalog 注册或补充一种语言的消息，已有的 ID 会被覆盖
+// 可用于增加新语言、修改内置文案，或为 Lua 脚本提供自定义消息。
+func RegisterCatalog(locale string, messages Catalog) {
+	locale = normalizeLocale(locale)
+
+	catalogMu.Lock()
+	defer catalogMu.Unlock()
+
+	merged := make(Catalog, len(catalogs[locale])+len(messages))
+	for id, text := range catalogs[locale] {
+		merged[id] = text
+	}
+	for id, text := range messages {
+		merged[id] = text
+	}
+	catalogs[locale] = merged
+}
+
+// Localizer 按语言查找消息，nil 时使用 DefaultLocale
+type Localizer struct {
+	locale string
+}
+
+// NewLocalizer 创建指定语言的 Localizer，支持 en-US、zh_CN 等写法
+func NewLocalizer(locale string) *Localizer {
+	return &Localizer{locale: normalizeLocale(locale)}
+}
+
+// Locale 返回实际使用的语言
+func (l *Localizer) Locale() string {
+	if l == nil {
+		return DefaultLocale
+	}
+	return l.locale
+}
+
+// T 查找消息并按 args 格式化，当前语言没有时使用 DefaultLocale，仍没有时返回 ID 本身
+func (l *Localizer) T(id MessageID, args ...interface{}) string {
+	format, ok := lookupMessage(l.Locale(), id)
+	if !ok {
+		format, ok = lookupMessage(DefaultLocale, id)
+	}
+	if !ok {
+		format = string(id)
+	}
+	if len(args) == 0 {
+		return format
+	}
+	return fmt.Sprintf(format, args...)
+}
+
+// Has 判断当前语言或 DefaultLocale 中是否有该消息
+func (l *Localizer) Has(id MessageID) bool {
+	if _, ok := lookupMessage(l.Locale(), id); ok {
+		return true
+	}
+	_, ok := lookupMessage(DefaultLocale, id)
+	return ok
+}
+
+// Catalog 返回当前语言的全部消息（已合并 DefaultLocale），用于传给更新助手进程
+func (l *Localizer) Catalog() Catalog {
+	catalogMu.RLock()
+	defer catalogMu.RUnlock()
+
+	result := make(Catalog)
+	for id, text := range catalogs[DefaultLocale] {
+		result[id] = text
+	}
+	for id, text := range catalogs[l.Locale()] {
+		result[id] = text
+	}
+	return result
+}
+
+// newError 创建本地化错误
+func (l *Localizer) newError(id MessageID, args ...interface{}) error {
+	return &LocalizedError{ID: id, Text: l.T(id, args...)}
+}
+
+// wrap 创建本地化错误，cause 的内容追加在消息之后
+func (l *Localizer) wrap(id MessageID, cause error, args ...interface{}) error {
+	text := l.T(id, args...)
+	if cause != nil {
+		text += ": " + cause.Error()
+	}
+	return &LocalizedError{ID: id, Text: text, Err: cause}
+}
+
+// errorOf 创建本地化错误，消息本身已包含 cause 的内容
+func (l *Localizer) errorOf(id MessageID, cause error, args ...interface{}) error {
+	return &LocalizedError{ID: id, Text: l.T(id, args...), Err: cause}
+}
+
+func lookupMessage(locale string, id MessageID) (string, bool) {
+	catalogMu.RLock()
+	defer catalogMu.RUnlock()
+	text, ok := catalogs[locale][id]
+	return text, ok
+}
+
+// normalizeLocale 取语言部分并转为小写，为空时返回 DefaultLocale
+func normalizeLocale(locale string) string {
+	locale = strings.ToLower(strings.TrimSpace(locale))
+	if i := strings.IndexAny(locale, "-_."); i >= 0 {
+		locale = locale[:i]
+	}
+	if locale == "" {
+		return DefaultLocale
+	}
+	return locale
+}
+
+// localizer 返回 Config.Locale 对应的 Localizer
+func (c Config) localizer() *Localizer {
+	return NewLocalizer(c.Locale)
+}
+
+var catalogZh = Catalog{
+	"phase.download": "正在下载更新...",
+	"phase.precheck": "正在准备更新...",
+	"phase.backup":   "正在备份当前版本...",
+	"phase.install":  "正在安装新版本...",
+	"phase.verify":   "正在验证安装...",
+	"phase.complete": "更新完成",
+
+	MsgDownloadProgress:     "正在下载更新包...",
+	MsgVerifyFile:           "正在校验更新文件...",
+	MsgVerifyFileFailed:     "更新文件校验失败",
+	MsgVerifyPackage:        "正在校验更新包...",
+	MsgVerifyPackageFailed:  "更新包校验失败",
+	MsgVerifySignature:      "正在校验更新包签名...",
+	MsgVerifySignatureFail:  "更新包签名校验失败",
+	MsgVerifyPassed:         "更新包校验通过",
+	MsgVerifyStart:          "开始验证...",
+	MsgVerifyScript:         "正在验证更新脚本...",
+	MsgVerifyCustom:         "正在执行自定义检查...",
+	MsgVerifyRollback:       "发现问题，准备回滚...",
+	MsgVerifyRolledBack:     "已回滚到备份版本",
+	MsgVerifyDone:           "验证完成",
+	MsgPrecheckStart:        "正在检查更新环境...",
+	MsgPrecheckPaths:        "正在检查路径...",
+	MsgPrecheckPermission:   "正在检查写入权限...",
+	MsgPrecheckDone:         "环境检查完成",
+	MsgBackupPrepare:        "准备备份...",
+	MsgBackupCreate:         "正在创建备份...",
+	MsgBackupVerify:         "正在验证备份...",
+	MsgBackupDone:           "备份完成",
+	MsgBackupVerified:       "备份校验通过",
+	MsgBackupDelete:         "正在删除备份...",
+	MsgBackupDeleted:        "备份已删除",
+	MsgRestoreStart:         "正在从备份恢复...",
+	MsgRestoreDone:          "恢复完成",
+	MsgInstallPrepare:       "准备安装新版本...",
+	MsgInstallRemoveOld:     "正在删除旧版本...",
+	MsgInstallCopy:          "正在复制新版本...",
+	MsgInstallCopied:        "复制完成",
+	MsgInstallPermission:    "正在设置权限...",
+	MsgInstallReplace:       "正在替换旧版本...",
+	MsgInstallRestore:       "正在恢复旧版本...",
+	MsgInstallDone:          "安装完成",
+	MsgInstallHelperStarted: "更新助手已启动",
+	MsgInstallScriptCreate:  "正在创建更新脚本...",
+	MsgInstallRestartPrep:   "正在准备重启程序...",
+	MsgInstallScriptStarted: "更新脚本已启动...",
+	MsgCleanupStart:         "正在清理...",
+	MsgCleanupDone:          "清理完成",
+	MsgUpdateFailed:         "更新失败",
+	MsgUpdateDone:           "更新完成",
+	MsgUpdateRestarting:     "更新完成，准备重启...",
+
+	MsgStagePrecheck: "环境检查",
+	MsgStageBackup:   "备份",
+	MsgStageInstall:  "安装",
+	MsgStageVerify:   "验证",
+	MsgStageCleanup:  "清理",
+
+	MsgErrNoDownloadImpl:     "未提供下载实现",
+	MsgErrDownloadFailed:     "下载失败",
+	MsgErrPackageMissing:     "新版本不存在",
+	MsgErrVersionCompare:     "无法比较版本号",
+	MsgErrDowngrade:          "目标版本不高于当前版本: 当前版本 %s，目标版本 %s",
+	MsgErrScriptMissing:      "更新脚本不存在: %s",
+	MsgErrScriptFailed:       "执行更新脚本失败",
+	MsgErrBundleUnknown:      "无法确定应用程序包路径",
+	MsgErrAppRoot:            "无法获取应用根目录",
+	MsgErrHelperMissing:      "更新助手不存在: %s",
+	MsgErrHelperFailed:       "更新助手执行失败",
+	MsgErrStepFailed:         "%s失败",
+	MsgErrStepRollbackFailed: "%s失败且回滚失败: %v (原始错误: %v)",
+	MsgErrCancelRollback:     "更新已取消且回滚失败: %v (原始错误: %v)",
+	MsgErrRollbackStep:       "回滚%s失败",
+	MsgErrNewVersionInvalid:  "新版本路径无效",
+	MsgErrCurrentInvalid:     "当前版本路径无效",
+	MsgErrTypeMismatch:       "新版本与当前版本类型不一致: %s",
+	MsgErrPathInTarget:       "%s 位于被替换的安装目录 %s 中，更新后会被删除，请配置到安装目录之外",
+	MsgErrBackupDir:          "创建备份目录失败",
+	MsgErrCopyNew:            "复制新版本失败",
+	MsgErrChmod:              "设置执行权限失败",
+	MsgErrMoveOld:            "移除旧版本失败",
+	MsgErrReplace:            "替换旧版本失败",
+	MsgErrRestorePrevious:    "无法恢复旧版本: %s",
+	MsgErrRestoreBackup:      "从备份恢复失败",
+	MsgErrEmptyDir:           "新版本目录为空: %s",
+	MsgErrEmptyFile:          "新版本文件为空: %s",
+	MsgErrNotExecutable:      "新版本文件不可执行: %s",
+	MsgErrNotWritable:        "没有写入权限: %s",
+	MsgErrCheckWritable:      "检查写入权限失败",
+	MsgErrCancelled:          "更新已取消",
+	MsgErrHookVetoed:         "钩子 %s 否决了更新",
+	MsgErrRecover:            "恢复中断的更新失败",
+	MsgErrUnhealthy:          "新版本 %s 未在 %s 内确认运行正常",
+	MsgErrHealthRollback:     "新版本未确认运行正常，恢复旧版本失败",
+	MsgErrBackupCorrupt:      "备份已损坏: %s",
+	MsgErrBackupTarget:       "无法确定备份的恢复位置: %s",
+	MsgErrBackupInUse:        "备份正被等待健康确认的更新使用: %s",
+	MsgErrBackupEmpty:        "备份文件为空: %s",
+	MsgErrArchiveEmpty:       "归档为空: %s",
+	MsgErrManifestUnsigned:   "更新包为目录，下载实现没有校验文件清单签名: %s",
+	MsgErrManifestNotLoaded:  "文件清单尚未下载",
+	MsgErrFileMissing:        "缺少文件: %s",
+	MsgErrFileType:           "文件类型与清单不一致 %s: %s，期望 %s",
+	MsgErrSymlinkRead:        "读取符号链接失败 %s: %v",
+	MsgErrSymlinkTarget:      "符号链接目标与清单不一致 %s: %s，期望 %s",
+	MsgErrUnlistedFile:       "清单中没有的文件: %s",
+}
+
+var catalogEn = Catalog{
+	"phase.download": "Downloading update...",
+	"phase.precheck": "Preparing update...",
+	"phase.backup":   "Backing up current version...",
+	"phase.install":  "Installing new version...",
+	"phase.verify":   "Verifying installation...",
+	"phase.complete": "Update complete",
+
+	MsgDownloadProgress:     "Downloading update package...",
+	MsgVerifyFile:           "Verifying update files...",
+	MsgVerifyFileFailed:     "Update file verification failed",
+	MsgVerifyPackage:        "Verifying update package...",
+	MsgVerifyPackageFailed:  "Update package verification failed",
+	MsgVerifySignature:      "Verifying update package signature...",
+	MsgVerifySignatureFail:  "Update package signature verification failed",
+	MsgVerifyPassed:         "Update package verified",
+	MsgVerifyStart:          "Starting verification...",
+	MsgVerifyScript:         "Verifying update script...",
+	MsgVerifyCustom:         "Running custom checks...",
+	MsgVerifyRollback:       "Problem detected, preparing to roll back...",
+	MsgVerifyRolledBack:     "Rolled back to the backup version",
+	MsgVerifyDone:           "Verification complete",
+	MsgPrecheckStart:        "Checking update environment...",
+	MsgPrecheckPaths:        "Checking paths...",
+	MsgPrecheckPermission:   "Checking write permission...",
+	MsgPrecheckDone:         "Environment check complete",
+	MsgBackupPrepare:        "Preparing backup...",
+	MsgBackupCreate:         "Creating backup...",
+	MsgBackupVerify:         "Verifying backup...",
+	MsgBackupDone:           "Backup complete",
+	MsgBackupVerified:       "Backup verified",
+	MsgBackupDelete:         "Deleting backup...",
+	MsgBackupDeleted:        "Backup deleted",
+	MsgRestoreStart:         "Restoring from backup...",
+	MsgRestoreDone:          "Restore complete",
+	MsgInstallPrepare:       "Preparing to install new version...",
+	MsgInstallRemoveOld:     "Removing old version...",
+	MsgInstallCopy:          "Copying new version...",
+	MsgInstallCopied:        "Copy complete",
+	MsgInstallPermission:    "Setting permissions...",
+	MsgInstallReplace:       "Replacing old version...",
+	MsgInstallRestore:       "Restoring old version...",
+	MsgInstallDone:          "Installation complete",
+	MsgInstallHelperStarted: "Update helper started",
+	MsgInstallScriptCreate:  "Creating update script...",
+	MsgInstallRestartPrep:   "Preparing to restart...",
+	MsgInstallScriptStarted: "Update script started...",
+	MsgCleanupStart:         "Cleaning up...",
+	MsgCleanupDone:          "Cleanup complete",
+	MsgUpdateFailed:         "Update failed",
+	MsgUpdateDone:           "Update complete",
+	MsgUpdateRestarting:     "Update complete, restarting...",
+
+	MsgStagePrecheck: "Environment check",
+	MsgStageBackup:   "Backup",
+	MsgStageInstall:  "Installation",
+	MsgStageVerify:   "Verification",
+	MsgStageCleanup:  "Cleanup",
+
+	MsgErrNoDownloadImpl:     "no download implementation provided",
+	MsgErrDownloadFailed:     "download failed",
+	MsgErrPackageMissing:     "new version not found",
+	MsgErrVersionCompare:     "cannot compare versions",
+	MsgErrDowngrade:          "target version is not newer than the current version: current %s, target %s",
+	MsgErrScriptMissing:      "update script not found: %s",
+	MsgErrScriptFailed:       "update script failed",
+	MsgErrBundleUnknown:      "cannot determine the application bundle path",
+	MsgErrAppRoot:            "cannot determine the application root directory",
+	MsgErrHelperMissing:      "update helper not found: %s",
+	MsgErrHelperFailed:       "update helper failed",
+	MsgErrStepFailed:         "%s failed",
+	MsgErrStepRollbackFailed: "%s failed and rollback failed: %v (original error: %v)",
+	MsgErrCancelRollback:     "update cancelled and rollback failed: %v (original error: %v)",
+	MsgErrRollbackStep:       "rollback of %s failed",
+	MsgErrNewVersionInvalid:  "invalid new version path",
+	MsgErrCurrentInvalid:     "invalid current version path",
+	MsgErrTypeMismatch:       "new version type does not match the current version: %s",
+	MsgErrPathInTarget:       "%s is inside the install directory %s being replaced and would be deleted by the update; configure it outside the install directory",
+	MsgErrBackupDir:          "failed to create backup directory",
+	MsgErrCopyNew:            "failed to copy new version",
+	MsgErrChmod:              "failed to set executable permission",
+	MsgErrMoveOld:            "failed to move old version aside",
+	MsgErrReplace:            "failed to replace old version",
+	MsgErrRestorePrevious:    "cannot restore old version: %s",
+	MsgErrRestoreBackup:      "failed to restore from backup",
+	MsgErrEmptyDir:           "new version directory is empty: %s",
+	MsgErrEmptyFile:          "new version file is empty: %s",
+	MsgErrNotExecutable:      "new version file is not executable: %s",
+	MsgErrNotWritable:        "no write permission: %s",
+	MsgErrCheckWritable:      "failed to check write permission",
+	MsgErrCancelled:          "update cancelled",
+	MsgErrHookVetoed:         "the %s hook vetoed the update",
+	MsgErrRecover:            "failed to recover the interrupted update",
+	MsgErrUnhealthy:          "version %s was not confirmed healthy within %s",
+	MsgErrHealthRollback:     "version was not confirmed healthy and restoring the previous version failed",
+	MsgErrBackupCorrupt:      "backup is corrupt: %s",
+	MsgErrBackupTarget:       "cannot determine where to restore backup: %s",
+	MsgErrBackupInUse:        "backup is in use by an update awaiting health confirmation: %s",
+	MsgErrBackupEmpty:        "backup file is empty: %s",
+	MsgErrArchiveEmpty:       "archive is empty: %s",
+	MsgErrManifestUnsigned:   "update package is a directory and the downloader did not verify the file manifest signature: %s",
+	MsgErrManifestNotLoaded:  "file manifest has not been downloaded",
+	MsgErrFileMissing:        "missing file: %s",
+	MsgErrFileType:           "file type does not match the manifest %s: %s, expected %s",
+	MsgErrSymlinkRead:        "failed to read symlink %s: %v",
+	MsgErrSymlinkTarget:      "symlink target does not match the manifest %s: %s, expected %s",
+	MsgErrUnlistedFile:       "file not in the manifest: %s",
+}
+
+var catalogJa = Catalog{
+	"phase.download": "アップデートをダウンロードしています...",
+	"phase.precheck": "アップデートを準備しています...",
+	"phase.backup":   "現在のバージョンをバックアップしています...",
+	"phase.install":  "新しいバージョンをインストールしています...",
+	"phase.verify":   "インストールを検証しています...",
+	"phase.complete": "アップデートが完了しました",
+
+	MsgDownloadProgress:     "アップデートパッケージをダウンロードしています...",
+	MsgVerifyFile:           "アップデートファイルを検証しています...",
+	MsgVerifyFileFailed:     "アップデートファイルの検証に失敗しました",
+	MsgVerifyPackage:        "アップデートパッケージを検証しています...",
+	MsgVerifyPackageFailed:  "アップデートパッケージの検証に失敗しました",
+	MsgVerifySignature:      "アップデートパッケージの署名を検証しています...",
+	MsgVerifySignatureFail:  "アップデートパッケージの署名検証に失敗しました",
+	MsgVerifyPassed:         "アップデートパッケージの検証に成功しました",
+	MsgVerifyStart:          "検証を開始しています...",
+	MsgVerifyScript:         "アップデートスクリプトを検証しています...",
+	MsgVerifyCustom:         "カスタムチェックを実行しています...",
+	MsgVerifyRollback:       "問題が見つかりました。ロールバックを準備しています...",
+	MsgVerifyRolledBack:     "バックアップのバージョンにロールバックしました",
+	MsgVerifyDone:           "検証が完了しました",
+	MsgPrecheckStart:        "アップデート環境を確認しています...",
+	MsgPrecheckPaths:        "パスを確認しています...",
+	MsgPrecheckPermission:   "書き込み権限を確認しています...",
+	MsgPrecheckDone:         "環境の確認が完了しました",
+	MsgBackupPrepare:        "バックアップを準備しています...",
+	MsgBackupCreate:         "バックアップを作成しています...",
+	MsgBackupVerify:         "バックアップを検証しています...",
+	MsgBackupDone:           "バックアップが完了しました",
+	MsgBackupVerified:       "バックアップの検証に成功しました",
+	MsgBackupDelete:         "バックアップを削除しています...",
+	MsgBackupDeleted:        "バックアップを削除しました",
+	MsgRestoreStart:         "バックアップから復元しています...",
+	MsgRestoreDone:          "復元が完了しました",
+	MsgInstallPrepare:       "新しいバージョンのインストールを準備しています...",
+	MsgInstallRemoveOld:     "古いバージョンを削除しています...",
+	MsgInstallCopy:          "新しいバージョンをコピーしています...",
+	MsgInstallCopied:        "コピーが完了しました",
+	MsgInstallPermission:    "権限を設定しています...",
+	MsgInstallReplace:       "古いバージョンを置き換えています...",
+	MsgInstallRestore:       "古いバージョンを復元しています...",
+	MsgInstallDone:          "インストールが完了しました",
+	MsgInstallHelperStarted: "アップデートヘルパーを起動しました",
+	MsgInstallScriptCreate:  "アップデートスクリプトを作成しています...",
+	MsgInstallRestartPrep:   "再起動を準備しています...",
+	MsgInstallScriptStarted: "アップデートスクリプトを起動しました...",
+	MsgCleanupStart:         "クリーンアップしています...",
+	MsgCleanupDone:          "クリーンアップが完了しました",
+	MsgUpdateFailed:         "アップデートに失敗しました",
+	MsgUpdateDone:           "アップデートが完了しました",
+	MsgUpdateRestarting:     "アップデートが完了しました。再起動します...",
+
+	MsgStagePrecheck: "環境チェック",
+	MsgStageBackup:   "バックアップ",
+	MsgStageInstall:  "インストール",
+	MsgStageVerify:   "検証",
+	MsgStageCleanup:  "クリーンアップ",
+
+	MsgErrNoDownloadImpl:     "ダウンロード実装が指定されていません",
+	MsgErrDownloadFailed:     "ダウンロードに失敗しました",
+	MsgErrPackageMissing:     "新しいバージョンが見つかりません",
+	MsgErrVersionCompare:     "バージョンを比較できません",
+	MsgErrDowngrade:          "対象バージョンが現在のバージョンより新しくありません: 現在 %s、対象 %s",
+	MsgErrScriptMissing:      "アップデートスクリプトが見つかりません: %s",
+	MsgErrScriptFailed:       "アップデートスクリプトの実行に失敗しました",
+	MsgErrBundleUnknown:      "アプリケーションバンドルのパスを特定できません",
+	MsgErrAppRoot:            "アプリケーションのルートディレクトリを取得できません",
+	MsgErrHelperMissing:      "アップデートヘルパーが見つかりません: %s",
+	MsgErrHelperFailed:       "アップデートヘルパーの実行に失敗しました",
+	MsgErrStepFailed:         "%sに失敗しました",
+	MsgErrStepRollbackFailed: "%sに失敗し、ロールバックにも失敗しました: %v (元のエラー: %v)",
+	MsgErrCancelRollback:     "アップデートがキャンセルされ、ロールバックに失敗しました: %v (元のエラー: %v)",
+	MsgErrRollbackStep:       "%sのロールバックに失敗しました",
+	MsgErrNewVersionInvalid:  "新しいバージョンのパスが無効です",
+	MsgErrCurrentInvalid:     "現在のバージョンのパスが無効です",
+	MsgErrTypeMismatch:       "新しいバージョンの種類が現在のバージョンと一致しません: %s",
+	MsgErrPathInTarget:       "%s は置き換えるインストールディレクトリ %s の中にあり、更新で削除されます。インストールディレクトリの外に設定してください",
+	MsgErrBackupDir:          "バックアップディレクトリの作成に失敗しました",
+	MsgErrCopyNew:            "新しいバージョンのコピーに失敗しました",
+	MsgErrChmod:              "実行権限の設定に失敗しました",
+	MsgErrMoveOld:            "古いバージョンの移動に失敗しました",
+	MsgErrReplace:            "古いバージョンの置き換えに失敗しました",
+	MsgErrRestorePrevious:    "古いバージョンを復元できません: %s",
+	MsgErrRestoreBackup:      "バックアップからの復元に失敗しました",
+	MsgErrEmptyDir:           "新しいバージョンのディレクトリが空です: %s",
+	MsgErrEmptyFile:          "新しいバージョンのファイルが空です: %s",
+	MsgErrNotExecutable:      "新しいバージョンのファイルが実行可能ではありません: %s",
+	MsgErrNotWritable:        "書き込み権限がありません: %s",
+	MsgErrCheckWritable:      "書き込み権限の確認に失敗しました",
+	MsgErrCancelled:          "アップデートがキャンセルされました",
+	MsgErrHookVetoed:         "%s フックがアップデートを拒否しました",
+	MsgErrRecover:            "中断されたアップデートの復旧に失敗しました",
+	MsgErrUnhealthy:          "バージョン %s は %s 以内に正常動作が確認されませんでした",
+	MsgErrHealthRollback:     "正常動作が確認されず、旧バージョンの復元に失敗しました",
+	MsgErrBackupCorrupt:      "バックアップが破損しています: %s",
+	MsgErrBackupTarget:       "バックアップの復元先を特定できません: %s",
+	MsgErrBackupInUse:        "バックアップは正常動作の確認を待つアップデートで使用中です: %s",
+	MsgErrBackupEmpty:        "バックアップファイルが空です: %s",
+	MsgErrArchiveEmpty:       "アーカイブが空です: %s",
+	MsgErrManifestUnsigned:   "アップデートパッケージはディレクトリですが、ダウンロード実装がファイルマニフェストの署名を検証していません: %s",
+	MsgErrManifestNotLoaded:  "ファイルマニフェストがまだダウンロードされていません",
+	MsgErrFileMissing:        "ファイルがありません: %s",
+	MsgErrFileType:           "ファイルの種類がマニフェストと一致しません %s: %s、期待値 %s",
+	MsgErrSymlinkRead:        "シンボリックリンクの読み取りに失敗しました %s: %v",
+	MsgErrSymlinkTarget:      "シンボリックリンクのリンク先がマニフェストと一致しません %s: %s、期待値 %s",
+	MsgErrUnlistedFile:       "マニフェストにないファイル: %s",
+}
@@ -0,0 +1,85 @@
+package hotupdater
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// Digester 可在下载过程中同步计算摘要的下载实现
+// Downloader 在下载完成后会用它立即比对 Config 中的期望值，无需再次读取文件。
+type Digester interface {
+	// Digest 返回已下载内容的 SHA-256（十六进制小写）和字节数
+	Digest() (sha256Hex string, size int64)
+}
+
+// PackageVerifier 自行完成更新包校验的下载实现
+// FastUpdater 在安装前先调用它，再按 Config 中的摘要和签名校验，
+// 用于暂存目录这类无法整体计算摘要的更新包。
+type PackageVerifier interface {
+	VerifyPackage(path string) error
+}
+
+// ManifestVerifier 下载前校验过文件清单签名的下载实现
+// 更新包为目录时无法整体签名，配置了 Config.PublicKeys 时 FastUpdater 据此确认清单签名已校验。
+type ManifestVerifier interface {
+	ManifestVerified() bool
+}
+
+// FileSHA256 计算文件的 SHA-256 和大小
+func FileSHA256(path string) (string, int64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", 0, err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	size, err := io.Copy(h, f)
+	if err != nil {
+		return "", 0, err
+	}
+	return hex.EncodeToString(h.Sum(nil)), size, nil
+}
+
+// VerifyPackage 校验更新包的 SHA-256 和大小
+// expectedSHA256 为空时只校验大小，expectedSize 为 0 时不校验大小。
+func VerifyPackage(path, expectedSHA256 string, expectedSize int64) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	if info.IsDir() {
+		return fmt.Errorf("完整性校验只支持单个文件: %s", path)
+	}
+
+	sum, size, err := FileSHA256(path)
+	if err != nil {
+		return fmt.Errorf("计算文件摘要失败: %w", err)
+	}
+	return checkDigest(path, expectedSHA256, expectedSize, sum, size)
+}
+
+// checkDigest 比对摘要和大小，不一致时返回 *IntegrityError
+func checkDigest(path, expectedSHA256 string, expectedSize int64, actualSHA256 string, actualSize int64) error {
+	sizeMismatch := expectedSize > 0 && expectedSize != actualSize
+	hashMismatch := expectedSHA256 != "" && !strings.EqualFold(expectedSHA256, actualSHA256)
+	if !sizeMismatch && !hashMismatch {
+		return nil
+	}
+	return &IntegrityError{
+		Path:           path,
+		ExpectedSHA256: strings.ToLower(expectedSHA256),
+		ActualSHA256:   actualSHA256,
+		ExpectedSize:   expectedSize,
+		ActualSize:     actualSize,
+	}
+}
+
+// hasIntegrityCheck 是否配置了完整性校验
+func (c Config) hasIntegrityCheck() bool {
+	return c.PackageSHA256 != "" || c.PackageSize > 0
+}
@@ -0,0 +1,289 @@
+package hotupdater
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// JournalFile 更新日志的文件名，位于 Config.UpdatePath
+const JournalFile = "update.journal"
+
+// JournalState 更新日志记录的进度，每一步在执行之前写入
+type JournalState string
+
+const (
+	JournalBegin       JournalState = "begin"        // 开始更新，尚未修改应用
+	JournalBackingUp   JournalState = "backing_up"   // 正在创建 BackupFile
+	JournalBackedUp    JournalState = "backed_up"    // 备份完成
+	JournalInstalling  JournalState = "installing"   // 开始替换 Target，此后应用可能不完整
+	JournalInstalled   JournalState = "installed"    // 新版本已完整替换 Target
+	JournalRollingBack JournalState = "rolling_back" // 开始把 Target 恢复为旧版本，此后应用可能不完整
+	JournalCommitted   JournalState = "committed"    // 更新结束（完成或已回滚），日志可以删除
+)
+
+// JournalEntry 更新日志的一行，每行都是 JSON 对象，后面的行覆盖前面行中的非空字段
+// 批处理等外部程序可以只追加 {"state":"..."} 来推进状态。
+type JournalEntry struct {
+	State          JournalState `json:"state"`
+	Time           int64        `json:"time,omitempty"` // 写入时间（Unix 毫秒）
+	Target         string       `json:"target,omitempty"`
+	NewVersion     string       `json:"new_version,omitempty"`
+	BackupFile     string       `json:"backup_file,omitempty"`
+	Previous       string       `json:"previous,omitempty"` // 被重命名保留的旧版本，为空表示原地替换
+	CurrentVersion string       `json:"current_version,omitempty"`
+	UpdateVersion  string       `json:"update_version,omitempty"`
+}
+
+// merge 用 e 中的非空字段覆盖 base
+func (e JournalEntry) merge(base JournalEntry) JournalEntry {
+	if e.State != "" {
+		base.State = e.State
+	}
+	if e.Time != 0 {
+		base.Time = e.Time
+	}
+	if e.Target != "" {
+		base.Target = e.Target
+	}
+	if e.NewVersion != "" {
+		base.NewVersion = e.NewVersion
+	}
+	if e.BackupFile != "" {
+		base.BackupFile = e.BackupFile
+	}
+	if e.Previous != "" {
+		base.Previous = e.Previous
+	}
+	if e.CurrentVersion != "" {
+		base.CurrentVersion = e.CurrentVersion
+	}
+	if e.UpdateVersion != "" {
+		base.UpdateVersion = e.UpdateVersion
+	}
+	return base
+}
+
+// Journal 预写式更新日志：每一步修改应用之前先追加一行并落盘，
+// 断电或崩溃后由 Recover 根据最后的状态完成或回滚更新。方法对 nil 安全，nil 表示不记录。
+type Journal struct {
+	path  string
+	entry JournalEntry
+}
+
+// OpenJournal 在 dir 中创建更新日志，dir 为空时返回 nil
+// 已有的日志会被覆盖，调用前应先执行 Recover。
+func OpenJournal(dir string) (*Journal, error) {
+	if dir == "" {
+		return nil, nil
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	path := filepath.Join(dir, JournalFile)
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	return &Journal{path: path}, nil
+}
+
+// ResumeJournal 继续记录 dir 中已有的更新日志，用于接手脚本开始的更新（如 Windows 更新助手）
+// 没有日志时创建新的日志，dir 为空时返回 nil。
+func ResumeJournal(dir string) (*Journal, error) {
+	if dir == "" {
+		return nil, nil
+	}
+	entry, err := ReadJournal(dir)
+	if err != nil {
+		return nil, err
+	}
+	j := &Journal{path: filepath.Join(dir, JournalFile)}
+	if entry != nil {
+		j.entry = *entry
+	}
+	return j, nil
+}
+
+// Path 返回日志文件路径
+func (j *Journal) Path() string {
+	if j == nil {
+		return ""
+	}
+	return j.path
+}
+
+// Record 合并 entry 并追加一行，返回前会同步到磁盘
+func (j *Journal) Record(entry JournalEntry) error {
+	if j == nil {
+		return nil
+	}
+	entry.Time = time.Now().UnixMilli()
+	j.entry = entry.merge(j.entry)
+	return appendJournal(j.path, j.entry)
+}
+
+// Commit 更新结束后删除日志
+func (j *Journal) Commit() error {
+	if j == nil {
+		return nil
+	}
+	if err := os.Remove(j.path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// appendJournal 追加一行并同步到磁盘
+func appendJournal(path string, entry JournalEntry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// ReadJournal 读取 dir 中的更新日志，没有日志时返回 nil
+// 无法解析的行（如写入一半时断电）会被忽略。
+func ReadJournal(dir string) (*JournalEntry, error) {
+	f, err := os.Open(filepath.Join(dir, JournalFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var entry JournalEntry
+	found := false
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var line JournalEntry
+		if err := json.Unmarshal(scanner.Bytes(), &line); err != nil {
+			continue
+		}
+		entry = line.merge(entry)
+		found = true
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if !found {
+		return &JournalEntry{}, nil
+	}
+	return &entry, nil
+}
+
+// RecoveryAction Recover 对中断的更新所做的处理
+type RecoveryAction string
+
+const (
+	RecoveryNone       RecoveryAction = "none"        // 没有中断的更新
+	RecoveryDiscarded  RecoveryAction = "discarded"   // 应用未被修改，只删除日志和不完整的备份
+	RecoveryCompleted  RecoveryAction = "completed"   // 新版本已完整替换，完成收尾
+	RecoveryRolledBack RecoveryAction = "rolled_back" // 替换到一半，已恢复旧版本
+)
+
+// Recovery Recover 的结果
+type Recovery struct {
+	Action RecoveryAction
+	Entry  JournalEntry // 中断时的日志内容
+}
+
+// Recover 检查 config.UpdatePath 中的更新日志，完成或回滚上次被中断的更新
+// 应在应用（或更新助手）启动时、开始新的更新之前调用：
+//
+//	if r, err := hotupdater.Recover(config); err != nil {
+//		log.Printf("恢复中断的更新失败，请使用恢复助手: %v", err)
+//	} else if r.Action == hotupdater.RecoveryRolledBack {
+//		log.Printf("上次更新被中断，已恢复到 %s", r.Entry.CurrentVersion)
+//	}
+//
+// 替换开始之前中断时只丢弃日志；新版本已完整替换时删除保留的旧版本；替换或回滚到一半时
+// 优先把保留的旧版本重命名回来，否则从备份恢复。恢复失败时保留日志，返回的错误匹配 ErrRollbackFailed。
+func Recover(config Config) (*Recovery, error) {
+	if config.UpdatePath == "" {
+		return &Recovery{Action: RecoveryNone}, nil
+	}
+	entry, err := ReadJournal(config.UpdatePath)
+	if err != nil || entry == nil {
+		return &Recovery{Action: RecoveryNone}, err
+	}
+
+	logf := func(format string, args ...interface{}) {
+		if config.Logger != nil {
+			config.Logger.Logf(format, args...)
+		}
+	}
+	logf("发现中断的更新，状态: %s", entry.State)
+
+	r := &Recovery{Entry: *entry}
+	switch entry.State {
+	case JournalInstalling, JournalRollingBack:
+		if err := recoverInstalling(*entry); err != nil {
+			logf("恢复旧版本失败: %v", err)
+			return r, &UpdateError{Kind: ErrInstallFailed, Phase: PhaseInstall, Err: config.localizer().wrap(MsgErrRecover, err), RollbackErr: err}
+		}
+		logf("已恢复旧版本: %s", entry.Target)
+		r.Action = RecoveryRolledBack
+	case JournalInstalled:
+		if entry.Previous != "" {
+			if err := os.RemoveAll(entry.Previous); err != nil {
+				logf("清理旧版本失败: %v", err)
+			}
+		}
+		r.Action = RecoveryCompleted
+	case JournalCommitted:
+		r.Action = RecoveryNone
+	default:
+		// 备份中断时删除不完整的备份文件
+		if entry.State == JournalBackingUp && entry.BackupFile != "" {
+			os.Remove(entry.BackupFile)
+		}
+		r.Action = RecoveryDiscarded
+	}
+
+	if err := os.Remove(filepath.Join(config.UpdatePath, JournalFile)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return r, err
+	}
+	return r, nil
+}
+
+// recoverInstalling 回滚替换或回滚到一半的更新
+func recoverInstalling(entry JournalEntry) error {
+	if entry.Target == "" {
+		return errors.New("更新日志缺少 target")
+	}
+	if entry.Previous != "" {
+		os.RemoveAll(entry.Target + ".new")
+		if _, err := os.Lstat(entry.Previous); err == nil {
+			if err := os.RemoveAll(entry.Target); err != nil {
+				return err
+			}
+			return os.Rename(entry.Previous, entry.Target)
+		}
+		// 替换时旧版本还没有被移走，Target 仍是旧版本；回滚时无法区分旧版本是已移回还是已丢失，从备份恢复
+		if _, err := os.Lstat(entry.Target); err == nil && entry.State != JournalRollingBack {
+			return nil
+		}
+	}
+	if entry.BackupFile == "" {
+		return errors.New("更新日志缺少 backup_file，无法恢复")
+	}
+	return restoreBackupFile(entry.BackupFile, entry.Target)
+}
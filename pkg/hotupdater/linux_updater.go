@@ -0,0 +1,117 @@
+//go:build linux
+// +build linux
+
+package hotupdater
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"syscall"
+
+	lua "github.com/yuin/gopher-lua"
+)
+
+// LinuxUpdater Linux 更新器
+// 配置了 Pipeline 时执行该流水线，否则配置了 ScriptPath 时由 Lua 脚本完成更新，
+// 两者都未配置时使用 DefaultPipeline。
+// 新版本为单个文件时替换当前可执行文件，为目录时替换可执行文件所在目录。
+type LinuxUpdater struct {
+	config     Config
+	ctx        context.Context
+	luaState   *lua.LState
+	currentExe string
+	helper     *helper
+}
+
+func newPlatformUpdater(config Config, ctx context.Context) Updater {
+	return newLinuxUpdater(config, ctx)
+}
+
+func newLinuxUpdater(config Config, ctx context.Context) *LinuxUpdater {
+	exe, _ := os.Executable()
+	if resolved, err := filepath.EvalSymlinks(exe); err == nil {
+		exe = resolved
+	}
+	return &LinuxUpdater{
+		config:     config,
+		ctx:        ctx,
+		luaState:   NewLuaState(config.LuaSandbox),
+		currentExe: exe,
+		helper:     newHelper(config),
+	}
+}
+
+func (l *LinuxUpdater) Update(newVersion string) error {
+	l.sendLog("当前程序路径: %s", l.currentExe)
+	l.sendLog("新版本路径: %s", newVersion)
+
+	switch {
+	case l.config.Pipeline != nil:
+		return l.helper.runPipeline(l.ctx, l.config.Pipeline, l.config, l.currentExe, newVersion)
+	case l.config.ScriptPath != "":
+		return l.updateWithScript(newVersion)
+	}
+	return l.helper.runPipeline(l.ctx, DefaultPipeline(), l.config, l.currentExe, newVersion)
+}
+
+// updateWithScript 使用 Lua 脚本执行更新
+func (l *LinuxUpdater) updateWithScript(newVersion string) error {
+	if _, err := os.Stat(l.config.ScriptPath); err != nil {
+		return newUpdateError(ErrScriptFailed, PhasePreCheck, l.helper.l.wrap(MsgErrScriptMissing, err, l.config.ScriptPath))
+	}
+
+	params := map[string]string{
+		"app_path":        l.currentExe,
+		"new_version":     newVersion,
+		"backup_path":     l.config.BackupPath,
+		"update_path":     l.config.UpdatePath,
+		"app_root":        l.appRoot(),
+		"script_path":     l.config.ScriptPath,
+		"current_version": l.config.CurrentVersion,
+		"update_version":  l.config.UpdateVersion,
+		"locale":          l.helper.l.Locale(),
+	}
+
+	if err := l.helper.executeLuaScript(l.ctx, l.luaState, l.config.ScriptPath, params, l.config.LuaSandbox); err != nil {
+		return newUpdateError(ErrScriptFailed, l.helper.dispatcher.failedPhase(), l.helper.l.wrap(MsgErrScriptFailed, err))
+	}
+	return nil
+}
+
+// appRoot Linux 下使用可执行文件所在目录作为根目录
+func (l *LinuxUpdater) appRoot() string {
+	return filepath.Dir(l.currentExe)
+}
+
+func (l *LinuxUpdater) Close() {
+	if l.luaState != nil {
+		l.luaState.Close()
+	}
+}
+
+func (l *LinuxUpdater) GetCurrentExe() string {
+	return l.currentExe
+}
+
+func (l *LinuxUpdater) GetConfig() Config {
+	return l.config
+}
+
+// Restart 以独立会话启动新版本，当前进程由调用方退出
+func (l *LinuxUpdater) Restart() error {
+	cmd := exec.Command(l.currentExe, os.Args[1:]...)
+	cmd.Dir = l.appRoot()
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true}
+	return cmd.Start()
+}
+
+func (l *LinuxUpdater) sendLog(format string, args ...interface{}) {
+	if l.config.Logger == nil {
+		fmt.Printf("WARNING: Logger not set - "+format+"\n", args...)
+		return
+	}
+	l.config.Logger.Logf(format, args...)
+}
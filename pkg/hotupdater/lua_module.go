@@ -0,0 +1,417 @@
+package hotupdater
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	lua "github.com/yuin/gopher-lua"
+)
+
+// LuaModuleName 原生模块名，脚本中通过全局变量 hotupdater 或 require("hotupdater") 使用
+const LuaModuleName = "hotupdater"
+
+// luaJSONMaxDepth json_encode 允许的最大嵌套层数，防止循环引用
+const luaJSONMaxDepth = 64
+
+// luaModule 提供给 Lua 脚本的原生文件操作，替代通过 shell 命令完成的复制、打包等操作
+//
+//	hotupdater.copy_file(src, dst)          -- 复制文件，保留权限
+//	hotupdater.copy_tree(src, dst)          -- 递归复制目录（src 为文件时等同 copy_file）
+//	hotupdater.remove_all(path)             -- 删除文件或目录
+//	hotupdater.mkdir_all(path [, mode])     -- 创建目录，默认权限 0755
+//	hotupdater.exists(path)                 -- 路径是否存在
+//	hotupdater.stat(path)                   -- {size, mode, mod_time, is_dir, is_symlink}
+//	hotupdater.sha256(path)                 -- 十六进制摘要, 文件大小
+//	hotupdater.tar_create(src, dst)         -- 打包为 tar.gz，路径规则同 `tar -czf dst src`
+//	hotupdater.tar_extract(archive, dst)    -- 解压 tar.gz 到 dst
+//	hotupdater.zip_create(src, dst)         -- 打包为 zip，以 src 的名称为顶层目录
+//	hotupdater.zip_extract(archive, dst)    -- 解压 zip 到 dst
+//	hotupdater.json_encode(value [, indent])
+//	hotupdater.json_decode(text)
+//	hotupdater.journal(dir, entry)          -- 追加更新日志（见 JournalEntry），state 为 begin 时重新开始，为 committed 时删除
+//	hotupdater.backup_meta(file, meta)      -- 写入备份说明文件（见 BackupMeta），大小和摘要由备份文件计算
+//
+// 失败时按 Lua 惯例返回 nil 和错误信息。
+type luaModule struct {
+	check func(path string) error // 沙箱路径检查，为 nil 时不限制
+}
+
+// OpenLuaModule 在 L 上注册原生模块
+// sandbox 非空时模块中的路径同样只能位于沙箱允许的目录内。
+func OpenLuaModule(L *lua.LState, sandbox *LuaSandbox, params map[string]string) error {
+	m := &luaModule{}
+	if sandbox != nil {
+		policy, err := sandbox.policy(params)
+		if err != nil {
+			return err
+		}
+		m.check = policy.checkPath
+	}
+
+	mod := L.SetFuncs(L.NewTable(), map[string]lua.LGFunction{
+		"copy_file":   m.copyFile,
+		"copy_tree":   m.copyTree,
+		"remove_all":  m.removeAll,
+		"mkdir_all":   m.mkdirAll,
+		"exists":      m.exists,
+		"stat":        m.stat,
+		"sha256":      m.sha256,
+		"tar_create":  m.tarCreate,
+		"tar_extract": m.tarExtract,
+		"zip_create":  m.zipCreate,
+		"zip_extract": m.zipExtract,
+		"json_encode": m.jsonEncode,
+		"json_decode": m.jsonDecode,
+		"journal":     m.journal,
+		"backup_meta": m.backupMeta,
+	})
+	L.SetGlobal(LuaModuleName, mod)
+
+	// 加载了 package 库时同时支持 require
+	if pkg, ok := L.GetGlobal(lua.LoadLibName).(*lua.LTable); ok {
+		if preload, ok := pkg.RawGetString("preload").(*lua.LTable); ok {
+			preload.RawSetString(LuaModuleName, L.NewFunction(func(L *lua.LState) int {
+				L.Push(mod)
+				return 1
+			}))
+		}
+	}
+	return nil
+}
+
+func (m *luaModule) copyFile(L *lua.LState) int {
+	src, dst := L.CheckString(1), L.CheckString(2)
+	return m.run(L, func() error { return copyFile(src, dst) }, src, dst)
+}
+
+func (m *luaModule) copyTree(L *lua.LState) int {
+	src, dst := L.CheckString(1), L.CheckString(2)
+	return m.run(L, func() error { return copyPath(src, dst) }, src, dst)
+}
+
+func (m *luaModule) removeAll(L *lua.LState) int {
+	path := L.CheckString(1)
+	return m.run(L, func() error { return os.RemoveAll(path) }, path)
+}
+
+func (m *luaModule) mkdirAll(L *lua.LState) int {
+	path := L.CheckString(1)
+	mode := os.FileMode(L.OptInt(2, 0755))
+	return m.run(L, func() error { return os.MkdirAll(path, mode) }, path)
+}
+
+func (m *luaModule) exists(L *lua.LState) int {
+	path := L.CheckString(1)
+	if err := m.allow(path); err != nil {
+		return pushError(L, lua.LFalse, err)
+	}
+	_, err := os.Lstat(path)
+	L.Push(lua.LBool(err == nil))
+	return 1
+}
+
+func (m *luaModule) stat(L *lua.LState) int {
+	path := L.CheckString(1)
+	if err := m.allow(path); err != nil {
+		return pushError(L, lua.LNil, err)
+	}
+	info, err := os.Lstat(path)
+	if err != nil {
+		return pushError(L, lua.LNil, err)
+	}
+
+	t := L.NewTable()
+	t.RawSetString("size", lua.LNumber(info.Size()))
+	t.RawSetString("mode", lua.LNumber(info.Mode().Perm()))
+	t.RawSetString("mod_time", lua.LNumber(info.ModTime().Unix()))
+	t.RawSetString("is_dir", lua.LBool(info.IsDir()))
+	t.RawSetString("is_symlink", lua.LBool(info.Mode()&os.ModeSymlink != 0))
+	L.Push(t)
+	return 1
+}
+
+func (m *luaModule) sha256(L *lua.LState) int {
+	path := L.CheckString(1)
+	if err := m.allow(path); err != nil {
+		return pushError(L, lua.LNil, err)
+	}
+	sum, size, err := FileSHA256(path)
+	if err != nil {
+		return pushError(L, lua.LNil, err)
+	}
+	L.Push(lua.LString(sum))
+	L.Push(lua.LNumber(size))
+	return 2
+}
+
+func (m *luaModule) tarCreate(L *lua.LState) int {
+	src, dst := L.CheckString(1), L.CheckString(2)
+	return m.run(L, func() error { return createTarGz(src, dst) }, src, dst)
+}
+
+func (m *luaModule) tarExtract(L *lua.LState) int {
+	archive, dst := L.CheckString(1), L.CheckString(2)
+	return m.run(L, func() error {
+		if err := m.allowEntries(dst, archive, listTarGz); err != nil {
+			return err
+		}
+		return extractTarGz(archive, dst)
+	}, archive)
+}
+
+func (m *luaModule) zipCreate(L *lua.LState) int {
+	src, dst := L.CheckString(1), L.CheckString(2)
+	return m.run(L, func() error { return createZip(src, dst) }, src, dst)
+}
+
+func (m *luaModule) zipExtract(L *lua.LState) int {
+	archive, dst := L.CheckString(1), L.CheckString(2)
+	return m.run(L, func() error {
+		if err := m.allowEntries(dst, archive, listZip); err != nil {
+			return err
+		}
+		return extractZip(archive, dst)
+	}, archive)
+}
+
+func (m *luaModule) jsonEncode(L *lua.LState) int {
+	value, err := luaToGo(L.CheckAny(1), 0)
+	if err != nil {
+		return pushError(L, lua.LNil, err)
+	}
+
+	var data []byte
+	if L.OptBool(2, false) {
+		data, err = json.MarshalIndent(value, "", "  ")
+	} else {
+		data, err = json.Marshal(value)
+	}
+	if err != nil {
+		return pushError(L, lua.LNil, err)
+	}
+	L.Push(lua.LString(data))
+	return 1
+}
+
+func (m *luaModule) jsonDecode(L *lua.LState) int {
+	var value interface{}
+	if err := json.Unmarshal([]byte(L.CheckString(1)), &value); err != nil {
+		return pushError(L, lua.LNil, err)
+	}
+	L.Push(goToLua(L, value))
+	return 1
+}
+
+func (m *luaModule) journal(L *lua.LState) int {
+	dir := L.CheckString(1)
+	value, err := luaToGo(L.CheckTable(2), 0)
+	if err != nil {
+		return pushError(L, lua.LNil, err)
+	}
+	var entry JournalEntry
+	data, _ := json.Marshal(value)
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return pushError(L, lua.LNil, err)
+	}
+
+	path := filepath.Join(dir, JournalFile)
+	return m.run(L, func() error {
+		switch entry.State {
+		case JournalBegin:
+			j, err := OpenJournal(dir)
+			if err != nil {
+				return err
+			}
+			return j.Record(entry)
+		case JournalCommitted:
+			return (&Journal{path: path}).Commit()
+		}
+		entry.Time = time.Now().UnixMilli()
+		return appendJournal(path, entry)
+	}, path)
+}
+
+func (m *luaModule) backupMeta(L *lua.LState) int {
+	backupFile := L.CheckString(1)
+	value, err := luaToGo(L.CheckTable(2), 0)
+	if err != nil {
+		return pushError(L, lua.LNil, err)
+	}
+	var meta BackupMeta
+	data, _ := json.Marshal(value)
+	if err := json.Unmarshal(data, &meta); err != nil {
+		return pushError(L, lua.LNil, err)
+	}
+	return m.run(L, func() error {
+		return WriteBackupMeta(backupFile, meta)
+	}, backupFile, BackupMetaPath(backupFile))
+}
+
+// run 检查路径后执行 fn，成功返回 true，失败返回 nil 和错误信息
+func (m *luaModule) run(L *lua.LState, fn func() error, paths ...string) int {
+	if err := m.allow(paths...); err != nil {
+		return pushError(L, lua.LNil, err)
+	}
+	if err := fn(); err != nil {
+		return pushError(L, lua.LNil, err)
+	}
+	L.Push(lua.LTrue)
+	return 1
+}
+
+// allow 检查路径是否允许访问
+func (m *luaModule) allow(paths ...string) error {
+	if m.check == nil {
+		return nil
+	}
+	for _, p := range paths {
+		if err := m.check(p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// allowEntries 解压前检查归档中每个条目的目标路径，避免解压到一半才被拒绝
+func (m *luaModule) allowEntries(dst, archive string, list func(string) ([]string, error)) error {
+	if m.check == nil {
+		return nil
+	}
+	names, err := list(archive)
+	if err != nil {
+		return err
+	}
+	for _, name := range names {
+		target, err := safeJoin(dst, name)
+		if err != nil {
+			return err
+		}
+		if err := m.check(target); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func pushError(L *lua.LState, value lua.LValue, err error) int {
+	L.Push(value)
+	L.Push(lua.LString(err.Error()))
+	return 2
+}
+
+// luaToGo 将 Lua 值转换为可 JSON 编码的 Go 值
+// 键为 1..n 连续整数的非空表转换为数组，其他表转换为对象。
+func luaToGo(v lua.LValue, depth int) (interface{}, error) {
+	if depth > luaJSONMaxDepth {
+		return nil, errors.New("json_encode: 嵌套层数过多或存在循环引用")
+	}
+
+	switch v := v.(type) {
+	case *lua.LNilType:
+		return nil, nil
+	case lua.LBool:
+		return bool(v), nil
+	case lua.LNumber:
+		return float64(v), nil
+	case lua.LString:
+		return string(v), nil
+	case *lua.LTable:
+		n := v.Len()
+		count := 0
+		v.ForEach(func(lua.LValue, lua.LValue) { count++ })
+
+		if n > 0 && n == count {
+			arr := make([]interface{}, 0, n)
+			for i := 1; i <= n; i++ {
+				item, err := luaToGo(v.RawGetInt(i), depth+1)
+				if err != nil {
+					return nil, err
+				}
+				arr = append(arr, item)
+			}
+			return arr, nil
+		}
+
+		obj := make(map[string]interface{}, count)
+		var convErr error
+		v.ForEach(func(key, value lua.LValue) {
+			if convErr != nil {
+				return
+			}
+			switch key.(type) {
+			case lua.LString, lua.LNumber:
+			default:
+				convErr = fmt.Errorf("json_encode: 不支持的键类型 %s", key.Type())
+				return
+			}
+			item, err := luaToGo(value, depth+1)
+			if err != nil {
+				convErr = err
+				return
+			}
+			obj[key.String()] = item
+		})
+		if convErr != nil {
+			return nil, convErr
+		}
+		return obj, nil
+	}
+	return nil, fmt.Errorf("json_encode: 不支持的类型 %s", v.Type())
+}
+
+// goToLua 将 JSON 解码得到的 Go 值转换为 Lua 值，null 转换为 nil
+func goToLua(L *lua.LState, v interface{}) lua.LValue {
+	switch v := v.(type) {
+	case bool:
+		return lua.LBool(v)
+	case float64:
+		return lua.LNumber(v)
+	case string:
+		return lua.LString(v)
+	case []interface{}:
+		t := L.CreateTable(len(v), 0)
+		for i, item := range v {
+			t.RawSetInt(i+1, goToLua(L, item))
+		}
+		return t
+	case map[string]interface{}:
+		t := L.CreateTable(0, len(v))
+		for key, item := range v {
+			t.RawSetString(key, goToLua(L, item))
+		}
+		return t
+	}
+	return lua.LNil
+}
+
+// RegisterTranslator 注册全局函数 tr(id, ...)，按 l 的语言查找消息并格式化，没有时返回 id 本身
+func RegisterTranslator(L *lua.LState, l *Localizer) {
+	L.SetGlobal("tr", L.NewFunction(func(L *lua.LState) int {
+		L.Push(lua.LString(l.T(MessageID(L.CheckString(1)), luaArgs(L, 2)...)))
+		return 1
+	}))
+}
+
+// luaArgs 把从 start 开始的 Lua 参数转换为格式化参数
+func luaArgs(L *lua.LState, start int) []interface{} {
+	var args []interface{}
+	for i := start; i <= L.GetTop(); i++ {
+		switch v := L.Get(i).(type) {
+		case lua.LNumber:
+			if float64(v) == float64(int64(v)) {
+				args = append(args, int64(v))
+			} else {
+				args = append(args, float64(v))
+			}
+		case lua.LBool:
+			args = append(args, bool(v))
+		default:
+			args = append(args, v.String())
+		}
+	}
+	return args
+}
@@ -0,0 +1,337 @@
+package hotupdater
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	lua "github.com/yuin/gopher-lua"
+)
+
+const (
+	// DefaultLuaTimeout 沙箱中脚本的默认最长执行时间
+	DefaultLuaTimeout = 30 * time.Minute
+
+	defaultLuaCallStackSize   = 256
+	defaultLuaRegistrySize    = 1024 * 20
+	defaultLuaMaxRegistrySize = 1024 * 256
+	memoryCheckInterval       = 200 * time.Millisecond
+)
+
+// LuaSandbox Lua 更新脚本的沙箱配置
+// 启用后脚本只能使用 base、table、string、math、coroutine 标准库以及受限的 io、os：
+//   - io.open、io.lines、os.remove、os.rename 只能访问 app_root、backup_path、update_path 和 AllowedPaths 之内的路径
+//   - os_execute、os.execute、io.popen 的命令必须完整匹配 AllowedCommands 中的某个正则表达式，
+//     并且不经过 shell 直接执行：命令按空白和引号拆分为参数，不支持管道、重定向、变量和命令替换，
+//     其中的路径参数（绝对路径、以 . 开头或包含路径分隔符）同样只能位于上述目录内，工作目录为其中第一个目录（通常为 app_root）
+//   - dofile、loadfile、os.exit、os.setenv、os.tmpname、debug 等不可用
+//
+// 被拒绝的调用按 Lua 惯例返回 nil 和错误信息（os_execute 返回 false）。
+// 路径检查只针对命令行参数，被允许的程序自身可以访问任何路径，AllowedCommands 中不应包含
+// sh、bash、cmd、powershell、python 等能执行任意代码的程序。
+// 脚本执行超过 Timeout 或内存增长超过 MaxMemory 时会被中止。MaxMemory 按整个进程的堆内存增长估算，
+// 宿主程序其他 goroutine 的分配也会计入，正在执行的外部命令的内存不计入。
+type LuaSandbox struct {
+	AllowedCommands []string      `json:"allowed_commands,omitempty"`  // 允许执行的命令（正则表达式，需完整匹配）
+	AllowedPaths    []string      `json:"allowed_paths,omitempty"`     // 额外允许访问的目录
+	Timeout         time.Duration `json:"timeout,omitempty"`           // 最长执行时间，0 时使用 DefaultLuaTimeout
+	MaxMemory       uint64        `json:"max_memory,omitempty"`        // 执行期间整个进程的堆内存最大增长（字节），0 不限制
+	CallStackSize   int           `json:"call_stack_size,omitempty"`   // 调用栈深度，0 时为 256
+	MaxRegistrySize int           `json:"max_registry_size,omitempty"` // 数据栈最大槽位数，0 时为 256K
+}
+
+// sandboxPolicy 按更新参数解析后的沙箱规则
+type sandboxPolicy struct {
+	commands []*regexp.Regexp
+	roots    []string
+}
+
+// NewLuaState 创建执行更新脚本的 Lua 虚拟机
+// sandbox 为 nil 时加载全部标准库，否则只加载安全的标准库并限制调用栈和数据栈大小，
+// 执行脚本前还需调用 Install 安装受限的 io、os。两种情况下 os.execute 都会随 context 取消。
+func NewLuaState(sandbox *LuaSandbox) *lua.LState {
+	if sandbox == nil {
+		L := lua.NewState()
+		L.GetGlobal(lua.OsLibName).(*lua.LTable).RawSetString("execute", L.NewFunction(luaOsExecute))
+		return L
+	}
+
+	L := lua.NewState(lua.Options{
+		SkipOpenLibs:    true,
+		CallStackSize:   orDefault(sandbox.CallStackSize, defaultLuaCallStackSize),
+		RegistrySize:    defaultLuaRegistrySize,
+		RegistryMaxSize: orDefault(sandbox.MaxRegistrySize, defaultLuaMaxRegistrySize),
+	})
+	openLuaLib(L, lua.BaseLibName, lua.OpenBase)
+	openLuaLib(L, lua.TabLibName, lua.OpenTable)
+	openLuaLib(L, lua.StringLibName, lua.OpenString)
+	openLuaLib(L, lua.MathLibName, lua.OpenMath)
+	openLuaLib(L, lua.CoroutineLibName, lua.OpenCoroutine)
+
+	// 移除可以直接读取文件的基础函数
+	for _, name := range []string{"dofile", "loadfile"} {
+		L.SetGlobal(name, lua.LNil)
+	}
+	return L
+}
+
+// Install 在 L 上安装受限的 io、os 和 package，并把已注册的 os_execute 替换为沙箱中的版本
+// 允许访问的目录取自 params 中的 app_root、backup_path、update_path 以及 AllowedPaths。
+// sandbox 为 nil 时不做任何处理。
+func (s *LuaSandbox) Install(L *lua.LState, params map[string]string) error {
+	if s == nil {
+		return nil
+	}
+
+	policy, err := s.policy(params)
+	if err != nil {
+		return err
+	}
+
+	// 临时打开 io、os 取得原始实现，再替换为受限版本
+	rawIo := openLuaLib(L, lua.IoLibName, lua.OpenIo)
+	rawOs := openLuaLib(L, lua.OsLibName, lua.OpenOs)
+
+	ioLib := L.NewTable()
+	for _, name := range []string{"write", "read", "close", "type", "stdout", "stderr"} {
+		ioLib.RawSetString(name, rawIo.RawGetString(name))
+	}
+	ioLib.RawSetString("open", guardLuaFunc(L, rawIo.RawGetString("open"), policy.checkPath, 1))
+	ioLib.RawSetString("lines", guardLuaFunc(L, rawIo.RawGetString("lines"), policy.checkPath, 1))
+	ioLib.RawSetString("popen", L.NewFunction(policy.luaPopen))
+
+	osLib := L.NewTable()
+	for _, name := range []string{"clock", "date", "difftime", "getenv", "time"} {
+		osLib.RawSetString(name, rawOs.RawGetString(name))
+	}
+	osLib.RawSetString("execute", L.NewFunction(policy.luaExecute))
+	osLib.RawSetString("remove", guardLuaFunc(L, rawOs.RawGetString("remove"), policy.checkPath, 1))
+	osLib.RawSetString("rename", guardLuaFunc(L, rawOs.RawGetString("rename"), policy.checkPath, 1, 2))
+
+	L.SetGlobal(lua.IoLibName, ioLib)
+	L.SetGlobal(lua.OsLibName, osLib)
+
+	// 脚本通过 package.config 判断路径分隔符
+	pkg := L.NewTable()
+	pkg.RawSetString("config", lua.LString(fmt.Sprintf("%c\n;\n?\n!\n-", os.PathSeparator)))
+	L.SetGlobal(lua.LoadLibName, pkg)
+
+	if _, ok := L.GetGlobal("os_execute").(*lua.LFunction); ok {
+		L.SetGlobal("os_execute", L.NewFunction(policy.luaOsExecuteBool))
+	}
+	return nil
+}
+
+// Run 在沙箱限制下执行 fn，ctx 取消、超时或内存超限时中止脚本并返回对应错误
+// sandbox 为 nil 时只把 ctx 绑定到 L 上再执行 fn。
+func (s *LuaSandbox) Run(ctx context.Context, L *lua.LState, fn func() error) error {
+	if s == nil {
+		L.SetContext(ctx)
+		defer L.RemoveContext()
+		return fn()
+	}
+
+	timeout := s.Timeout
+	if timeout <= 0 {
+		timeout = DefaultLuaTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	var exceeded int32
+	if s.MaxMemory > 0 {
+		go watchMemory(ctx, s.MaxMemory, func() {
+			atomic.StoreInt32(&exceeded, 1)
+			cancel()
+		})
+	}
+
+	L.SetContext(ctx)
+	defer L.RemoveContext()
+
+	err := fn()
+	if err == nil {
+		return nil
+	}
+	switch {
+	case atomic.LoadInt32(&exceeded) == 1:
+		return fmt.Errorf("Lua 脚本内存占用超过限制 (%d 字节): %w", s.MaxMemory, err)
+	case errors.Is(ctx.Err(), context.DeadlineExceeded):
+		return fmt.Errorf("Lua 脚本执行超时 (%v): %w", timeout, err)
+	}
+	return err
+}
+
+// policy 编译命令白名单并整理允许访问的目录
+func (s *LuaSandbox) policy(params map[string]string) (*sandboxPolicy, error) {
+	p := &sandboxPolicy{}
+	for _, pattern := range s.AllowedCommands {
+		re, err := regexp.Compile(`^(?:` + pattern + `)$`)
+		if err != nil {
+			return nil, fmt.Errorf("无效的命令白名单 %q: %w", pattern, err)
+		}
+		p.commands = append(p.commands, re)
+	}
+
+	roots := append([]string{params["app_root"], params["backup_path"], params["update_path"]}, s.AllowedPaths...)
+	for _, root := range roots {
+		if root == "" {
+			continue
+		}
+		abs, err := filepath.Abs(root)
+		if err != nil {
+			return nil, fmt.Errorf("无效的沙箱目录 %s: %w", root, err)
+		}
+		p.roots = append(p.roots, resolvePath(abs))
+	}
+	return p, nil
+}
+
+// checkCommand 检查命令是否在白名单中
+func (p *sandboxPolicy) checkCommand(cmd string) error {
+	for _, re := range p.commands {
+		if re.MatchString(cmd) {
+			return nil
+		}
+	}
+	return fmt.Errorf("沙箱禁止执行命令: %s", cmd)
+}
+
+// checkPath 检查路径是否位于允许访问的目录内，符号链接按实际指向判断
+func (p *sandboxPolicy) checkPath(path string) error {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return fmt.Errorf("沙箱禁止访问路径: %s", path)
+	}
+	abs = resolvePath(abs)
+	for _, root := range p.roots {
+		if pathWithin(root, abs) {
+			return nil
+		}
+	}
+	return fmt.Errorf("沙箱禁止访问路径: %s", path)
+}
+
+// resolvePath 解析路径中已存在部分的符号链接
+func resolvePath(path string) string {
+	dir, rest := path, ""
+	for {
+		if resolved, err := filepath.EvalSymlinks(dir); err == nil {
+			return filepath.Join(resolved, rest)
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return path
+		}
+		rest = filepath.Join(filepath.Base(dir), rest)
+		dir = parent
+	}
+}
+
+// pathWithin 判断 path 是否为 root 或位于 root 之下
+func pathWithin(root, path string) bool {
+	if runtime.GOOS == "windows" {
+		root, path = strings.ToLower(root), strings.ToLower(path)
+	}
+	rel, err := filepath.Rel(root, path)
+	if err != nil {
+		return false
+	}
+	return rel == "." || (rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)))
+}
+
+// watchMemory 定期检查堆内存增长，超过 limit 时调用 onExceeded
+// 统计的是整个进程的堆内存，因此只是近似限制。
+func watchMemory(ctx context.Context, limit uint64, onExceeded func()) {
+	var stats runtime.MemStats
+	runtime.ReadMemStats(&stats)
+	base := stats.HeapAlloc
+
+	ticker := time.NewTicker(memoryCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			runtime.ReadMemStats(&stats)
+			if stats.HeapAlloc > base && stats.HeapAlloc-base > limit {
+				onExceeded()
+				return
+			}
+		}
+	}
+}
+
+// openLuaLib 打开单个标准库并返回其模块表
+func openLuaLib(L *lua.LState, name string, open lua.LGFunction) *lua.LTable {
+	L.Push(L.NewFunction(open))
+	L.Push(lua.LString(name))
+	L.Call(1, 1)
+	lib, _ := L.Get(-1).(*lua.LTable)
+	L.Pop(1)
+	return lib
+}
+
+// guardLuaFunc 包装 fn，调用前用 check 检查指定位置的字符串参数
+func guardLuaFunc(L *lua.LState, fn lua.LValue, check func(string) error, args ...int) *lua.LFunction {
+	return L.NewFunction(func(L *lua.LState) int {
+		for _, i := range args {
+			if L.GetTop() < i || L.Get(i) == lua.LNil {
+				continue
+			}
+			if err := check(L.CheckString(i)); err != nil {
+				L.Push(lua.LNil)
+				L.Push(lua.LString(err.Error()))
+				return 2
+			}
+		}
+		return callLuaFunc(L, fn)
+	})
+}
+
+// callLuaFunc 以当前全部参数调用 fn 并返回全部结果
+func callLuaFunc(L *lua.LState, fn lua.LValue) int {
+	top := L.GetTop()
+	L.Push(fn)
+	for i := 1; i <= top; i++ {
+		L.Push(L.Get(i))
+	}
+	L.Call(top, lua.MultRet)
+	return L.GetTop() - top
+}
+
+func orDefault(v, def int) int {
+	if v > 0 {
+		return v
+	}
+	return def
+}
+
+// luaOsExecute 替代 os.execute，命令进程随 LState 的 context 一起取消
+// 返回值与 gopher-lua 一致：成功为 0，失败为 1。
+func luaOsExecute(L *lua.LState) int {
+	cmd := shellCommand(luaContext(L), L.CheckString(1))
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	if err := cmd.Run(); err != nil {
+		L.Push(lua.LNumber(1))
+		return 1
+	}
+	L.Push(lua.LNumber(0))
+	return 1
+}
+
+// luaContext 返回 LState 当前的 context，没有时返回 context.Background()
+func luaContext(L *lua.LState) context.Context {
+	if ctx := L.Context(); ctx != nil {
+		return ctx
+	}
+	return context.Background()
+}
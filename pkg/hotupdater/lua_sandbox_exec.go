@@ -0,0 +1,218 @@
+package hotupdater
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+
+	lua "github.com/yuin/gopher-lua"
+)
+
+// 沙箱中的命令不经过 shell，引号之外出现这些字符时拒绝执行，避免脚本误以为管道、重定向等会生效
+const shellMetaChars = "|&;<>()$`*?\n"
+
+// windowsSwitch 匹配 Windows 命令的 /X、/IM 形式的开关，冒号之后的值按路径检查
+var windowsSwitch = regexp.MustCompile(`^/[A-Za-z?]{1,2}(:.*)?$`)
+
+// command 检查命令并返回不经过 shell 直接执行的进程
+// 命令先要完整匹配 AllowedCommands，再按引号拆分为参数，路径参数必须位于允许访问的目录内。
+// 命令的工作目录为第一个允许访问的目录，相对路径按此解析。
+func (p *sandboxPolicy) command(ctx context.Context, line string) (*exec.Cmd, error) {
+	if err := p.checkCommand(line); err != nil {
+		return nil, err
+	}
+	argv, err := splitCommand(line)
+	if err != nil {
+		return nil, err
+	}
+	for _, arg := range argv[1:] {
+		if err := p.checkArg(arg); err != nil {
+			return nil, err
+		}
+	}
+
+	cmd := directCommand(ctx, argv[0], argv[1:]...)
+	if len(p.roots) > 0 {
+		cmd.Dir = p.roots[0]
+	}
+	return cmd, nil
+}
+
+// checkArg 检查命令参数中的路径
+// 绝对路径、以 . 开头或包含路径分隔符的参数视为路径，--opt=value 形式检查 value。
+func (p *sandboxPolicy) checkArg(arg string) error {
+	value := arg
+	switch {
+	case runtime.GOOS == "windows" && windowsSwitch.MatchString(arg):
+		i := strings.IndexByte(arg, ':')
+		if i < 0 {
+			return nil
+		}
+		value = arg[i+1:]
+	case strings.HasPrefix(arg, "-"):
+		i := strings.IndexByte(arg, '=')
+		if i < 0 {
+			if strings.ContainsAny(arg, `/\`) {
+				return fmt.Errorf("沙箱中的命令不支持在选项中直接附带路径: %s", arg)
+			}
+			return nil
+		}
+		value = arg[i+1:]
+	}
+
+	// 没有允许访问的目录时，任何参数都可能是相对于当前目录的路径
+	if !isPathArg(value) && len(p.roots) > 0 {
+		return nil
+	}
+	if !filepath.IsAbs(value) && len(p.roots) > 0 {
+		value = filepath.Join(p.roots[0], value)
+	}
+	return p.checkPath(value)
+}
+
+// isPathArg 判断参数是否可能是路径
+func isPathArg(s string) bool {
+	return filepath.IsAbs(s) || filepath.VolumeName(s) != "" || strings.HasPrefix(s, ".") || strings.ContainsAny(s, `/\`)
+}
+
+// splitCommand 按空白拆分命令，单引号和双引号内的内容作为一个参数原样保留
+func splitCommand(line string) ([]string, error) {
+	var (
+		args    []string
+		current strings.Builder
+		inArg   bool
+		quote   rune
+	)
+	for _, r := range line {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else {
+				current.WriteRune(r)
+			}
+		case r == '"' || r == '\'':
+			quote, inArg = r, true
+		case r == ' ' || r == '\t':
+			if inArg {
+				args = append(args, current.String())
+				current.Reset()
+				inArg = false
+			}
+		case strings.ContainsRune(shellMetaChars, r):
+			return nil, fmt.Errorf("沙箱中的命令不经过 shell 执行，不支持 %q: %s", r, line)
+		default:
+			current.WriteRune(r)
+			inArg = true
+		}
+	}
+	if quote != 0 {
+		return nil, fmt.Errorf("命令中的引号不完整: %s", line)
+	}
+	if inArg {
+		args = append(args, current.String())
+	}
+	if len(args) == 0 {
+		return nil, errors.New("命令为空")
+	}
+	return args, nil
+}
+
+// luaExecute 沙箱中的 os.execute，返回值与 luaOsExecute 一致
+func (p *sandboxPolicy) luaExecute(L *lua.LState) int {
+	cmd, err := p.command(luaContext(L), L.CheckString(1))
+	if err != nil {
+		L.Push(lua.LNil)
+		L.Push(lua.LString(err.Error()))
+		return 2
+	}
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	if err := cmd.Run(); err != nil {
+		L.Push(lua.LNumber(1))
+		return 1
+	}
+	L.Push(lua.LNumber(0))
+	return 1
+}
+
+// luaOsExecuteBool 沙箱中的 os_execute，成功返回 true，被拒绝时返回 false 和错误信息
+func (p *sandboxPolicy) luaOsExecuteBool(L *lua.LState) int {
+	cmd, err := p.command(luaContext(L), L.CheckString(1))
+	if err != nil {
+		L.Push(lua.LFalse)
+		L.Push(lua.LString(err.Error()))
+		return 2
+	}
+	L.Push(lua.LBool(cmd.Run() == nil))
+	return 1
+}
+
+// luaPopen 沙箱中的 io.popen，只支持读取：命令执行结束后返回带 read、lines、close 方法的对象
+func (p *sandboxPolicy) luaPopen(L *lua.LState) int {
+	if mode := L.OptString(2, "r"); mode != "r" {
+		L.Push(lua.LNil)
+		L.Push(lua.LString("沙箱中的 io.popen 只支持读取"))
+		return 2
+	}
+	cmd, err := p.command(luaContext(L), L.CheckString(1))
+	if err != nil {
+		L.Push(lua.LNil)
+		L.Push(lua.LString(err.Error()))
+		return 2
+	}
+	output, runErr := cmd.Output()
+	buf := bytes.NewBuffer(output)
+
+	readLine := func() lua.LValue {
+		if buf.Len() == 0 {
+			return lua.LNil
+		}
+		line, _ := buf.ReadString('\n')
+		return lua.LString(strings.TrimSuffix(strings.TrimSuffix(line, "\n"), "\r"))
+	}
+
+	handle := L.NewTable()
+	handle.RawSetString("read", L.NewFunction(func(L *lua.LState) int {
+		switch strings.TrimPrefix(L.OptString(2, "*l"), "*") {
+		case "a":
+			L.Push(lua.LString(buf.String()))
+			buf.Reset()
+		case "l":
+			L.Push(readLine())
+		default:
+			L.Push(lua.LNil)
+		}
+		return 1
+	}))
+	handle.RawSetString("lines", L.NewFunction(func(L *lua.LState) int {
+		L.Push(L.NewFunction(func(L *lua.LState) int {
+			L.Push(readLine())
+			return 1
+		}))
+		return 1
+	}))
+	handle.RawSetString("close", L.NewFunction(func(L *lua.LState) int {
+		if runErr == nil {
+			L.Push(lua.LTrue)
+			return 1
+		}
+		code := 1
+		var exitErr *exec.ExitError
+		if errors.As(runErr, &exitErr) {
+			code = exitErr.ExitCode()
+		}
+		L.Push(lua.LNil)
+		L.Push(lua.LString("exit"))
+		L.Push(lua.LNumber(code))
+		return 3
+	}))
+	L.Push(handle)
+	return 1
+}
@@ -0,0 +1,112 @@
+package hotupdater
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	lua "github.com/yuin/gopher-lua"
+)
+
+// newSandboxState 创建以 root 为 app_root 的沙箱虚拟机
+func newSandboxState(t *testing.T, root string, commands ...string) *lua.LState {
+	t.Helper()
+	sandbox := &LuaSandbox{AllowedCommands: commands}
+	L := NewLuaState(sandbox)
+	t.Cleanup(L.Close)
+	if err := sandbox.Install(L, map[string]string{"app_root": root}); err != nil {
+		t.Fatal(err)
+	}
+	return L
+}
+
+func TestSandboxCommandArguments(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("测试使用 Unix 命令")
+	}
+	root := t.TempDir()
+	outside := t.TempDir()
+	if err := os.WriteFile(filepath.Join(root, "inside"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	L := newSandboxState(t, root, `test -e "[^"]+"`, `test -e [^ ]+`)
+
+	cases := []struct {
+		name    string
+		command string
+		allowed bool
+	}{
+		{"目录内的路径", `test -e "` + filepath.Join(root, "inside") + `"`, true},
+		{"相对路径", `test -e "./inside"`, true},
+		{"目录外的路径", `test -e "` + outside + `"`, false},
+		{"根目录", `test -e "/"`, false},
+		{"跳出目录", `test -e "../"`, false},
+		{"引号外的命令替换", "test -e `id`", false},
+		{"引号外的变量", "test -e $HOME", false},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			L.SetGlobal("cmd", lua.LString(c.command))
+			if err := L.DoString(`result, err = os.execute(cmd)`); err != nil {
+				t.Fatal(err)
+			}
+			allowed := L.GetGlobal("result") != lua.LNil
+			if allowed != c.allowed {
+				t.Fatalf("%s: 期望允许 %v，实际 %v (%s)", c.command, c.allowed, allowed, L.GetGlobal("err"))
+			}
+		})
+	}
+
+	// 引号中的 $(...) 不经过 shell，只是普通文本
+	L.SetGlobal("cmd", lua.LString(`test -e "$(touch `+filepath.Join(outside, "pwned")+`)"`))
+	if err := L.DoString(`os.execute(cmd)`); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(outside, "pwned")); err == nil {
+		t.Fatal("命令替换被 shell 执行了")
+	}
+}
+
+func TestSandboxPopen(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("测试使用 Unix 命令")
+	}
+	L := newSandboxState(t, t.TempDir(), `echo [a-z ]+`)
+	err := L.DoString(`
+		local h = io.popen("echo hello world")
+		output = h:read("*a")
+		ok = h:close()
+		denied, reason = io.popen("echo hi | cat")
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := L.GetGlobal("output").String(); got != "hello world\n" {
+		t.Fatalf("输出为 %q", got)
+	}
+	if L.GetGlobal("ok") != lua.LTrue {
+		t.Fatal("close 应返回 true")
+	}
+	if L.GetGlobal("denied") != lua.LNil {
+		t.Fatal("不在白名单中的命令应被拒绝")
+	}
+}
+
+func TestSandboxTimeoutKeepsCause(t *testing.T) {
+	sandbox := &LuaSandbox{Timeout: 10 * time.Millisecond}
+	L := NewLuaState(sandbox)
+	defer L.Close()
+
+	cause := errors.New("脚本失败")
+	err := sandbox.Run(context.Background(), L, func() error {
+		time.Sleep(50 * time.Millisecond)
+		return cause
+	})
+	if !errors.Is(err, cause) {
+		t.Fatalf("超时错误应保留原因: %v", err)
+	}
+}
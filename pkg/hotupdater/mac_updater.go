@@ -4,18 +4,23 @@
 package hotupdater
 
 import (
-	"bufio"
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"strings"
+	"syscall"
+	"time"
 
 	lua "github.com/yuin/gopher-lua"
 )
 
+// helperCancelDelay 取消后等待更新助手回滚退出的最长时间
+const helperCancelDelay = 30 * time.Second
+
 // MacUpdater macOS 更新器
 type MacUpdater struct {
 	config     Config
@@ -34,9 +39,9 @@ func newMacUpdater(config Config, ctx context.Context) *MacUpdater {
 	m := &MacUpdater{
 		config:     config,
 		ctx:        ctx,
-		luaState:   lua.NewState(),
+		luaState:   NewLuaState(config.LuaSandbox),
 		currentExe: exe,
-		helper:     newHelper(config.Logger, config.EventEmitter),
+		helper:     newHelper(config),
 	}
 
 	// 添加初始化日志
@@ -53,10 +58,15 @@ func (m *MacUpdater) Update(newVersion string) error {
 	m.sendLog("当前程序路径: %s", m.currentExe)
 	m.sendLog("新版本路径: %s", newVersion)
 
+	// Go 流水线在当前进程内执行，需要当前用户对 .app 所在目录有写入权限
+	if m.config.Pipeline != nil {
+		return m.helper.runPipeline(m.ctx, m.config.Pipeline, m.config, m.currentExe, newVersion)
+	}
+
 	appRoot := m.getMacAppRoot()
 	if appRoot == "" {
 		m.sendLog("无法获取应用根目录")
-		return fmt.Errorf("无法确定应用程序包路径")
+		return newUpdateError(ErrInstallFailed, PhasePreCheck, m.helper.l.newError(MsgErrBundleUnknown))
 	}
 	m.sendLog("应用根目录: %s", appRoot)
 
@@ -67,7 +77,7 @@ func (m *MacUpdater) Update(newVersion string) error {
 	m.sendLog("更新助手路径: %s", helperPath)
 	if _, err := os.Stat(helperPath); os.IsNotExist(err) {
 		m.sendLog("更新助手不存在: %s", helperPath)
-		return fmt.Errorf("更新助手不存在: %s", helperPath)
+		return newUpdateError(ErrHelperMissing, PhasePreCheck, m.helper.l.wrap(MsgErrHelperMissing, err, helperPath))
 	}
 	m.sendLog("更新助手存在")
 
@@ -76,10 +86,16 @@ func (m *MacUpdater) Update(newVersion string) error {
 	m.sendLog("更新脚本路径: %s", scriptPath)
 	if _, err := os.Stat(scriptPath); os.IsNotExist(err) {
 		m.sendLog("更新脚本不存在: %s", scriptPath)
-		return fmt.Errorf("更新脚本不存在: %s", scriptPath)
+		return newUpdateError(ErrScriptFailed, PhasePreCheck, m.helper.l.wrap(MsgErrScriptMissing, err, scriptPath))
 	}
 	m.sendLog("更新脚本存在")
 
+	// 脚本在更新助手进程中执行，无法调用应用的钩子，备份和安装前的钩子在启动助手前执行，
+	// 之后的钩子在助手完成后执行，见 runAfterHooks
+	if err := m.runHooks(newVersion, HookBeforeBackup, HookBeforeInstall); err != nil {
+		return err
+	}
+
 	updateInfo := filepath.Join(m.config.UpdatePath, "update_info.json")
 	m.sendLog("更新信息文件路径: %s", updateInfo)
 
@@ -92,11 +108,23 @@ func (m *MacUpdater) Update(newVersion string) error {
 		"script_path":     scriptPath,
 		"current_version": m.config.CurrentVersion,
 		"update_version":  m.config.UpdateVersion,
+		"locale":          m.helper.l.Locale(),
 	}
 
-	if err := m.helper.writeUpdateInfo(updateInfo, params); err != nil {
+	info := make(map[string]interface{}, len(params)+2)
+	for k, v := range params {
+		info[k] = v
+	}
+	// 更新助手是独立进程，传入当前语言的消息（包括 RegisterCatalog 注册的）供脚本的 tr 使用
+	info["messages"] = m.helper.l.Catalog()
+	if m.config.LuaSandbox != nil {
+		// 更新助手按相同的沙箱配置执行脚本
+		info["lua_sandbox"] = m.config.LuaSandbox
+	}
+
+	if err := m.helper.writeUpdateInfo(updateInfo, info); err != nil {
 		m.sendLog("写入更新信息失败: %v", err)
-		return err
+		return newUpdateError(ErrInstallFailed, PhasePreCheck, err)
 	}
 	m.sendLog("更新信息已写入")
 
@@ -107,48 +135,163 @@ func (m *MacUpdater) Update(newVersion string) error {
 	cmd := exec.CommandContext(m.ctx, helperPath, "--update", updateInfo)
 	cmd.Stdout = pw
 	cmd.Stderr = pw
+	// 取消时先发送 SIGTERM，让助手通知提权进程回滚，超时后再强制结束
+	cmd.Cancel = func() error {
+		return cmd.Process.Signal(syscall.SIGTERM)
+	}
+	cmd.WaitDelay = helperCancelDelay
 
-	// 启动一个 goroutine 来读取输出
+	// 启动一个 goroutine 来读取输出，助手按消息协议逐行输出
+	var firstError, rollbackResult Message
+	dispatcher := &messageDispatcher{logger: m.config.Logger, emitter: m.config.EventEmitter, model: m.config.ProgressModel, l: m.helper.l}
+	done := make(chan struct{})
 	go func() {
-		defer pw.Close()
-		scanner := bufio.NewScanner(pr)
-		for scanner.Scan() {
-			line := scanner.Text()
-			// 解析进度信息
-			if strings.HasPrefix(line, "@PROGRESS@") {
-				m.parseProgress(strings.TrimPrefix(line, "@PROGRESS@"))
-			} else {
-				m.sendLog("助手输出: %s", line)
-			}
-		}
+		defer close(done)
+		firstError, rollbackResult = readHelperOutput(pr, dispatcher)
 	}()
 
 	m.sendLog("正在启动更新助手...")
+	started := time.Now()
 	if err := cmd.Start(); err != nil {
 		m.sendLog("启动更新助手失败: %v", err)
 		pw.Close()
-		return err
+		return newUpdateError(ErrHelperMissing, PhasePreCheck, m.helper.l.wrap(MsgErrHelperFailed, err))
 	}
 
 	m.sendLog("更新助手启动成功，等待更新完成...")
 
 	// 等待命令完成
 	err := cmd.Wait()
+	pw.Close()
+	<-done
+	if ctxErr := m.ctx.Err(); ctxErr != nil {
+		m.sendLog("更新被取消: %v", ctxErr)
+		return m.cancelledError(ctxErr, rollbackResult, dispatcher.failedPhase())
+	}
 	if err != nil {
 		m.sendLog("更新助手执行失败: %v", err)
-		return err
+		if firstError.Message != "" {
+			err = fmt.Errorf("%s: %w", firstError.Message, err)
+		}
+		return newUpdateError(ErrScriptFailed, dispatcher.failedPhase(), m.helper.l.wrap(MsgErrHelperFailed, err))
 	}
 
-	// 检查上下文是否已取消
-	if m.ctx.Err() != nil {
-		m.sendLog("更新被取消: %v", m.ctx.Err())
-		return m.ctx.Err()
+	m.sendLog("更新助手执行完成")
+	return m.runAfterHooks(newVersion, started, helperPath, updateInfo)
+}
+
+// readHelperOutput 按消息协议读取更新助手的输出并分发，返回助手上报的第一条错误和回滚结果
+func readHelperOutput(r io.Reader, dispatcher *messageDispatcher) (firstError, rollbackResult Message) {
+	scanner := NewMessageScanner(r)
+	for scanner.Scan() {
+		msg := ParseMessage(scanner.Text())
+		switch msg.Type {
+		case MessageLog:
+			msg.Message = "助手输出: " + msg.Message
+		case MessageError:
+			// 提权进程的错误会先于父进程的错误到达，保留第一条
+			if firstError.Message == "" {
+				firstError = msg
+			}
+			if msg.Code == ErrorCodeRolledBack || msg.Code == ErrorCodeRollbackFailed {
+				rollbackResult = msg
+			}
+		}
+		dispatcher.dispatch(msg)
 	}
+	// 读取出错时继续排空管道，避免助手写入阻塞
+	io.Copy(io.Discard, r)
+	return firstError, rollbackResult
+}
 
-	m.sendLog("更新助手执行完成")
+// runHooks 依次执行钩子，任一钩子否决时返回
+func (m *MacUpdater) runHooks(newVersion string, points ...HookPoint) error {
+	for _, point := range points {
+		hc := m.config.hookContext(m.ctx, point)
+		hc.NewVersion = newVersion
+		hc.Target = m.currentExe
+		if err := m.config.runHook(hc); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
+// runAfterHooks 更新助手完成后执行 AfterBackup、AfterInstall，否决时从本次更新的备份恢复旧版本
+// 助手在独立进程中执行脚本，无法在替换前后调用应用的钩子，此时新版本已经安装，只能回滚。
+func (m *MacUpdater) runAfterHooks(newVersion string, since time.Time, helperPath, updateInfo string) error {
+	backup := findUpdateBackup(backupDirs(m.config.BackupPath, m.currentExe), m.config.CurrentVersion, since)
+	for _, point := range []HookPoint{HookAfterBackup, HookAfterInstall} {
+		hc := m.config.hookContext(m.ctx, point)
+		hc.NewVersion = newVersion
+		hc.Target = m.currentExe
+		hc.BackupFile = backup
+		err := m.config.runHook(hc)
+		if err == nil {
+			continue
+		}
+
+		ue := err.(*UpdateError)
+		if rbErr := m.restoreBackup(backup, helperPath, updateInfo); rbErr != nil {
+			m.sendLog("钩子否决后恢复旧版本失败: %v", rbErr)
+			ue.Err = m.helper.l.errorOf(MsgErrStepRollbackFailed, ue.Err, point, rbErr, ue.Err)
+			ue.RollbackErr = rbErr
+			return ue
+		}
+		ue.RolledBack = true
+		return ue
+	}
+	return nil
+}
+
+// restoreBackup 从更新助手创建的备份恢复旧版本
+// .app 通常位于需要管理员权限的 /Applications，与更新一样交给更新助手（--restore）提权后恢复。
+func (m *MacUpdater) restoreBackup(backup, helperPath, updateInfo string) error {
+	if backup == "" {
+		return m.helper.l.newError(MsgErrRestorePrevious, m.currentExe)
+	}
+	target, err := backupTarget(backup, m.currentExe)
+	if err != nil {
+		return err
+	}
+	m.sendLog("从备份恢复: %s -> %s", backup, target)
+
+	// 回滚不受更新的 context 控制，取消更新不能中断恢复
+	pr, pw := io.Pipe()
+	cmd := exec.Command(helperPath, "--update", updateInfo, "--restore", backup, "--target", target)
+	cmd.Stdout = pw
+	cmd.Stderr = pw
+	var firstError Message
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		firstError, _ = readHelperOutput(pr, m.helper.dispatcher)
+	}()
+	err = cmd.Run()
+	pw.Close()
+	<-done
+	if err != nil {
+		if firstError.Message != "" {
+			err = fmt.Errorf("%s: %w", firstError.Message, err)
+		}
+		return m.helper.l.wrap(MsgErrRestoreBackup, err)
+	}
+	return nil
+}
+
+// cancelledError 按助手上报的错误码构造取消结果
+// 助手回滚成功时上报 rolled_back，回滚失败时上报 rollback_failed
+func (m *MacUpdater) cancelledError(ctxErr error, result Message, phase UpdatePhase) error {
+	ue := &UpdateError{Kind: ErrCancelled, Phase: phase, Err: m.helper.l.wrap(MsgErrCancelled, ctxErr)}
+	switch result.Code {
+	case ErrorCodeRolledBack:
+		ue.RolledBack = true
+	case ErrorCodeRollbackFailed:
+		ue.RollbackErr = errors.New(result.Message)
+	}
+	return ue
+}
+
 func (m *MacUpdater) Close() {
 	if m.luaState != nil {
 		m.luaState.Close()
@@ -199,7 +342,7 @@ func (m *MacUpdater) sendLog(format string, args ...interface{}) {
 func (m *MacUpdater) Restart() error {
 	appRoot := m.getMacAppRoot()
 	if appRoot == "" {
-		return fmt.Errorf("无法获取应用根目录")
+		return m.helper.l.newError(MsgErrAppRoot)
 	}
 
 	cmd := exec.Command("/usr/bin/open", "-n", appRoot)
@@ -207,16 +350,3 @@ func (m *MacUpdater) Restart() error {
 	cmd.Stderr = nil
 	return cmd.Start()
 }
-
-// 解析进度信息
-func (m *MacUpdater) parseProgress(data string) {
-	progress := ParseProgressMessage(data)
-	if progress == nil {
-		return
-	}
-
-	// 发送进度事件
-	if m.config.EventEmitter != nil {
-		m.config.EventEmitter.EmitProgress(*progress)
-	}
-}
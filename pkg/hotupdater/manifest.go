@@ -0,0 +1,333 @@
+package hotupdater
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"runtime"
+	"time"
+
+	"github.com/562589540/hotupdater/pkg/version"
+)
+
+// manifestMaxSize 更新清单的最大字节数
+const manifestMaxSize = 1 << 20
+
+// Manifest 发布清单，通常以 JSON 形式放在更新服务器上
+//
+//	{
+//	  "version": "1.0.5",
+//	  "release_notes": "修复若干问题",
+//	  "mandatory": false,
+//	  "minimum_version": "1.0.0",
+//	  "published_at": "2025-01-08T12:00:00Z",
+//	  "assets": {
+//	    "windows-amd64": {"url": "https://example.com/app_1.0.5.exe", "size": 83886080, "sha256": "..."},
+//	    "darwin": {"url": "https://example.com/app_1.0.5.tar.gz", "size": 104857600, "sha256": "..."}
+//	  },
+//	  "channels": {
+//	    "beta": {"version": "1.1.0-beta.1", "rollout": 25, "assets": {...}}
+//	  }
+//	}
+//
+// assets 的键为 "GOOS-GOARCH"，找不到时退回 "GOOS"。
+// 顶层字段为 stable 通道的发布；其他通道写在 channels 中，
+// 客户端会在自己通道和 stable 通道中选择覆盖本机的最高版本。
+// rollout 为灰度发布百分比(0-100)，缺省表示全量。
+type Manifest struct {
+	Version        string               `json:"version"`            // 发布版本号
+	ReleaseNotes   string               `json:"release_notes"`      // 更新说明
+	Mandatory      bool                 `json:"mandatory"`          // 是否强制更新
+	MinimumVersion string               `json:"minimum_version"`    // 最低支持版本，低于该版本的客户端必须更新
+	PublishedAt    time.Time            `json:"published_at"`       // 发布时间
+	Rollout        *int                 `json:"rollout,omitempty"`  // 灰度百分比，缺省为 100
+	Assets         map[string]Asset     `json:"assets"`             // 各平台的更新包
+	Channels       map[string]*Manifest `json:"channels,omitempty"` // 其他通道的发布
+}
+
+// Asset 单个平台的更新包
+type Asset struct {
+	URL       string           `json:"url"`                 // 下载地址
+	Size      int64            `json:"size"`                // 文件大小（字节）
+	SHA256    string           `json:"sha256"`              // 文件 SHA-256（十六进制）
+	Signature string           `json:"signature,omitempty"` // 文件的 base64 Ed25519 签名
+	Deltas    map[string]Delta `json:"deltas,omitempty"`    // 从旧版本到本版本的增量补丁，键为旧版本号
+	FilesURL  string           `json:"files_url,omitempty"` // 文件级清单地址，用于 .app 包和安装目录的差异更新
+}
+
+// Delta 增量补丁，仅适用于单个可执行文件的应用
+type Delta struct {
+	URL    string `json:"url"`    // 补丁下载地址
+	Size   int64  `json:"size"`   // 补丁大小（字节）
+	SHA256 string `json:"sha256"` // 补丁文件 SHA-256（十六进制）
+}
+
+// Release 检查得到的可用更新
+type Release struct {
+	Version      string    // 新版本号
+	ReleaseNotes string    // 更新说明
+	Mandatory    bool      // 是否必须更新（清单要求或当前版本低于最低支持版本）
+	PublishedAt  time.Time // 发布时间
+	Channel      string    // 发布所在的通道
+	Platform     string    // 匹配到的平台键
+	Asset        Asset     // 当前平台的更新包
+	Delta        *Delta    // 从当前版本升级可用的增量补丁，没有时为 nil
+}
+
+// Prepare 将更新信息填入配置，并返回下载完成后的更新包路径
+// 返回的配置使用内置 HTTPDownloader 下载到 UpdatePath，并带上摘要、大小和签名以便校验。
+// 有可用的增量补丁时改用 DeltaDownloader，补丁失败会自动回退到完整更新包；
+// 配置了文件级清单时改用 FileDownloader，返回的路径为暂存的新版本目录。
+func (r *Release) Prepare(config Config) (Config, string) {
+	if r.Asset.FilesURL != "" {
+		files := NewFileDownloader(r.Asset.FilesURL, defaultInstallRoot(), config.UpdatePath)
+		files.PublicKeys = config.PublicKeys
+		files.Logger = config.Logger
+		files.Locale = config.Locale
+
+		config.UpdateVersion = r.Version
+		config.DownloadImpl = files
+		config.PackageSHA256 = ""
+		config.PackageSize = 0
+		config.PackageSignature = ""
+		return config, files.Path()
+	}
+
+	var downloader interface {
+		DownloadImplementation
+		Path() string
+	}
+	if r.Delta != nil {
+		delta := NewDeltaDownloader(r.Delta.URL, r.Asset.URL, config.UpdatePath)
+		delta.SHA256 = r.Asset.SHA256
+		delta.PatchSHA256 = r.Delta.SHA256
+		delta.PatchSize = r.Delta.Size
+		delta.Logger = config.Logger
+		downloader = delta
+	} else {
+		downloader = NewHTTPDownloader(r.Asset.URL, config.UpdatePath)
+	}
+
+	config.UpdateVersion = r.Version
+	config.DownloadImpl = downloader
+	config.PackageSHA256 = r.Asset.SHA256
+	config.PackageSize = r.Asset.Size
+	config.PackageSignature = r.Asset.Signature
+	return config, downloader.Path()
+}
+
+// Checker 更新检查器
+type Checker struct {
+	config      Config
+	manifestURL string
+	client      *http.Client
+}
+
+// NewChecker 创建更新检查器
+func NewChecker(config Config, manifestURL string) *Checker {
+	return &Checker{
+		config:      config,
+		manifestURL: manifestURL,
+		client:      http.DefaultClient,
+	}
+}
+
+// SetHTTPClient 设置请求清单使用的 HTTP 客户端
+func (c *Checker) SetHTTPClient(client *http.Client) {
+	c.client = client
+}
+
+// Check 检查是否有新版本，没有可用更新时返回 nil, nil
+func (c *Checker) Check(ctx context.Context) (*Release, error) {
+	manifest, err := c.Fetch(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return c.Resolve(manifest)
+}
+
+// Fetch 下载并解析清单，配置了 PublicKeys 时同时校验清单签名（清单地址 + ".sig"）
+func (c *Checker) Fetch(ctx context.Context) (*Manifest, error) {
+	data, err := c.get(ctx, c.manifestURL)
+	if err != nil {
+		return nil, fmt.Errorf("获取更新清单失败: %w", err)
+	}
+
+	if len(c.config.PublicKeys) > 0 {
+		sig, err := c.get(ctx, c.manifestURL+SignatureSuffix)
+		if err != nil {
+			return nil, fmt.Errorf("%w: 获取清单签名失败: %w", ErrSignature, err)
+		}
+		if err := VerifySignature(data, string(sig), c.config.PublicKeys); err != nil {
+			return nil, fmt.Errorf("更新清单%w", err)
+		}
+	}
+
+	var manifest Manifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("解析更新清单失败: %w", err)
+	}
+	if manifest.Version == "" && len(manifest.Channels) == 0 {
+		return nil, errors.New("更新清单缺少版本号")
+	}
+	return &manifest, nil
+}
+
+// Resolve 根据通道、灰度、当前版本和平台从清单中选出可用更新，没有可用更新时返回 nil, nil
+func (c *Checker) Resolve(manifest *Manifest) (*Release, error) {
+	channel, selected, latest, err := c.selectRelease(manifest)
+	if err != nil || selected == nil {
+		return nil, err
+	}
+
+	var current version.Version
+	hasCurrent := c.config.CurrentVersion != ""
+	if hasCurrent {
+		if current, err = version.Parse(c.config.CurrentVersion); err != nil {
+			return nil, fmt.Errorf("当前版本号无效: %w", err)
+		}
+		if !current.LessThan(latest) {
+			return nil, nil
+		}
+	}
+
+	platform, asset, ok := selected.asset()
+	if !ok {
+		return nil, fmt.Errorf("更新清单中没有当前平台的更新包: %s-%s", runtime.GOOS, runtime.GOARCH)
+	}
+	if asset.URL == "" {
+		return nil, fmt.Errorf("更新包缺少下载地址: %s", platform)
+	}
+
+	mandatory := selected.Mandatory
+	if selected.MinimumVersion != "" && hasCurrent {
+		minimum, err := version.Parse(selected.MinimumVersion)
+		if err != nil {
+			return nil, fmt.Errorf("更新清单最低支持版本无效: %w", err)
+		}
+		if current.LessThan(minimum) {
+			mandatory = true
+		}
+	}
+
+	release := &Release{
+		Version:      selected.Version,
+		ReleaseNotes: selected.ReleaseNotes,
+		Mandatory:    mandatory,
+		PublishedAt:  selected.PublishedAt,
+		Channel:      channel,
+		Platform:     platform,
+		Asset:        asset,
+	}
+	if hasCurrent {
+		release.Delta = asset.delta(current)
+	}
+	return release, nil
+}
+
+// selectRelease 在配置的通道和 stable 通道中选出本机处于灰度范围内的最高版本，跳过 FailedVersions 中的版本
+func (c *Checker) selectRelease(manifest *Manifest) (string, *Manifest, version.Version, error) {
+	channels := []string{ChannelStable}
+	candidates := map[string]*Manifest{ChannelStable: manifest}
+	if channel := c.config.channel(); channel != ChannelStable {
+		if m, ok := manifest.Channels[channel]; ok && m != nil {
+			channels = append(channels, channel)
+			candidates[channel] = m
+		}
+	}
+
+	// 未通过健康确认而被回滚的版本不再提供
+	failed, _ := FailedVersions(c.config.UpdatePath)
+
+	var (
+		bestChannel string
+		best        *Manifest
+		bestVersion version.Version
+	)
+	for _, channel := range channels {
+		m := candidates[channel]
+		if m.Version == "" {
+			continue
+		}
+		v, err := version.Parse(m.Version)
+		if err != nil {
+			return "", nil, version.Version{}, fmt.Errorf("更新清单版本号无效: %w", err)
+		}
+		if best != nil && !bestVersion.LessThan(v) {
+			continue
+		}
+		if isFailedVersion(failed, v) {
+			continue
+		}
+
+		in, err := c.inRollout(m, v)
+		if err != nil {
+			return "", nil, version.Version{}, err
+		}
+		if !in {
+			continue
+		}
+		bestChannel, best, bestVersion = channel, m, v
+	}
+	return bestChannel, best, bestVersion, nil
+}
+
+// inRollout 判断本机是否处于该发布的灰度范围内
+func (c *Checker) inRollout(m *Manifest, v version.Version) (bool, error) {
+	if m.Rollout == nil || *m.Rollout >= 100 {
+		return true, nil
+	}
+	if *m.Rollout <= 0 {
+		return false, nil
+	}
+
+	id, err := InstallID(c.config.UpdatePath)
+	if err != nil {
+		return false, fmt.Errorf("获取安装标识失败: %w", err)
+	}
+	return RolloutBucket(id, v.String()) < *m.Rollout, nil
+}
+
+// asset 查找当前平台的更新包
+func (m *Manifest) asset() (string, Asset, bool) {
+	for _, key := range []string{runtime.GOOS + "-" + runtime.GOARCH, runtime.GOOS} {
+		if asset, ok := m.Assets[key]; ok {
+			return key, asset, true
+		}
+	}
+	return "", Asset{}, false
+}
+
+// delta 查找从 current 升级的增量补丁，键按版本号比较
+func (a Asset) delta(current version.Version) *Delta {
+	for from, d := range a.Deltas {
+		v, err := version.Parse(from)
+		if err == nil && v.Equal(current) && d.URL != "" {
+			d := d
+			return &d
+		}
+	}
+	return nil
+}
+
+// get 请求 URL 并返回响应内容
+func (c *Checker) get(ctx context.Context, url string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("HTTP 状态码: %d", resp.StatusCode)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, manifestMaxSize))
+}
@@ -0,0 +1,137 @@
+package hotupdater
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"testing"
+)
+
+// serveManifest 提供清单和清单签名，sig 为空时签名地址返回 404
+func serveManifest(t *testing.T, data []byte, sig string) *httptest.Server {
+	t.Helper()
+	mux := http.NewServeMux()
+	mux.HandleFunc("/manifest.json", func(w http.ResponseWriter, r *http.Request) { w.Write(data) })
+	mux.HandleFunc("/manifest.json.sig", func(w http.ResponseWriter, r *http.Request) {
+		if sig == "" {
+			http.NotFound(w, r)
+			return
+		}
+		io.WriteString(w, sig)
+	})
+	s := httptest.NewServer(mux)
+	t.Cleanup(s.Close)
+	return s
+}
+
+func testManifest(t *testing.T) []byte {
+	t.Helper()
+	data, err := json.Marshal(Manifest{
+		Version:        "1.1.0",
+		ReleaseNotes:   "修复若干问题",
+		MinimumVersion: "1.0.5",
+		Assets: map[string]Asset{
+			runtime.GOOS + "-" + runtime.GOARCH: {
+				URL:    "https://example.com/app_1.1.0",
+				Size:   1024,
+				SHA256: "abc",
+				Deltas: map[string]Delta{"v1.0.0": {URL: "https://example.com/1.0.0-1.1.0.patch", Size: 64}},
+			},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestCheckerCheck(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := testManifest(t)
+	s := serveManifest(t, data, SignBytes(data, priv))
+
+	config := Config{UpdatePath: t.TempDir(), CurrentVersion: "1.0.0", PublicKeys: []ed25519.PublicKey{pub}}
+	release, err := NewChecker(config, s.URL+"/manifest.json").Check(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if release == nil || release.Version != "1.1.0" || release.Channel != ChannelStable {
+		t.Fatalf("检查结果为 %+v", release)
+	}
+	if release.Platform != runtime.GOOS+"-"+runtime.GOARCH || release.Asset.URL != "https://example.com/app_1.1.0" {
+		t.Fatalf("平台或更新包不正确: %s %+v", release.Platform, release.Asset)
+	}
+	if !release.Mandatory {
+		t.Fatal("当前版本低于最低支持版本，应强制更新")
+	}
+	if release.Delta == nil || release.Delta.URL != "https://example.com/1.0.0-1.1.0.patch" {
+		t.Fatalf("增量补丁为 %+v", release.Delta)
+	}
+}
+
+func TestCheckerRejectsBadSignature(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := testManifest(t)
+	tampered := bytes.Replace(data, []byte("app_1.1.0"), []byte("evil_1.1"), 1)
+	config := Config{UpdatePath: t.TempDir(), CurrentVersion: "1.0.0", PublicKeys: []ed25519.PublicKey{pub}}
+
+	cases := []struct {
+		name string
+		data []byte
+		sig  string
+	}{
+		{"清单被篡改", tampered, SignBytes(data, priv)},
+		{"缺少签名", data, ""},
+		{"签名格式错误", data, "not-a-signature"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			s := serveManifest(t, c.data, c.sig)
+			release, err := NewChecker(config, s.URL+"/manifest.json").Check(context.Background())
+			if !errors.Is(err, ErrSignature) || release != nil {
+				t.Fatalf("应返回 ErrSignature，实际 %+v %v", release, err)
+			}
+		})
+	}
+
+	// 没有配置公钥时不校验签名
+	s := serveManifest(t, tampered, "")
+	if _, err := NewChecker(Config{UpdatePath: t.TempDir()}, s.URL+"/manifest.json").Check(context.Background()); err != nil {
+		t.Fatalf("未配置公钥时不应校验签名: %v", err)
+	}
+}
+
+func TestCheckerNoNewerRelease(t *testing.T) {
+	data := testManifest(t)
+	s := serveManifest(t, data, "")
+	for _, current := range []string{"1.1.0", "v1.1", "1.2.0"} {
+		config := Config{UpdatePath: t.TempDir(), CurrentVersion: current}
+		release, err := NewChecker(config, s.URL+"/manifest.json").Check(context.Background())
+		if err != nil || release != nil {
+			t.Fatalf("当前版本 %s 不应有可用更新: %+v %v", current, release, err)
+		}
+	}
+
+	// 没有当前平台的更新包
+	var m Manifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		t.Fatal(err)
+	}
+	m.Assets = map[string]Asset{"plan9-386": {URL: "https://example.com/app"}}
+	checker := NewChecker(Config{UpdatePath: t.TempDir(), CurrentVersion: "1.0.0"}, "")
+	if release, err := checker.Resolve(&m); err == nil || release != nil {
+		t.Fatalf("没有当前平台的更新包时应返回错误: %+v %v", release, err)
+	}
+}
@@ -0,0 +1,229 @@
+package hotupdater
+
+import (
+	"context"
+	"errors"
+)
+
+// UpdateStep 更新流水线中的一个步骤
+// Run 失败时流水线会按相反顺序调用已执行步骤（包括失败的步骤本身）的 Rollback，
+// 因此 Rollback 需要能处理 Run 只执行了一部分的情况。
+type UpdateStep interface {
+	Run(sc *StepContext) error
+	Rollback(sc *StepContext) error
+}
+
+// Stage 流水线中的一个阶段，Phase 决定该步骤进度事件所属的更新阶段
+type Stage struct {
+	Name  string      // 步骤名称，用于日志和错误信息，可以是消息 ID（如 stage.backup）
+	Phase UpdatePhase // 进度阶段，自定义阶段需要先用 ProgressModel.Declare 声明
+	Step  UpdateStep
+}
+
+// Pipeline 用 Go 代码描述的更新流程，可替代 Lua 脚本
+//
+//	pipeline := hotupdater.DefaultPipeline()
+//	// 或自行组合
+//	pipeline := hotupdater.NewPipeline().
+//		Add(hotupdater.PhasePreCheck, "环境检查", &hotupdater.PrecheckStep{}).
+//		Add(hotupdater.PhaseBackup, "备份", &hotupdater.BackupStep{}).
+//		Add(hotupdater.PhaseInstall, "安装", &hotupdater.InstallStep{}).
+//		Add(hotupdater.PhaseVerify, "验证", &hotupdater.VerifyStep{Check: healthCheck}).
+//		Add(hotupdater.PhaseComplete, "清理", &hotupdater.CleanupStep{})
+type Pipeline struct {
+	Stages []Stage
+}
+
+// NewPipeline 创建空流水线
+func NewPipeline() *Pipeline {
+	return &Pipeline{}
+}
+
+// DefaultPipeline 返回内置流程：检查、备份、安装、验证、清理
+func DefaultPipeline() *Pipeline {
+	return NewPipeline().
+		Add(PhasePreCheck, string(MsgStagePrecheck), &PrecheckStep{}).
+		Add(PhaseBackup, string(MsgStageBackup), &BackupStep{}).
+		Add(PhaseInstall, string(MsgStageInstall), &InstallStep{}).
+		Add(PhaseVerify, string(MsgStageVerify), &VerifyStep{}).
+		Add(PhaseComplete, string(MsgStageCleanup), &CleanupStep{})
+}
+
+// Add 追加步骤
+func (p *Pipeline) Add(phase UpdatePhase, name string, step UpdateStep) *Pipeline {
+	p.Stages = append(p.Stages, Stage{Name: name, Phase: phase, Step: step})
+	return p
+}
+
+// Run 依次执行各步骤，任一步骤失败或 Context 被取消时按相反顺序回滚
+// 失败时返回 *UpdateError，Kind 为 ErrInstallFailed（取消时为 ErrCancelled），
+// 并按回滚结果匹配 ErrRolledBack 或 ErrRollbackFailed。回滚本身不会被取消。
+func (p *Pipeline) Run(sc *StepContext) error {
+	l := sc.localizer()
+	for i, stage := range p.Stages {
+		if err := sc.Context.Err(); err != nil {
+			sc.Logf("更新已取消: %v，开始回滚", err)
+			if rbErr := p.rollback(sc, i-1); rbErr != nil {
+				return &UpdateError{Kind: ErrCancelled, Phase: stage.Phase, Err: l.errorOf(MsgErrCancelRollback, err, rbErr, err), RollbackErr: rbErr}
+			}
+			return &UpdateError{Kind: ErrCancelled, Phase: stage.Phase, Err: l.wrap(MsgErrCancelled, err), RolledBack: true}
+		}
+
+		// 进入备份、安装阶段前执行 Before 钩子，否决时回滚已执行的步骤
+		before, after := phaseHooks(stage.Phase)
+		if i == 0 || p.Stages[i-1].Phase != stage.Phase {
+			if err := p.runHook(sc, i-1, before); err != nil {
+				return err
+			}
+		}
+
+		name := sc.T(MessageID(stage.Name))
+		sc.phase = stage.Phase
+		sc.Logf("执行步骤: %s", name)
+		if err := stage.Step.Run(sc); err != nil {
+			kind := ErrInstallFailed
+			if sc.Context.Err() != nil {
+				kind = ErrCancelled
+			}
+			sc.Logf("步骤 %s 失败: %v，开始回滚", name, err)
+			if rbErr := p.rollback(sc, i); rbErr != nil {
+				return &UpdateError{Kind: kind, Phase: stage.Phase, Err: l.errorOf(MsgErrStepRollbackFailed, err, name, rbErr, err), RollbackErr: rbErr}
+			}
+			return &UpdateError{Kind: kind, Phase: stage.Phase, Err: l.wrap(MsgErrStepFailed, err, name), RolledBack: true}
+		}
+
+		// 离开阶段后执行 After 钩子，否决时连同当前步骤一起回滚
+		if i == len(p.Stages)-1 || p.Stages[i+1].Phase != stage.Phase {
+			if err := p.runHook(sc, i, after); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// runHook 执行 Config.Hooks 中的钩子，否决时从第 last 个步骤开始回滚
+func (p *Pipeline) runHook(sc *StepContext, last int, point HookPoint) error {
+	if point == "" {
+		return nil
+	}
+	hc := sc.Config.hookContext(sc.Context, point)
+	hc.NewVersion = sc.NewVersion
+	hc.Target = sc.Target
+	hc.BackupFile = sc.BackupFile
+	err := sc.Config.runHook(hc)
+	if err == nil {
+		return nil
+	}
+
+	ue := err.(*UpdateError)
+	if rbErr := p.rollback(sc, last); rbErr != nil {
+		ue.Err = sc.localizer().errorOf(MsgErrStepRollbackFailed, ue.Err, point, rbErr, ue.Err)
+		ue.RollbackErr = rbErr
+		return ue
+	}
+	ue.RolledBack = last >= 0
+	return ue
+}
+
+// rollback 从第 last 个步骤开始倒序回滚，返回第一个回滚错误
+func (p *Pipeline) rollback(sc *StepContext, last int) error {
+	var firstErr error
+	for i := last; i >= 0; i-- {
+		stage := p.Stages[i]
+		name := sc.T(MessageID(stage.Name))
+		sc.phase = stage.Phase
+		if err := stage.Step.Rollback(sc); err != nil {
+			sc.Logf("回滚步骤 %s 失败: %v", name, err)
+			if firstErr == nil {
+				firstErr = sc.localizer().wrap(MsgErrRollbackStep, err, name)
+			}
+		}
+	}
+	return firstErr
+}
+
+// StepContext 步骤之间共享的更新状态
+type StepContext struct {
+	Context    context.Context
+	Config     Config
+	CurrentExe string                 // 当前可执行文件路径
+	NewVersion string                 // 新版本路径（文件或目录）
+	Target     string                 // 被替换的路径，为空时由 PrecheckStep 确定
+	BackupFile string                 // 备份文件路径，由 BackupStep 写入
+	Previous   string                 // 被替换下来的旧版本，由 InstallStep 写入，CleanupStep 删除
+	Values     map[string]interface{} // 自定义步骤之间传递数据
+
+	phase   UpdatePhase
+	helper  *helper
+	journal *Journal
+}
+
+// Progress 报告当前步骤的阶段内进度（0-100）
+func (sc *StepContext) Progress(percentage int, detail string) {
+	sc.helper.emitProgress(sc.phase, percentage, detail)
+}
+
+// ProgressID 报告当前步骤的阶段内进度，详情为消息 id 按 Config.Locale 翻译后的文本
+func (sc *StepContext) ProgressID(percentage int, id MessageID, args ...interface{}) {
+	sc.helper.emitProgressID(sc.phase, percentage, id, args...)
+}
+
+// T 按 Config.Locale 查找消息，没有时返回 id 本身
+func (sc *StepContext) T(id MessageID, args ...interface{}) string {
+	return sc.localizer().T(id, args...)
+}
+
+func (sc *StepContext) localizer() *Localizer {
+	return sc.helper.l
+}
+
+// record 写入更新日志，日志写入失败只记录，不影响更新
+func (sc *StepContext) record(state JournalState) {
+	err := sc.journal.Record(JournalEntry{
+		State:          state,
+		Target:         sc.Target,
+		NewVersion:     sc.NewVersion,
+		BackupFile:     sc.BackupFile,
+		Previous:       sc.Previous,
+		CurrentVersion: sc.Config.CurrentVersion,
+		UpdateVersion:  sc.Config.UpdateVersion,
+	})
+	if err != nil {
+		sc.Logf("写入更新日志失败: %v", err)
+	}
+}
+
+// Logf 输出日志
+func (sc *StepContext) Logf(format string, args ...interface{}) {
+	if sc.helper.logger != nil {
+		sc.helper.logger.Logf(format, args...)
+	}
+}
+
+// runPipeline 以平台更新器的状态构造 StepContext 并执行流水线
+// 配置了 UpdatePath 时在其中记录更新日志，只有更新完成或回滚成功（或无需回滚）时才删除，其余情况保留给 Recover。
+func (h *helper) runPipeline(ctx context.Context, p *Pipeline, config Config, currentExe, newVersion string) error {
+	journal, err := OpenJournal(config.UpdatePath)
+	if err != nil {
+		h.logf("创建更新日志失败: %v", err)
+	}
+	sc := &StepContext{
+		Context:    ctx,
+		Config:     config,
+		CurrentExe: currentExe,
+		NewVersion: newVersion,
+		Values:     make(map[string]interface{}),
+		helper:     h,
+		journal:    journal,
+	}
+	sc.record(JournalBegin)
+
+	err = p.Run(sc)
+	var ue *UpdateError
+	if err == nil || (errors.As(err, &ue) && ue.RollbackErr == nil) {
+		sc.record(JournalCommitted)
+		journal.Commit()
+	}
+	return err
+}
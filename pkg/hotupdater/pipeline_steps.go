@@ -0,0 +1,290 @@
+package hotupdater
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// PrecheckStep 检查新版本和写入权限，并在 Target 为空时确定被替换的路径：
+// 新版本为目录时替换安装目录（macOS 为 .app 包），为文件时替换当前可执行文件。
+// 替换目录时 Config.UpdatePath 和 BackupPath 不能位于该目录中。
+type PrecheckStep struct{}
+
+func (s *PrecheckStep) Run(sc *StepContext) error {
+	sc.ProgressID(0, MsgPrecheckStart)
+	info, err := os.Stat(sc.NewVersion)
+	if err != nil {
+		return sc.localizer().wrap(MsgErrNewVersionInvalid, err)
+	}
+
+	if sc.Target == "" {
+		sc.Target = sc.CurrentExe
+		if info.IsDir() {
+			sc.Target = installRoot(sc.CurrentExe)
+		}
+	}
+	sc.Logf("更新目标: %s", sc.Target)
+
+	current, err := os.Stat(sc.Target)
+	if err != nil {
+		return sc.localizer().wrap(MsgErrCurrentInvalid, err)
+	}
+	if current.IsDir() != info.IsDir() {
+		return sc.localizer().newError(MsgErrTypeMismatch, sc.NewVersion)
+	}
+	// 替换目录时旧目录会被删除，其中的更新日志、健康确认记录和备份也会随之丢失
+	if current.IsDir() {
+		root := absPath(sc.Target)
+		for _, path := range []string{sc.Config.UpdatePath, sc.Config.BackupPath} {
+			if path != "" && pathWithin(root, absPath(path)) {
+				return sc.localizer().newError(MsgErrPathInTarget, path, sc.Target)
+			}
+		}
+	}
+
+	sc.ProgressID(50, MsgPrecheckPermission)
+	if err := checkWritable(sc.localizer(), filepath.Dir(sc.Target)); err != nil {
+		return err
+	}
+	sc.ProgressID(100, MsgPrecheckDone)
+	return nil
+}
+
+func (s *PrecheckStep) Rollback(sc *StepContext) error {
+	return nil
+}
+
+// BackupStep 备份 Target，文件名规则与 update.lua 一致，同时写入说明文件（见 BackupMeta）
+// Dir 为空时使用 Config.BackupPath，仍为空时使用 Target 同级的 backup 目录。
+// 回滚时保留备份文件，便于用恢复助手手动恢复。
+type BackupStep struct {
+	Dir string
+}
+
+func (s *BackupStep) Run(sc *StepContext) error {
+	sc.ProgressID(0, MsgBackupPrepare)
+	dir := s.Dir
+	if dir == "" {
+		dir = sc.Config.BackupPath
+	}
+	if dir == "" {
+		dir = defaultBackupDir(sc.Target)
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return sc.localizer().wrap(MsgErrBackupDir, err)
+	}
+
+	info, err := os.Stat(sc.Target)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	backupFile := filepath.Join(dir, newBackupName(sc.Config.CurrentVersion, info.IsDir(), now))
+	sc.Logf("创建备份文件: %s", backupFile)
+	sc.ProgressID(30, MsgBackupCreate)
+	sc.BackupFile = backupFile
+	sc.record(JournalBackingUp)
+
+	if err := writeBackup(sc.Target, backupFile); err != nil {
+		sc.BackupFile = ""
+		return err
+	}
+	err = WriteBackupMeta(backupFile, BackupMeta{
+		Version:       sc.Config.CurrentVersion,
+		Time:          now,
+		Target:        sc.Target,
+		UpdateVersion: sc.Config.UpdateVersion,
+	})
+	if err != nil {
+		sc.Logf("写入备份说明文件失败: %v", err)
+	}
+
+	sc.record(JournalBackedUp)
+	sc.ProgressID(100, MsgBackupDone)
+	return nil
+}
+
+func (s *BackupStep) Rollback(sc *StepContext) error {
+	return nil
+}
+
+// InstallStep 用新版本替换 Target
+// 新版本先复制到同级的 .new 临时路径，再把旧版本重命名为 .old、临时路径重命名为 Target，
+// 旧版本保留到 CleanupStep，回滚时直接重命名回来，失败时再从备份恢复。
+type InstallStep struct{}
+
+func (s *InstallStep) Run(sc *StepContext) error {
+	sc.ProgressID(0, MsgInstallPrepare)
+	staging := sc.Target + ".new"
+	old := sc.Target + ".old"
+	os.RemoveAll(staging)
+	os.RemoveAll(old)
+
+	sc.ProgressID(20, MsgInstallCopy)
+	if err := copyPath(sc.NewVersion, staging); err != nil {
+		os.RemoveAll(staging)
+		return sc.localizer().wrap(MsgErrCopyNew, err)
+	}
+	if runtime.GOOS != "windows" {
+		if info, err := os.Stat(staging); err == nil && !info.IsDir() {
+			if err := os.Chmod(staging, 0755); err != nil {
+				return sc.localizer().wrap(MsgErrChmod, err)
+			}
+		}
+	}
+
+	// 替换前最后一次检查取消，之后的取消由流水线回滚
+	if err := sc.Context.Err(); err != nil {
+		os.RemoveAll(staging)
+		return err
+	}
+
+	sc.ProgressID(70, MsgInstallReplace)
+	sc.Previous = old
+	sc.record(JournalInstalling)
+	if err := os.Rename(sc.Target, old); err != nil {
+		sc.Previous = ""
+		return sc.localizer().wrap(MsgErrMoveOld, err)
+	}
+	if err := os.Rename(staging, sc.Target); err != nil {
+		return sc.localizer().wrap(MsgErrReplace, err)
+	}
+	sc.record(JournalInstalled)
+
+	sc.ProgressID(100, MsgInstallDone)
+	return nil
+}
+
+func (s *InstallStep) Rollback(sc *StepContext) error {
+	os.RemoveAll(sc.Target + ".new")
+	if sc.Previous == "" {
+		return nil
+	}
+
+	// 回滚途中中断或失败时，Recover 据此继续恢复，而不是当作已完成的更新删除 Previous
+	sc.ProgressID(0, MsgInstallRestore)
+	sc.record(JournalRollingBack)
+	if _, err := os.Lstat(sc.Previous); err == nil {
+		if err := os.RemoveAll(sc.Target); err == nil {
+			if err := os.Rename(sc.Previous, sc.Target); err == nil {
+				sc.Previous = ""
+				sc.Logf("已恢复旧版本: %s", sc.Target)
+				return nil
+			}
+		}
+	}
+
+	if sc.BackupFile == "" {
+		return sc.localizer().newError(MsgErrRestorePrevious, sc.Target)
+	}
+	sc.Logf("从备份恢复: %s", sc.BackupFile)
+	if err := restoreBackupFile(sc.BackupFile, sc.Target); err != nil {
+		return sc.localizer().wrap(MsgErrRestoreBackup, err)
+	}
+	sc.Previous = ""
+	return nil
+}
+
+// VerifyStep 检查安装结果：文件非空且可执行，目录非空；Check 非空时再执行自定义检查
+type VerifyStep struct {
+	Check func(sc *StepContext) error
+}
+
+func (s *VerifyStep) Run(sc *StepContext) error {
+	sc.ProgressID(0, MsgVerifyStart)
+	info, err := os.Stat(sc.Target)
+	if err != nil {
+		return err
+	}
+
+	if info.IsDir() {
+		entries, err := os.ReadDir(sc.Target)
+		if err != nil {
+			return err
+		}
+		if len(entries) == 0 {
+			return sc.localizer().newError(MsgErrEmptyDir, sc.Target)
+		}
+	} else {
+		if info.Size() == 0 {
+			return sc.localizer().newError(MsgErrEmptyFile, sc.Target)
+		}
+		if runtime.GOOS != "windows" && info.Mode().Perm()&0111 == 0 {
+			return sc.localizer().newError(MsgErrNotExecutable, sc.Target)
+		}
+	}
+
+	if s.Check != nil {
+		sc.ProgressID(50, MsgVerifyCustom)
+		if err := s.Check(sc); err != nil {
+			return err
+		}
+	}
+	sc.ProgressID(100, MsgVerifyDone)
+	return nil
+}
+
+func (s *VerifyStep) Rollback(sc *StepContext) error {
+	return nil
+}
+
+// CleanupStep 删除被替换下来的旧版本，RemovePackage 为 true 时同时删除新版本安装包
+// 清理失败只记录日志，不影响更新结果（如 Windows 下仍在运行的旧可执行文件无法删除）。
+type CleanupStep struct {
+	RemovePackage bool
+}
+
+func (s *CleanupStep) Run(sc *StepContext) error {
+	sc.ProgressID(0, MsgCleanupStart)
+	if sc.Previous != "" {
+		// 删除后之后的步骤失败时，InstallStep 找不到 Previous，会改为从备份恢复
+		if err := os.RemoveAll(sc.Previous); err != nil {
+			sc.Logf("清理旧版本失败: %v", err)
+		}
+	}
+	if s.RemovePackage {
+		if err := os.RemoveAll(sc.NewVersion); err != nil {
+			sc.Logf("清理更新包失败: %v", err)
+		}
+	}
+	sc.ProgressID(100, MsgCleanupDone)
+	return nil
+}
+
+func (s *CleanupStep) Rollback(sc *StepContext) error {
+	return nil
+}
+
+// checkWritable 通过创建临时文件检查目录是否可写
+func checkWritable(l *Localizer, dir string) error {
+	f, err := os.CreateTemp(dir, ".hotupdater-*")
+	if err != nil {
+		if errors.Is(err, os.ErrPermission) {
+			return l.newError(MsgErrNotWritable, dir)
+		}
+		return l.wrap(MsgErrCheckWritable, err)
+	}
+	name := f.Name()
+	f.Close()
+	return os.Remove(name)
+}
+
+// absPath 返回解析了符号链接的绝对路径，用于比较路径
+func absPath(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	return resolvePath(path)
+}
+
+// installRoot 返回可执行文件所属的安装目录：macOS 为 .app 包，其他平台为可执行文件所在目录
+func installRoot(exe string) string {
+	if idx := strings.Index(exe, ".app/"); idx != -1 {
+		return exe[:idx+4]
+	}
+	return filepath.Dir(exe)
+}
@@ -0,0 +1,170 @@
+package hotupdater
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// funcStep 用函数实现的步骤
+type funcStep struct {
+	run      func(sc *StepContext) error
+	rollback func(sc *StepContext) error
+}
+
+func (s *funcStep) Run(sc *StepContext) error {
+	if s.run == nil {
+		return nil
+	}
+	return s.run(sc)
+}
+
+func (s *funcStep) Rollback(sc *StepContext) error {
+	if s.rollback == nil {
+		return nil
+	}
+	return s.rollback(sc)
+}
+
+// runInstallPipeline 安装 new 目录后执行 after 步骤，返回流水线的错误
+func runInstallPipeline(t *testing.T, after *funcStep) (Config, string, error) {
+	t.Helper()
+	dir := t.TempDir()
+	target := filepath.Join(dir, "app")
+	newVersion := filepath.Join(dir, "new")
+	for path, content := range map[string]string{target: "old", newVersion: "new"} {
+		if err := os.MkdirAll(path, 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(path, "version"), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	config := Config{UpdatePath: filepath.Join(dir, "update")}
+	p := NewPipeline().
+		Add(PhasePreCheck, "target", &funcStep{run: func(sc *StepContext) error {
+			sc.Target = target
+			return nil
+		}}).
+		Add(PhaseInstall, "install", &InstallStep{}).
+		Add(PhaseVerify, "after", after)
+	err := newHelper(config).runPipeline(context.Background(), p, config, target, newVersion)
+	return config, target, err
+}
+
+func TestRollbackFailureKeepsJournal(t *testing.T) {
+	// 旧版本和备份都不可用，InstallStep 无法回滚
+	config, target, err := runInstallPipeline(t, &funcStep{run: func(sc *StepContext) error {
+		os.RemoveAll(sc.Previous)
+		return errors.New("验证失败")
+	}})
+	if !errors.Is(err, ErrRollbackFailed) {
+		t.Fatalf("期望回滚失败，实际 %v", err)
+	}
+	entry, err := ReadJournal(config.UpdatePath)
+	if err != nil || entry == nil {
+		t.Fatalf("回滚失败时应保留更新日志: %v", err)
+	}
+	if entry.State != JournalRollingBack {
+		t.Fatalf("日志状态为 %s，期望 %s", entry.State, JournalRollingBack)
+	}
+
+	r, err := Recover(config)
+	if err == nil || r.Action == RecoveryCompleted {
+		t.Fatalf("无法恢复时不应当作更新已完成: %v %v", r.Action, err)
+	}
+	if _, err := os.Stat(target); err != nil {
+		t.Fatalf("Target 不应被删除: %v", err)
+	}
+}
+
+func TestRollbackSuccessCommitsJournal(t *testing.T) {
+	config, target, err := runInstallPipeline(t, &funcStep{run: func(sc *StepContext) error {
+		return errors.New("验证失败")
+	}})
+	if !errors.Is(err, ErrRolledBack) {
+		t.Fatalf("期望已回滚，实际 %v", err)
+	}
+	if entry, _ := ReadJournal(config.UpdatePath); entry != nil {
+		t.Fatalf("回滚成功后应删除更新日志，状态 %s", entry.State)
+	}
+	data, err := os.ReadFile(filepath.Join(target, "version"))
+	if err != nil || string(data) != "old" {
+		t.Fatalf("应恢复旧版本: %v %q", err, data)
+	}
+}
+
+func TestRecoverRollingBack(t *testing.T) {
+	dir := t.TempDir()
+	target := filepath.Join(dir, "app")
+	previous := target + ".old"
+	if err := os.MkdirAll(previous, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(previous, "version"), []byte("old"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	config := Config{UpdatePath: filepath.Join(dir, "update")}
+	j, err := OpenJournal(config.UpdatePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	j.Record(JournalEntry{State: JournalInstalled, Target: target, Previous: previous})
+	j.Record(JournalEntry{State: JournalRollingBack})
+
+	r, err := Recover(config)
+	if err != nil || r.Action != RecoveryRolledBack {
+		t.Fatalf("期望恢复旧版本，实际 %v %v", r.Action, err)
+	}
+	if data, err := os.ReadFile(filepath.Join(target, "version")); err != nil || string(data) != "old" {
+		t.Fatalf("应恢复旧版本: %v %q", err, data)
+	}
+}
+
+func TestPrecheckRejectsPathsInTarget(t *testing.T) {
+	dir := t.TempDir()
+	target := filepath.Join(dir, "app")
+	newVersion := filepath.Join(dir, "new")
+	for _, path := range []string{target, newVersion} {
+		if err := os.MkdirAll(path, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cases := []struct {
+		name    string
+		config  Config
+		wantErr bool
+	}{
+		{"更新路径在安装目录中", Config{UpdatePath: filepath.Join(target, "update")}, true},
+		{"备份路径在安装目录中", Config{BackupPath: filepath.Join(target, "backup")}, true},
+		{"更新路径为安装目录", Config{UpdatePath: target}, true},
+		{"路径在安装目录之外", Config{UpdatePath: filepath.Join(dir, "update"), BackupPath: filepath.Join(dir, "backup")}, false},
+		{"前缀相同的同级目录", Config{UpdatePath: target + "-data"}, false},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			sc := &StepContext{Context: context.Background(), Config: c.config, Target: target, NewVersion: newVersion, helper: newHelper(c.config)}
+			err := (&PrecheckStep{}).Run(sc)
+			if (err != nil) != c.wantErr {
+				t.Fatalf("PrecheckStep 返回 %v，期望出错: %v", err, c.wantErr)
+			}
+		})
+	}
+
+	// 替换单个文件时安装目录不会被删除
+	exe := filepath.Join(target, "app.bin")
+	newExe := filepath.Join(dir, "app.bin")
+	for _, path := range []string{exe, newExe} {
+		if err := os.WriteFile(path, []byte("bin"), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	config := Config{UpdatePath: filepath.Join(target, "update")}
+	sc := &StepContext{Context: context.Background(), Config: config, Target: exe, NewVersion: newExe, helper: newHelper(config)}
+	if err := (&PrecheckStep{}).Run(sc); err != nil {
+		t.Fatalf("替换文件时不应报错: %v", err)
+	}
+}
@@ -16,7 +16,8 @@ const (
 	PhaseComplete UpdatePhase = "complete" // 更新完成
 )
 
-// 每个阶段的进度范围
+// 每个阶段的进度范围，供包级 CalculateProgress 使用
+// 更新器按 Config.ProgressModel 计算进度，自定义阶段和权重请使用 ProgressModel。
 var PhaseRanges = map[UpdatePhase]struct{ Start, End int }{
 	PhaseDownload: {0, 70},   // 0-70%，下载占大部分时间
 	PhasePreCheck: {70, 75},  // 70-75%，检查很快
@@ -40,11 +41,15 @@ type UpdateProgress struct {
 	Phase      UpdatePhase `json:"phase"`      // 当前阶段
 	Percentage int         `json:"percentage"` // 总体进度百分比(0-100)
 	Speed      float64     `json:"speed"`      // 下载速度(MB/s)
-	Message    string      `json:"message"`    // 用户友好的提示信息
+	Current    int64       `json:"current"`    // 已处理字节数(可选)
+	Total      int64       `json:"total"`      // 总字节数(可选)
+	Message    string      `json:"message"`    // 用户友好的提示信息，按 Config.Locale 翻译
 	Detail     string      `json:"detail"`     // 详细信息(可选)
+	MessageID  MessageID   `json:"message_id"` // Message 的消息 ID，如 phase.download
+	DetailID   MessageID   `json:"detail_id"`  // Detail 的消息 ID(可选)，自定义文本时为空
 }
 
-// 计算阶段内的进度百分比
+// 按 PhaseRanges 计算阶段内的进度百分比，不记录状态，也不保证进度不后退
 func CalculateProgress(phase UpdatePhase, current, total int64) int {
 	if total == 0 {
 		return PhaseRanges[phase].Start
@@ -55,27 +60,32 @@ func CalculateProgress(phase UpdatePhase, current, total int64) int {
 	return r.Start + int(progress*float64(r.End-r.Start))
 }
 
-// ParseProgressMessage 解析进度消息
+// ParseProgressMessage 解析旧版进度消息（不含 @PROGRESS@ 前缀），detail 中可以包含 |
 func ParseProgressMessage(data string) *UpdateProgress {
-	parts := strings.Split(data, "|")
-	if len(parts) != 3 {
+	m, ok := parseLegacyProgress(data)
+	if !ok {
 		return nil
 	}
+	progress := m.Progress()
+	return &progress
+}
+
+// parseLegacyProgress 解析 phase|pct|detail
+func parseLegacyProgress(data string) (Message, bool) {
+	parts := strings.SplitN(data, "|", 3)
+	if len(parts) != 3 {
+		return Message{}, false
+	}
 
-	phase := UpdatePhase(parts[0])
 	percentage, err := strconv.Atoi(parts[1])
 	if err != nil {
-		return nil
+		return Message{}, false
 	}
-	detail := parts[2]
 
-	// 计算总体进度
-	totalPercentage := CalculateProgress(phase, int64(percentage), 100)
-
-	return &UpdateProgress{
-		Phase:      phase,
-		Percentage: totalPercentage,
-		Message:    PhaseMessages[phase],
-		Detail:     detail,
-	}
+	return Message{
+		Type:       MessageProgress,
+		Phase:      UpdatePhase(parts[0]),
+		Percentage: percentage,
+		Detail:     parts[2],
+	}, true
 }
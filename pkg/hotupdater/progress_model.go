@@ -0,0 +1,201 @@
+package hotupdater
+
+import "sync"
+
+// PhaseSpec 进度模型中的一个阶段
+type PhaseSpec struct {
+	Phase   UpdatePhase `json:"phase"`
+	Weight  int         `json:"weight"`  // 在总体进度中所占权重，按所有阶段权重之和折算成百分比
+	Message string      `json:"message"` // 用户友好的提示信息，为空时按语言使用 phase.<阶段名> 消息
+}
+
+// ProgressModel 定义更新阶段的顺序、权重和提示信息，把阶段内进度换算为总体进度
+// 模型会记录已报告的最高进度，阶段调整或乱序的进度消息都不会让总体进度后退；
+// 每次更新应使用独立的模型，FastUpdater 开始更新时会调用 Reset。可并发使用。
+//
+//	model := hotupdater.DefaultProgressModel()
+//	model.Declare(hotupdater.PhaseSpec{Phase: "migrate", Weight: 5, Message: "正在迁移数据..."}, hotupdater.PhaseVerify)
+//	config.ProgressModel = model
+type ProgressModel struct {
+	mu     sync.Mutex
+	phases []PhaseSpec
+	last   int
+}
+
+// NewProgressModel 按给定顺序创建进度模型
+func NewProgressModel(phases ...PhaseSpec) *ProgressModel {
+	return &ProgressModel{phases: append([]PhaseSpec(nil), phases...)}
+}
+
+// DefaultProgressModel 返回内置阶段的模型，权重与 PhaseRanges 的默认值一致，提示信息按语言查找
+func DefaultProgressModel() *ProgressModel {
+	return NewProgressModel(
+		PhaseSpec{PhaseDownload, 70, ""},
+		PhaseSpec{PhasePreCheck, 5, ""},
+		PhaseSpec{PhaseBackup, 10, ""},
+		PhaseSpec{PhaseInstall, 10, ""},
+		PhaseSpec{PhaseVerify, 3, ""},
+		PhaseSpec{PhaseComplete, 2, ""},
+	)
+}
+
+// Declare 声明阶段：已存在时更新权重和提示信息，否则插入到 before 之前；
+// before 为空或不存在时插入到 complete 之前，没有 complete 阶段时追加到末尾
+func (pm *ProgressModel) Declare(spec PhaseSpec, before UpdatePhase) {
+	if spec.Weight < 0 {
+		spec.Weight = 0
+	}
+
+	pm.mu.Lock()
+	defer pm.mu.Unlock()
+
+	if i := pm.index(spec.Phase); i >= 0 {
+		pm.phases[i].Weight = spec.Weight
+		if spec.Message != "" {
+			pm.phases[i].Message = spec.Message
+		}
+		return
+	}
+
+	pos := pm.index(before)
+	if pos < 0 {
+		pos = pm.index(PhaseComplete)
+	}
+	if pos < 0 {
+		pos = len(pm.phases)
+	}
+	pm.phases = append(pm.phases, PhaseSpec{})
+	copy(pm.phases[pos+1:], pm.phases[pos:])
+	pm.phases[pos] = spec
+}
+
+// Phases 返回当前的阶段列表
+func (pm *ProgressModel) Phases() []PhaseSpec {
+	pm.mu.Lock()
+	defer pm.mu.Unlock()
+	return append([]PhaseSpec(nil), pm.phases...)
+}
+
+// Range 返回阶段在总体进度中的范围，未声明的阶段返回 ok 为 false
+func (pm *ProgressModel) Range(phase UpdatePhase) (start, end int, ok bool) {
+	pm.mu.Lock()
+	defer pm.mu.Unlock()
+	return pm.rangeOf(phase)
+}
+
+// Message 返回阶段的提示信息，模型中没有时按 l 的语言查找 phase.<阶段名> 消息
+func (pm *ProgressModel) Message(l *Localizer, phase UpdatePhase) string {
+	if pm != nil {
+		pm.mu.Lock()
+		i := pm.index(phase)
+		message := ""
+		if i >= 0 {
+			message = pm.phases[i].Message
+		}
+		pm.mu.Unlock()
+		if message != "" {
+			return message
+		}
+	}
+	if l.Has(PhaseMessageID(phase)) {
+		return l.T(PhaseMessageID(phase))
+	}
+	return ""
+}
+
+// CalculateProgress 把阶段内进度换算为总体进度，结果不小于之前报告过的进度
+// 未声明的阶段停留在当前进度。模型为 nil 时使用包级 CalculateProgress。
+func (pm *ProgressModel) CalculateProgress(phase UpdatePhase, current, total int64) int {
+	if pm == nil {
+		return CalculateProgress(phase, current, total)
+	}
+
+	pm.mu.Lock()
+	defer pm.mu.Unlock()
+
+	start, end, ok := pm.rangeOf(phase)
+	if !ok {
+		return pm.last
+	}
+
+	percentage := start
+	if total > 0 {
+		ratio := float64(current) / float64(total)
+		if ratio > 1 {
+			ratio = 1
+		} else if ratio < 0 {
+			ratio = 0
+		}
+		percentage = start + int(ratio*float64(end-start))
+	}
+
+	if percentage < pm.last {
+		percentage = pm.last
+	}
+	pm.last = percentage
+	return percentage
+}
+
+// Progress 构造阶段进度事件，percentage 为阶段内进度(0-100)，提示信息按 l 的语言查找
+func (pm *ProgressModel) Progress(l *Localizer, phase UpdatePhase, percentage int, detail string) UpdateProgress {
+	return UpdateProgress{
+		Phase:      phase,
+		Percentage: pm.CalculateProgress(phase, int64(percentage), 100),
+		MessageID:  PhaseMessageID(phase),
+		Message:    pm.Message(l, phase),
+		Detail:     detail,
+	}
+}
+
+// Reset 清除已报告的进度，开始新的更新时调用
+func (pm *ProgressModel) Reset() {
+	if pm == nil {
+		return
+	}
+	pm.mu.Lock()
+	pm.last = 0
+	pm.mu.Unlock()
+}
+
+// Clone 复制阶段定义，不复制已报告的进度
+func (pm *ProgressModel) Clone() *ProgressModel {
+	if pm == nil {
+		return nil
+	}
+	return NewProgressModel(pm.Phases()...)
+}
+
+func (pm *ProgressModel) index(phase UpdatePhase) int {
+	if phase == "" {
+		return -1
+	}
+	for i, p := range pm.phases {
+		if p.Phase == phase {
+			return i
+		}
+	}
+	return -1
+}
+
+// rangeOf 按累计权重计算范围，最后一个阶段的终点固定为 100
+func (pm *ProgressModel) rangeOf(phase UpdatePhase) (int, int, bool) {
+	i := pm.index(phase)
+	if i < 0 {
+		return 0, 0, false
+	}
+
+	sum, before := 0, 0
+	for j, p := range pm.phases {
+		if j < i {
+			before += p.Weight
+		}
+		sum += p.Weight
+	}
+	if sum == 0 {
+		return 0, 0, true
+	}
+
+	start := before * 100 / sum
+	end := (before + pm.phases[i].Weight) * 100 / sum
+	return start, end, true
+}
@@ -0,0 +1,119 @@
+package hotupdater
+
+import "testing"
+
+func TestProgressModelDeclare(t *testing.T) {
+	model := DefaultProgressModel()
+	model.Declare(PhaseSpec{Phase: "migrate", Weight: 5, Message: "正在迁移数据..."}, PhaseVerify)
+
+	var order []UpdatePhase
+	for _, p := range model.Phases() {
+		order = append(order, p.Phase)
+	}
+	want := []UpdatePhase{PhaseDownload, PhasePreCheck, PhaseBackup, PhaseInstall, "migrate", PhaseVerify, PhaseComplete}
+	if len(order) != len(want) {
+		t.Fatalf("阶段顺序为 %v", order)
+	}
+	for i := range want {
+		if order[i] != want[i] {
+			t.Fatalf("阶段顺序为 %v，期望 %v", order, want)
+		}
+	}
+
+	_, installEnd, _ := model.Range(PhaseInstall)
+	start, end, ok := model.Range("migrate")
+	verifyStart, _, _ := model.Range(PhaseVerify)
+	if !ok || start >= end || start != installEnd || end != verifyStart {
+		t.Fatalf("migrate 范围为 [%d,%d)，install 结束于 %d，verify 开始于 %d", start, end, installEnd, verifyStart)
+	}
+	if _, end, _ := model.Range(PhaseComplete); end != 100 {
+		t.Fatalf("最后一个阶段结束于 %d", end)
+	}
+	if got := model.Message(NewLocalizer("en"), "migrate"); got != "正在迁移数据..." {
+		t.Fatalf("提示信息为 %q", got)
+	}
+
+	// before 不存在时插入到 complete 之前
+	model.Declare(PhaseSpec{Phase: "warmup", Weight: 2}, "missing")
+	phases := model.Phases()
+	if phases[len(phases)-2].Phase != "warmup" || phases[len(phases)-1].Phase != PhaseComplete {
+		t.Fatalf("warmup 位置错误: %v", phases)
+	}
+
+	// 再次声明只更新权重，不改变位置和已有提示信息
+	model.Declare(PhaseSpec{Phase: "migrate", Weight: 20}, PhaseDownload)
+	phases = model.Phases()
+	if phases[4].Phase != "migrate" || phases[4].Weight != 20 || phases[4].Message != "正在迁移数据..." {
+		t.Fatalf("重复声明后为 %+v", phases[4])
+	}
+}
+
+func TestProgressModelMonotonic(t *testing.T) {
+	model := DefaultProgressModel()
+
+	steps := []struct {
+		phase          UpdatePhase
+		current, total int64
+	}{
+		{PhaseDownload, 50, 100},
+		{PhaseBackup, 0, 100},
+		{PhaseDownload, 100, 100}, // 乱序到达
+		{PhasePreCheck, 100, 100},
+		{"unknown", 10, 100}, // 未声明的阶段
+		{PhaseInstall, 50, 100},
+		{PhaseBackup, 200, 100},
+		{PhaseVerify, -1, 100},
+		{PhaseComplete, 100, 100},
+	}
+	last := 0
+	for _, s := range steps {
+		got := model.CalculateProgress(s.phase, s.current, s.total)
+		if got < last || got > 100 {
+			t.Fatalf("%s %d/%d 的进度为 %d，之前为 %d", s.phase, s.current, s.total, got, last)
+		}
+		last = got
+	}
+	if last != 100 {
+		t.Fatalf("最终进度为 %d", last)
+	}
+
+	// 权重调整让阶段范围前移时进度也不后退
+	model = DefaultProgressModel()
+	before := model.CalculateProgress(PhaseInstall, 100, 100)
+	model.Declare(PhaseSpec{Phase: PhaseDownload, Weight: 10}, "")
+	if _, end, _ := model.Range(PhaseInstall); end >= before {
+		t.Fatalf("调整权重后 install 结束于 %d，应小于 %d", end, before)
+	}
+	if got := model.CalculateProgress(PhaseInstall, 100, 100); got != before {
+		t.Fatalf("调整权重后进度为 %d，之前为 %d", got, before)
+	}
+	if got := model.CalculateProgress(PhaseVerify, 0, 100); got < before {
+		t.Fatalf("下一阶段进度为 %d，之前为 %d", got, before)
+	}
+}
+
+func TestProgressModelReset(t *testing.T) {
+	model := DefaultProgressModel()
+	if got := model.CalculateProgress(PhaseComplete, 100, 100); got != 100 {
+		t.Fatalf("进度为 %d", got)
+	}
+	if got := model.CalculateProgress(PhaseDownload, 0, 100); got != 100 {
+		t.Fatalf("重置前进度为 %d", got)
+	}
+
+	clone := model.Clone()
+	if got := clone.CalculateProgress(PhaseDownload, 0, 100); got != 0 {
+		t.Fatalf("Clone 后进度为 %d，不应复制已报告的进度", got)
+	}
+
+	model.Reset()
+	if got := model.CalculateProgress(PhaseDownload, 0, 100); got != 0 {
+		t.Fatalf("重置后进度为 %d", got)
+	}
+
+	var nilModel *ProgressModel
+	nilModel.Reset()
+	if got := nilModel.CalculateProgress(PhaseDownload, 100, 100); got != CalculateProgress(PhaseDownload, 100, 100) {
+		t.Fatalf("nil 模型进度为 %d", got)
+	}
+}
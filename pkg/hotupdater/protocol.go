@@ -0,0 +1,266 @@
+package hotupdater
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ProtocolVersion 更新消息协议版本
+const ProtocolVersion = 1
+
+// legacyProgressPrefix 旧版进度消息前缀：@PROGRESS@phase|pct|detail
+const legacyProgressPrefix = "@PROGRESS@"
+
+// MaxMessageSize 单条消息的最大长度，超过 bufio.Scanner 默认的 64KB 时（如包含脚本堆栈的错误）仍能读取
+const MaxMessageSize = 1024 * 1024
+
+// MessageType 消息类型
+type MessageType string
+
+const (
+	MessageProgress MessageType = "progress" // 进度
+	MessageLog      MessageType = "log"      // 日志
+	MessageError    MessageType = "error"    // 错误
+	MessagePhase    MessageType = "phase"    // 声明自定义进度阶段
+)
+
+// LogLevel 日志级别
+type LogLevel string
+
+const (
+	LevelDebug LogLevel = "debug"
+	LevelInfo  LogLevel = "info"
+	LevelWarn  LogLevel = "warn"
+	LevelError LogLevel = "error"
+)
+
+// 更新助手上报的错误码，见 ErrorCode
+const (
+	ErrorCodeUpdateFailed   = "update_failed"   // 更新失败
+	ErrorCodeCancelled      = "cancelled"       // 更新已取消，脚本未声明回滚
+	ErrorCodeRolledBack     = "rolled_back"     // 更新已取消或失败，已回滚
+	ErrorCodeRollbackFailed = "rollback_failed" // 回滚失败
+)
+
+// Message 更新脚本、更新助手与应用之间传递的消息，每条消息编码为一行 JSON：
+//
+//	{"v":1,"seq":7,"ts":1736337600000,"type":"progress","phase":"install","percentage":40,"detail":"正在复制新版本...","detail_id":"install.copy"}
+//	{"v":1,"seq":8,"ts":1736337600100,"type":"log","level":"warn","message":"清理旧版本失败"}
+//	{"v":1,"seq":9,"ts":1736337600200,"type":"error","code":"install_failed","message":"复制新版本失败"}
+//	{"v":1,"seq":1,"ts":1736337590000,"type":"phase","phase":"migrate","weight":5,"before":"verify","message":"正在迁移数据..."}
+//
+// Lua 脚本通过 log_message 发送，macOS 更新助手通过标准输出或命名管道发送。
+// 不是 JSON 的行按旧格式解析：@PROGRESS@ 开头的为进度，其他为 info 日志，此时 Version 为 0。
+type Message struct {
+	Version    int         `json:"v"`
+	Seq        uint64      `json:"seq,omitempty"`        // 发送方递增的序号，用于发现丢失和重复的消息
+	Timestamp  int64       `json:"ts,omitempty"`         // 发送时间（Unix 毫秒）
+	Type       MessageType `json:"type"`                 // 消息类型
+	Level      LogLevel    `json:"level,omitempty"`      // 日志级别，仅 log 类型
+	Phase      UpdatePhase `json:"phase,omitempty"`      // 更新阶段
+	Percentage int         `json:"percentage,omitempty"` // 阶段内进度(0-100)
+	Detail     string      `json:"detail,omitempty"`     // 进度详情
+	Speed      float64     `json:"speed,omitempty"`      // 速度(MB/s)
+	Current    int64       `json:"current,omitempty"`    // 已处理字节数
+	Total      int64       `json:"total,omitempty"`      // 总字节数
+	Code       string      `json:"code,omitempty"`       // 错误码，仅 error 类型
+	Message    string      `json:"message,omitempty"`    // 日志或错误内容，phase 类型为阶段提示信息
+	DetailID   MessageID   `json:"detail_id,omitempty"`  // 进度详情的消息 ID
+	Weight     int         `json:"weight,omitempty"`     // 阶段权重，仅 phase 类型
+	Before     UpdatePhase `json:"before,omitempty"`     // 插入到该阶段之前，仅 phase 类型
+}
+
+// Encode 编码为一行 JSON（不含换行）
+func (m Message) Encode() string {
+	if m.Version == 0 {
+		m.Version = ProtocolVersion
+	}
+	data, _ := json.Marshal(m)
+	return string(data)
+}
+
+// Progress 按默认阶段范围转换为总体进度事件，更新器内部按 Config.ProgressModel 转换
+func (m Message) Progress() UpdateProgress {
+	return UpdateProgress{
+		Phase:      m.Phase,
+		Percentage: CalculateProgress(m.Phase, int64(m.Percentage), 100),
+		Speed:      m.Speed,
+		Current:    m.Current,
+		Total:      m.Total,
+		Message:    PhaseMessages[m.Phase],
+		Detail:     m.Detail,
+	}
+}
+
+// ParseMessage 解析一行消息，兼容旧版 @PROGRESS@ 格式和纯文本日志
+func ParseMessage(line string) Message {
+	line = strings.TrimRight(line, "\r\n")
+
+	if strings.HasPrefix(line, "{") {
+		var m Message
+		if err := json.Unmarshal([]byte(line), &m); err == nil && m.Version > 0 && m.Type != "" {
+			if m.Type == MessageLog && m.Level == "" {
+				m.Level = LevelInfo
+			}
+			return m
+		}
+	}
+
+	if strings.HasPrefix(line, legacyProgressPrefix) {
+		if p, ok := parseLegacyProgress(strings.TrimPrefix(line, legacyProgressPrefix)); ok {
+			return p
+		}
+	}
+
+	return Message{Type: MessageLog, Level: LevelInfo, Message: line}
+}
+
+// NewMessageScanner 返回按行读取消息的 Scanner，单行最长 MaxMessageSize
+func NewMessageScanner(r io.Reader) *bufio.Scanner {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), MaxMessageSize)
+	return scanner
+}
+
+// MessageWriter 按协议写出消息并自动填写版本、序号和时间，可并发使用
+type MessageWriter struct {
+	mu  sync.Mutex
+	w   io.Writer
+	seq uint64
+}
+
+// NewMessageWriter 创建消息写入器
+func NewMessageWriter(w io.Writer) *MessageWriter {
+	return &MessageWriter{w: w}
+}
+
+// Write 写出一条消息
+func (mw *MessageWriter) Write(m Message) error {
+	mw.mu.Lock()
+	defer mw.mu.Unlock()
+
+	mw.seq++
+	m.Version = ProtocolVersion
+	m.Seq = mw.seq
+	if m.Timestamp == 0 {
+		m.Timestamp = time.Now().UnixNano() / int64(time.Millisecond)
+	}
+	_, err := io.WriteString(mw.w, m.Encode()+"\n")
+	return err
+}
+
+// Progress 写出进度消息，percentage 为阶段内进度
+func (mw *MessageWriter) Progress(phase UpdatePhase, percentage int, detail string) error {
+	return mw.Write(Message{Type: MessageProgress, Phase: phase, Percentage: percentage, Detail: detail})
+}
+
+// Log 写出日志消息
+func (mw *MessageWriter) Log(level LogLevel, message string) error {
+	return mw.Write(Message{Type: MessageLog, Level: level, Message: message})
+}
+
+// DeclarePhase 写出自定义阶段声明，见 ProgressModel.Declare
+func (mw *MessageWriter) DeclarePhase(phase UpdatePhase, weight int, message string, before UpdatePhase) error {
+	return mw.Write(Message{Type: MessagePhase, Phase: phase, Weight: weight, Message: message, Before: before})
+}
+
+// Error 写出错误消息
+func (mw *MessageWriter) Error(code, message string) error {
+	return mw.Write(Message{Type: MessageError, Level: LevelError, Code: code, Message: message})
+}
+
+// Forward 解析一行消息（JSON、旧格式或纯文本）后重新编号写出，用于转发其他进程的输出
+func (mw *MessageWriter) Forward(line string) error {
+	m := ParseMessage(line)
+	m.Seq = 0
+	return mw.Write(m)
+}
+
+// LogWriter 返回按行写出 info 日志消息的 io.Writer，可用于 log.SetOutput
+func (mw *MessageWriter) LogWriter() io.Writer {
+	return logLineWriter{mw}
+}
+
+type logLineWriter struct {
+	mw *MessageWriter
+}
+
+func (l logLineWriter) Write(p []byte) (int, error) {
+	for _, line := range strings.Split(strings.TrimRight(string(p), "\n"), "\n") {
+		if err := l.mw.Log(LevelInfo, line); err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
+
+// messageDispatcher 把收到的消息转发给 Logger 和 EventEmitter，并检查序号
+// 进度按 model 换算，阶段声明写入 model；model 为 nil 时使用默认阶段范围。
+type messageDispatcher struct {
+	logger  Logger
+	emitter EventEmitter
+	model   *ProgressModel
+	l       *Localizer
+	lastSeq uint64
+	phase   UpdatePhase // 最近一条进度消息的阶段，用于标记失败阶段
+}
+
+// dispatch 处理一条消息，重复的消息会被丢弃
+func (d *messageDispatcher) dispatch(m Message) {
+	if m.Seq > 0 {
+		if m.Seq <= d.lastSeq {
+			return
+		}
+		if d.lastSeq > 0 && m.Seq > d.lastSeq+1 {
+			d.log("[warn] 丢失 %d 条更新消息", m.Seq-d.lastSeq-1)
+		}
+		d.lastSeq = m.Seq
+	}
+
+	switch m.Type {
+	case MessageProgress:
+		d.phase = m.Phase
+		if d.emitter != nil {
+			progress := d.model.Progress(d.l, m.Phase, m.Percentage, m.Detail)
+			progress.DetailID = m.DetailID
+			progress.Speed = m.Speed
+			progress.Current = m.Current
+			progress.Total = m.Total
+			d.emitter.EmitProgress(progress)
+		}
+	case MessagePhase:
+		if d.model != nil && m.Phase != "" {
+			d.model.Declare(PhaseSpec{Phase: m.Phase, Weight: m.Weight, Message: m.Message}, m.Before)
+		}
+	case MessageError:
+		if m.Code != "" {
+			d.log("[error] %s: %s", m.Code, m.Message)
+		} else {
+			d.log("[error] %s", m.Message)
+		}
+	default:
+		if m.Level == "" || m.Level == LevelInfo {
+			d.log("%s", m.Message)
+		} else {
+			d.log("[%s] %s", m.Level, m.Message)
+		}
+	}
+}
+
+// failedPhase 返回失败时所处的阶段，还没有收到进度时为 precheck
+func (d *messageDispatcher) failedPhase() UpdatePhase {
+	if d.phase == "" {
+		return PhasePreCheck
+	}
+	return d.phase
+}
+
+func (d *messageDispatcher) log(format string, args ...interface{}) {
+	if d.logger != nil {
+		d.logger.Logf(format, args...)
+	}
+}
@@ -0,0 +1,101 @@
+package hotupdater
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestMessageRoundTrip(t *testing.T) {
+	messages := []Message{
+		{Type: MessageProgress, Phase: PhaseInstall, Percentage: 40, Detail: "正在复制新版本...", DetailID: MsgInstallCopy, Speed: 1.5, Current: 10, Total: 20},
+		{Type: MessageLog, Level: LevelWarn, Message: "清理旧版本失败"},
+		{Type: MessageError, Level: LevelError, Code: ErrorCodeRolledBack, Message: "复制新版本失败"},
+		{Type: MessagePhase, Phase: "migrate", Weight: 5, Before: PhaseVerify, Message: "正在迁移数据..."},
+	}
+	var buf bytes.Buffer
+	w := NewMessageWriter(&buf)
+	for _, m := range messages {
+		if err := w.Write(m); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	scanner := NewMessageScanner(&buf)
+	for i, want := range messages {
+		if !scanner.Scan() {
+			t.Fatalf("第 %d 条消息缺失: %v", i+1, scanner.Err())
+		}
+		got := ParseMessage(scanner.Text())
+		if got.Version != ProtocolVersion || got.Seq != uint64(i+1) || got.Timestamp == 0 {
+			t.Fatalf("版本、序号或时间未填写: %+v", got)
+		}
+		got.Version, got.Seq, got.Timestamp = 0, 0, 0
+		if !reflect.DeepEqual(got, want) {
+			t.Fatalf("消息不一致:\n%+v\n%+v", got, want)
+		}
+	}
+}
+
+func TestParseMessageLegacy(t *testing.T) {
+	cases := []struct {
+		line string
+		want Message
+	}{
+		{"@PROGRESS@install|40|正在复制", Message{Type: MessageProgress, Phase: PhaseInstall, Percentage: 40, Detail: "正在复制"}},
+		{"@PROGRESS@backup|100|a|b|c\r\n", Message{Type: MessageProgress, Phase: PhaseBackup, Percentage: 100, Detail: "a|b|c"}},
+		{"@PROGRESS@verify|0|", Message{Type: MessageProgress, Phase: PhaseVerify}},
+		// 格式不对时作为普通日志
+		{"@PROGRESS@install|x|detail", Message{Type: MessageLog, Level: LevelInfo, Message: "@PROGRESS@install|x|detail"}},
+		{"@PROGRESS@install", Message{Type: MessageLog, Level: LevelInfo, Message: "@PROGRESS@install"}},
+		{"普通日志", Message{Type: MessageLog, Level: LevelInfo, Message: "普通日志"}},
+		{"", Message{Type: MessageLog, Level: LevelInfo}},
+	}
+	for _, c := range cases {
+		if got := ParseMessage(c.line); !reflect.DeepEqual(got, c.want) {
+			t.Errorf("ParseMessage(%q) = %+v，期望 %+v", c.line, got, c.want)
+		}
+	}
+}
+
+func TestParseMessageMalformedJSON(t *testing.T) {
+	for _, line := range []string{
+		`{"v":1,"type":"progress"`,      // 不完整
+		`{"type":"progress","phase":1}`, // 字段类型错误
+		`{"type":"log","message":"x"}`,  // 缺少版本
+		`{"v":1,"message":"x"}`,         // 缺少类型
+		`{not json}`,
+	} {
+		got := ParseMessage(line)
+		want := Message{Type: MessageLog, Level: LevelInfo, Message: line}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("ParseMessage(%q) = %+v，应作为日志", line, got)
+		}
+	}
+
+	// 省略级别的日志默认为 info
+	if got := ParseMessage(`{"v":1,"type":"log","message":"x"}`); got.Level != LevelInfo {
+		t.Errorf("日志级别为 %q，期望 info", got.Level)
+	}
+}
+
+func TestMessageScannerLongLine(t *testing.T) {
+	// 超过 bufio.Scanner 默认 64KB 的错误消息（如带脚本堆栈）
+	long := strings.Repeat("堆栈", 50*1024)
+	var buf bytes.Buffer
+	w := NewMessageWriter(&buf)
+	w.Error(ErrorCodeUpdateFailed, long)
+	w.Log(LevelInfo, "之后的消息")
+
+	scanner := NewMessageScanner(&buf)
+	if !scanner.Scan() {
+		t.Fatalf("读取长消息失败: %v", scanner.Err())
+	}
+	if m := ParseMessage(scanner.Text()); m.Type != MessageError || m.Message != long {
+		t.Fatalf("长消息解析错误: type=%s len=%d", m.Type, len(m.Message))
+	}
+	if !scanner.Scan() || ParseMessage(scanner.Text()).Message != "之后的消息" {
+		t.Fatal("长消息之后的消息丢失")
+	}
+}
@@ -0,0 +1,157 @@
+package hotupdater
+
+import (
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/562589540/hotupdater/pkg/version"
+)
+
+// BackupRetention 备份保留策略，为零值的限制不生效，零值策略不删除任何备份
+//
+//	config.BackupRetention = hotupdater.BackupRetention{
+//		KeepLast:      5,
+//		MaxAge:        30 * 24 * time.Hour,
+//		MaxTotalBytes: 2 << 30,
+//		KeepLastGood:  true,
+//	}
+//
+// 备份从新到旧依次判断，超出任一限制的备份被删除。等待健康确认的更新所用的备份始终保留。
+type BackupRetention struct {
+	KeepLast      int           // 最多保留的备份数
+	MaxAge        time.Duration // 备份时间早于此时长的备份被删除
+	MaxTotalBytes int64         // 保留备份的总大小上限（字节）
+	KeepLastGood  bool          // 始终保留最新的已知可用版本（不在 FailedVersions 中）的备份，即使超出上述限制
+}
+
+// IsZero 策略是否不限制任何备份
+func (r BackupRetention) IsZero() bool {
+	return r.KeepLast <= 0 && r.MaxAge <= 0 && r.MaxTotalBytes <= 0
+}
+
+// Select 从 backups（ListBackups 的结果，从新到旧）中选出按策略应删除的备份
+// failed 为未通过健康确认的版本，protect 中的备份路径始终保留。
+func (r BackupRetention) Select(backups []Backup, failed []FailedVersion, protect ...string) []Backup {
+	if r.IsZero() {
+		return nil
+	}
+
+	keep := make(map[string]bool)
+	for _, path := range protect {
+		if path != "" {
+			keep[filepath.Clean(path)] = true
+		}
+	}
+	if r.KeepLastGood {
+		for _, b := range backups {
+			if !isFailedVersionString(failed, b.Version) {
+				keep[filepath.Clean(b.Path)] = true
+				break
+			}
+		}
+	}
+
+	now := time.Now()
+	var (
+		remove []Backup
+		kept   int
+		total  int64
+	)
+	for _, b := range backups {
+		if !keep[filepath.Clean(b.Path)] {
+			expired := r.MaxAge > 0 && now.Sub(b.Time) > r.MaxAge
+			tooMany := r.KeepLast > 0 && kept >= r.KeepLast
+			tooLarge := r.MaxTotalBytes > 0 && total+b.Size > r.MaxTotalBytes
+			if expired || tooMany || tooLarge {
+				remove = append(remove, b)
+				continue
+			}
+		}
+		kept++
+		total += b.Size
+	}
+	return remove
+}
+
+// PruneBackups 按策略删除 dir 中的备份及其说明文件，返回已删除的备份
+// updatePath 非空时读取其中的失败版本和等待确认的更新，等待确认的更新所用的备份不会被删除。
+// 删除失败的备份会跳过，返回遇到的第一个错误。
+func PruneBackups(dir, updatePath string, r BackupRetention, protect ...string) ([]Backup, error) {
+	plan, err := PlanPrune(dir, updatePath, r, protect...)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		removed  []Backup
+		firstErr error
+	)
+	for _, b := range plan {
+		if err := os.Remove(b.Path); err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		os.Remove(BackupMetaPath(b.Path))
+		removed = append(removed, b)
+	}
+	return removed, firstErr
+}
+
+// PlanPrune 返回 PruneBackups 将要删除的备份，不做任何修改，用于预览
+func PlanPrune(dir, updatePath string, r BackupRetention, protect ...string) ([]Backup, error) {
+	if r.IsZero() {
+		return nil, nil
+	}
+	backups, err := ListBackups(dir)
+	if err != nil {
+		return nil, err
+	}
+	failed, _ := FailedVersions(updatePath)
+	if p, _ := ReadPendingHealth(updatePath); p != nil {
+		protect = append(protect, p.BackupFile)
+	}
+	return r.Select(backups, failed, protect...), nil
+}
+
+// isFailedVersionString 判断版本号字符串是否在失败版本列表中，无法解析时按原文比较
+func isFailedVersionString(failed []FailedVersion, v string) bool {
+	if v == "" {
+		return false
+	}
+	if parsed, err := version.Parse(v); err == nil {
+		return isFailedVersion(failed, parsed)
+	}
+	for _, f := range failed {
+		if f.Version == v {
+			return true
+		}
+	}
+	return false
+}
+
+// pruneBackups 更新成功后按 Config.BackupRetention 清理备份，backup 为本次更新创建的备份
+// 配置了 BackupPath 时清理该目录，否则清理本次备份所在的目录。清理失败只记录日志。
+func (f *FastUpdater) pruneBackups(backup string) {
+	r := f.config.BackupRetention
+	if r.IsZero() {
+		return
+	}
+	dir := f.config.BackupPath
+	if dir == "" && backup != "" {
+		dir = filepath.Dir(backup)
+	}
+	if dir == "" {
+		return
+	}
+
+	removed, err := PruneBackups(dir, f.config.UpdatePath, r, backup)
+	for _, b := range removed {
+		f.config.Logger.Logf("已删除旧备份: %s", b.Path)
+	}
+	if err != nil {
+		f.config.Logger.Logf("清理旧备份失败: %v", err)
+	}
+}
@@ -0,0 +1,113 @@
+package hotupdater
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBackupRetentionSelect(t *testing.T) {
+	now := time.Now()
+	// 从新到旧，与 ListBackups 的顺序一致
+	backups := []Backup{
+		{Path: "b5", Version: "1.5.0", Time: now.Add(-1 * time.Hour), Size: 100},
+		{Path: "b4", Version: "1.4.0", Time: now.Add(-2 * 24 * time.Hour), Size: 100},
+		{Path: "b3", Version: "1.3.0", Time: now.Add(-10 * 24 * time.Hour), Size: 300},
+		{Path: "b2", Version: "1.2.0", Time: now.Add(-40 * 24 * time.Hour), Size: 100},
+		{Path: "b1", Version: "", Time: now.Add(-50 * 24 * time.Hour), Size: 100},
+	}
+	failed := []FailedVersion{{Version: "1.5.0"}, {Version: "v1.4"}}
+
+	cases := []struct {
+		name    string
+		r       BackupRetention
+		failed  []FailedVersion
+		protect []string
+		want    string
+	}{
+		{"零值策略", BackupRetention{}, nil, nil, ""},
+		{"零值策略忽略 KeepLastGood", BackupRetention{KeepLastGood: true}, nil, nil, ""},
+		{"KeepLast", BackupRetention{KeepLast: 2}, nil, nil, "b3,b2,b1"},
+		{"MaxAge", BackupRetention{MaxAge: 30 * 24 * time.Hour}, nil, nil, "b2,b1"},
+		{"MaxTotalBytes", BackupRetention{MaxTotalBytes: 300}, nil, nil, "b3,b1"},
+		{"组合限制", BackupRetention{KeepLast: 4, MaxAge: 45 * 24 * time.Hour, MaxTotalBytes: 500}, nil, nil, "b2,b1"},
+		{"KeepLastGood 保留最新的可用版本", BackupRetention{KeepLast: 1, KeepLastGood: true}, failed, nil, "b4,b2,b1"},
+		{"KeepLastGood 没有失败版本", BackupRetention{KeepLast: 1, KeepLastGood: true}, nil, nil, "b4,b3,b2,b1"},
+		{"保护的备份始终保留", BackupRetention{KeepLast: 1}, nil, []string{"b2", ""}, "b4,b3,b1"},
+		{"保护的备份超出大小限制", BackupRetention{MaxTotalBytes: 150}, nil, []string{"b3"}, "b4,b2,b1"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			var got []string
+			for _, b := range c.r.Select(backups, c.failed, c.protect...) {
+				got = append(got, b.Path)
+			}
+			if strings.Join(got, ",") != c.want {
+				t.Fatalf("删除 %v，期望 %s", got, c.want)
+			}
+		})
+	}
+}
+
+func TestPlanPrune(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "backups")
+	updatePath := t.TempDir()
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+	var paths []string // 从新到旧
+	for i, v := range []string{"1.4.0", "1.3.0", "1.2.0", "1.1.0"} {
+		path := filepath.Join(dir, newBackupName(v, true, now.Add(-time.Duration(i+1)*time.Hour)))
+		if err := os.WriteFile(path, []byte("backup"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		paths = append(paths, path)
+	}
+	// 非备份文件不受影响
+	if err := os.WriteFile(filepath.Join(dir, "notes.txt"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := recordFailedVersion(updatePath, "1.4.0"); err != nil {
+		t.Fatal(err)
+	}
+	// 等待健康确认的更新使用最旧的备份
+	writePending(t, updatePath, PendingHealth{Version: "1.5.0", BackupFile: paths[3]})
+
+	r := BackupRetention{KeepLast: 1, KeepLastGood: true}
+	// paths[0] 为本次更新刚创建的备份
+	plan, err := PlanPrune(dir, updatePath, r, paths[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, b := range plan {
+		got = append(got, b.Path)
+	}
+	// paths[0] 受保护，paths[1] 是最新的可用版本，paths[3] 等待健康确认
+	if want := []string{paths[2]}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("计划删除 %v，期望 %v", got, want)
+	}
+	for _, path := range paths {
+		if _, err := os.Stat(path); err != nil {
+			t.Fatalf("PlanPrune 不应删除文件: %v", err)
+		}
+	}
+
+	removed, err := PruneBackups(dir, updatePath, r, paths[0])
+	if err != nil || len(removed) != 1 || removed[0].Path != paths[2] {
+		t.Fatalf("PruneBackups = %v, %v", removed, err)
+	}
+	if _, err := os.Stat(paths[2]); !os.IsNotExist(err) {
+		t.Fatalf("备份未删除: %v", err)
+	}
+
+	// 目录不存在时不报错
+	if plan, err := PlanPrune(filepath.Join(dir, "missing"), updatePath, r); err != nil || plan != nil {
+		t.Fatalf("PlanPrune = %v, %v", plan, err)
+	}
+}
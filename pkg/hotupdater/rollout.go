@@ -0,0 +1,62 @@
+package hotupdater
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ChannelStable 默认的正式发布通道
+const ChannelStable = "stable"
+
+// installIDFile 安装标识文件名，保存在 UpdatePath 下
+const installIDFile = "install_id"
+
+// InstallID 返回本机稳定的安装标识，首次调用时生成并保存到 updatePath
+// 灰度发布按该标识分桶，保证同一台机器在重启后仍处于相同的灰度范围。
+func InstallID(updatePath string) (string, error) {
+	if updatePath == "" {
+		return "", errors.New("未配置更新路径")
+	}
+
+	path := filepath.Join(updatePath, installIDFile)
+	if data, err := os.ReadFile(path); err == nil {
+		if id := strings.TrimSpace(string(data)); id != "" {
+			return id, nil
+		}
+	}
+
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	id := hex.EncodeToString(buf)
+
+	if err := os.MkdirAll(updatePath, 0755); err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(path, []byte(id), 0644); err != nil {
+		return "", err
+	}
+	return id, nil
+}
+
+// RolloutBucket 计算安装标识在某个版本灰度中的桶号(0-99)
+// 桶号只取决于安装标识和版本，灰度比例从 5% 提高到 25% 时已命中的机器仍然命中。
+func RolloutBucket(installID, version string) int {
+	sum := sha256.Sum256([]byte(installID + ":" + version))
+	return int(binary.BigEndian.Uint32(sum[:4]) % 100)
+}
+
+// channel 返回配置的更新通道，默认为 stable
+func (c Config) channel() string {
+	if c.Channel == "" {
+		return ChannelStable
+	}
+	return c.Channel
+}
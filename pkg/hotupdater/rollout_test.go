@@ -0,0 +1,128 @@
+package hotupdater
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestInstallID(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "update")
+	id, err := InstallID(dir)
+	if err != nil || len(id) != 32 {
+		t.Fatalf("InstallID = %q, %v", id, err)
+	}
+	if again, err := InstallID(dir); err != nil || again != id {
+		t.Fatalf("再次调用得到 %q, %v，期望 %q", again, err, id)
+	}
+	if _, err := InstallID(""); err == nil {
+		t.Fatal("未配置更新路径时应返回错误")
+	}
+}
+
+func TestRolloutBucket(t *testing.T) {
+	if RolloutBucket("machine", "1.2.0") != RolloutBucket("machine", "1.2.0") {
+		t.Fatal("同一安装标识和版本的桶号应稳定")
+	}
+
+	hit := make(map[int]bool)
+	differs := false
+	for i := 0; i < 10000; i++ {
+		id := fmt.Sprintf("install-%d", i)
+		b := RolloutBucket(id, "1.2.0")
+		if b < 0 || b >= 100 {
+			t.Fatalf("桶号 %d 超出范围", b)
+		}
+		hit[b] = true
+		if b != RolloutBucket(id, "1.3.0") {
+			differs = true
+		}
+	}
+	if len(hit) != 100 {
+		t.Fatalf("只命中了 %d 个桶", len(hit))
+	}
+	if !differs {
+		t.Fatal("不同版本应重新分桶")
+	}
+}
+
+func TestSelectRelease(t *testing.T) {
+	rollout := func(p int) *int { return &p }
+
+	cases := []struct {
+		name        string
+		channel     string
+		stable      string
+		beta        string
+		betaRollout func(bucket int) *int
+		failed      []string
+		want        string // 期望的通道和版本，没有可用发布时为空
+	}{
+		{"只有 stable", "", "1.1.0", "", nil, nil, "stable 1.1.0"},
+		{"未订阅 beta", "", "1.1.0", "1.2.0-beta.1", nil, nil, "stable 1.1.0"},
+		{"beta 版本更高", "beta", "1.1.0", "1.2.0-beta.1", nil, nil, "beta 1.2.0-beta.1"},
+		{"stable 版本更高", "beta", "1.2.0", "1.2.0-beta.1", nil, nil, "stable 1.2.0"},
+		{"相同版本优先 stable", "beta", "1.2.0", "1.2.0", nil, nil, "stable 1.2.0"},
+		{"0% 灰度", "beta", "1.1.0", "1.2.0", func(int) *int { return rollout(0) }, nil, "stable 1.1.0"},
+		{"100% 灰度", "beta", "1.1.0", "1.2.0", func(int) *int { return rollout(100) }, nil, "beta 1.2.0"},
+		{"桶号不在灰度内", "beta", "1.1.0", "1.2.0", func(b int) *int { return rollout(b) }, nil, "stable 1.1.0"},
+		{"桶号在灰度内", "beta", "1.1.0", "1.2.0", func(b int) *int { return rollout(b + 1) }, nil, "beta 1.2.0"},
+		{"跳过失败版本", "beta", "1.1.0", "1.2.0", nil, []string{"v1.2"}, "stable 1.1.0"},
+		{"全部失败", "beta", "1.1.0", "1.2.0", nil, []string{"1.1.0", "1.2.0"}, ""},
+		{"stable 只有通道", "beta", "", "1.2.0", nil, nil, "beta 1.2.0"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			updatePath := t.TempDir()
+			for _, v := range c.failed {
+				if err := recordFailedVersion(updatePath, v); err != nil {
+					t.Fatal(err)
+				}
+			}
+			id, err := InstallID(updatePath)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			manifest := &Manifest{Version: c.stable}
+			if c.beta != "" {
+				beta := &Manifest{Version: c.beta}
+				if c.betaRollout != nil {
+					beta.Rollout = c.betaRollout(RolloutBucket(id, c.beta))
+				}
+				manifest.Channels = map[string]*Manifest{"beta": beta}
+			}
+
+			checker := NewChecker(Config{UpdatePath: updatePath, Channel: c.channel}, "")
+			channel, m, v, err := checker.selectRelease(manifest)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := ""
+			if m != nil {
+				got = channel + " " + m.Version
+				if v.String() != m.Version {
+					t.Fatalf("返回的版本 %s 与清单 %s 不一致", v, m.Version)
+				}
+			}
+			if got != c.want {
+				t.Fatalf("选中 %q，期望 %q", got, c.want)
+			}
+		})
+	}
+}
+
+func TestSelectReleaseRolloutSkipsInstallID(t *testing.T) {
+	// 全量和 0% 灰度不需要安装标识
+	updatePath := t.TempDir()
+	zero := 0
+	manifest := &Manifest{Version: "1.1.0", Channels: map[string]*Manifest{"beta": {Version: "1.2.0", Rollout: &zero}}}
+	checker := NewChecker(Config{UpdatePath: updatePath, Channel: "beta"}, "")
+	if channel, _, _, err := checker.selectRelease(manifest); err != nil || channel != ChannelStable {
+		t.Fatalf("selectRelease = %q, %v", channel, err)
+	}
+	if _, err := os.Stat(filepath.Join(updatePath, installIDFile)); !os.IsNotExist(err) {
+		t.Fatalf("不应生成安装标识: %v", err)
+	}
+}
@@ -0,0 +1,129 @@
+package hotupdater
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/sha512"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// SignatureSuffix 分离签名文件的后缀
+const SignatureSuffix = ".sig"
+
+// ErrSignature 签名校验失败
+var ErrSignature = errors.New("签名校验失败")
+
+// 文件签名使用 Ed25519ph（先对文件做 SHA-512），大文件可以流式计算
+var fileSignOptions = &ed25519.Options{Hash: crypto.SHA512}
+
+// ParsePublicKey 解析 base64 编码的 Ed25519 公钥，便于在代码或配置中内嵌
+func ParsePublicKey(s string) (ed25519.PublicKey, error) {
+	data, err := base64.StdEncoding.DecodeString(strings.TrimSpace(s))
+	if err != nil {
+		return nil, fmt.Errorf("解析公钥失败: %w", err)
+	}
+	if len(data) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("公钥长度无效: %d", len(data))
+	}
+	return ed25519.PublicKey(data), nil
+}
+
+// SignFile 使用私钥为文件生成分离签名，写入 path + ".sig" 并返回签名文件路径
+// 供发布流程调用。
+func SignFile(path string, privateKey ed25519.PrivateKey) (string, error) {
+	digest, err := fileSHA512(path)
+	if err != nil {
+		return "", fmt.Errorf("计算文件摘要失败: %w", err)
+	}
+
+	sig, err := privateKey.Sign(nil, digest, fileSignOptions)
+	if err != nil {
+		return "", fmt.Errorf("生成签名失败: %w", err)
+	}
+
+	sigPath := path + SignatureSuffix
+	if err := os.WriteFile(sigPath, []byte(encodeSignature(sig)+"\n"), 0644); err != nil {
+		return "", fmt.Errorf("写入签名文件失败: %w", err)
+	}
+	return sigPath, nil
+}
+
+// SignBytes 为一段数据（如更新清单）生成 base64 编码的签名
+func SignBytes(data []byte, privateKey ed25519.PrivateKey) string {
+	return encodeSignature(ed25519.Sign(privateKey, data))
+}
+
+// VerifyFileSignature 使用任意一个公钥校验文件签名
+// signature 为 base64 编码的签名，为空时读取 path + ".sig"。
+func VerifyFileSignature(path, signature string, publicKeys []ed25519.PublicKey) error {
+	if signature == "" {
+		data, err := os.ReadFile(path + SignatureSuffix)
+		if err != nil {
+			return fmt.Errorf("%w: 读取签名文件失败: %w", ErrSignature, err)
+		}
+		signature = string(data)
+	}
+
+	sig, err := decodeSignature(signature)
+	if err != nil {
+		return err
+	}
+
+	digest, err := fileSHA512(path)
+	if err != nil {
+		return fmt.Errorf("计算文件摘要失败: %w", err)
+	}
+
+	for _, key := range publicKeys {
+		if ed25519.VerifyWithOptions(key, digest, sig, fileSignOptions) == nil {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: %s", ErrSignature, path)
+}
+
+// VerifySignature 使用任意一个公钥校验数据签名，signature 为 base64 编码
+func VerifySignature(data []byte, signature string, publicKeys []ed25519.PublicKey) error {
+	sig, err := decodeSignature(signature)
+	if err != nil {
+		return err
+	}
+	for _, key := range publicKeys {
+		if ed25519.Verify(key, data, sig) {
+			return nil
+		}
+	}
+	return ErrSignature
+}
+
+func encodeSignature(sig []byte) string {
+	return base64.StdEncoding.EncodeToString(sig)
+}
+
+func decodeSignature(signature string) ([]byte, error) {
+	sig, err := base64.StdEncoding.DecodeString(strings.TrimSpace(signature))
+	if err != nil || len(sig) != ed25519.SignatureSize {
+		return nil, fmt.Errorf("%w: 签名格式无效", ErrSignature)
+	}
+	return sig, nil
+}
+
+// fileSHA512 计算文件的 SHA-512，用于 Ed25519ph 签名
+func fileSHA512(path string) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	h := sha512.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return nil, err
+	}
+	return h.Sum(nil), nil
+}
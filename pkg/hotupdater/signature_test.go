@@ -0,0 +1,26 @@
+package hotupdater
+
+import (
+	"crypto/ed25519"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestVerifyFileSignatureKeepsCause(t *testing.T) {
+	pub, _, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "update.pkg")
+	if err := os.WriteFile(path, []byte("package"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// 没有 .sig 文件
+	err = VerifyFileSignature(path, "", []ed25519.PublicKey{pub})
+	if !errors.Is(err, ErrSignature) || !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("错误应同时匹配 ErrSignature 和 os.ErrNotExist: %v", err)
+	}
+}
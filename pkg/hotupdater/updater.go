@@ -2,9 +2,11 @@ package hotupdater
 
 import (
 	"context"
-	"fmt"
+	"errors"
 	"os"
 	"time"
+
+	"github.com/562589540/hotupdater/pkg/version"
 )
 
 // 事件名称常量
@@ -29,6 +31,9 @@ type Updater interface {
 
 // New 创建平台特定的更新器
 func New(config Config, ctx context.Context) Updater {
+	if config.ProgressModel == nil {
+		config.ProgressModel = DefaultProgressModel()
+	}
 	// 由于使用了构建标签，编译器会自动选择正确的实现
 	return newPlatformUpdater(config, ctx)
 }
@@ -41,6 +46,10 @@ type FastUpdater struct {
 
 // NewFastUpdate 快速更新
 func NewFastUpdate(config Config, ctx context.Context) *FastUpdater {
+	// 下载器和平台更新器共用同一个进度模型，保证总体进度连续
+	if config.ProgressModel == nil {
+		config.ProgressModel = DefaultProgressModel()
+	}
 	updater := New(config, ctx)
 	return &FastUpdater{
 		config:  config,
@@ -50,36 +59,75 @@ func NewFastUpdate(config Config, ctx context.Context) *FastUpdater {
 }
 
 // Update 方法中添加下载阶段
-func (f *FastUpdater) Update(newAppPath string, WindowHide func(ctx context.Context)) error {
+// 更新结束时调用 Config.Hooks.OnComplete 和 Config.OnUpdate，成功时在重启之前调用
+func (f *FastUpdater) Update(newAppPath string, WindowHide func(ctx context.Context)) (err error) {
 	defer f.updater.Close()
+	f.config.ProgressModel.Reset()
+	completed := false
+	defer func() {
+		if !completed {
+			f.complete(newAppPath, err)
+		}
+	}()
+
+	// 检查版本，默认拒绝降级和重复安装
+	if err := f.checkVersion(); err != nil {
+		f.config.Logger.Logf("版本检查失败: %v", err)
+		return err
+	}
 
 	// 如果提供了下载实现，执行下载
+	if err := f.checkCancelled(PhaseDownload); err != nil {
+		return err
+	}
 	if f.config.DownloadImpl != nil {
+		if err := f.config.runHook(f.hookContext(newAppPath, HookBeforeDownload)); err != nil {
+			return err
+		}
 		downloader := NewDownloader(f.ctx, f.config, f.config.DownloadImpl)
 		if err := downloader.Execute(); err != nil {
-			return fmt.Errorf("下载失败: %v", err)
+			f.config.Logger.Logf("下载失败: %v", err)
+			return err
 		}
 	}
 
 	// 检查新版本是否存在
+	if err := f.checkCancelled(PhasePreCheck); err != nil {
+		return err
+	}
 	if _, err := os.Stat(newAppPath); err != nil {
 		f.config.Logger.Logf("新版本不存在: %v", err)
-		return fmt.Errorf("新版本不存在: %v", err)
+		return newUpdateError(ErrInstallFailed, PhasePreCheck, f.config.localizer().wrap(MsgErrPackageMissing, err))
+	}
+
+	// 校验更新包完整性，失败时不进行任何备份和替换
+	if err := f.verifyPackage(newAppPath); err != nil {
+		f.config.Logger.Logf("更新包校验失败: %v", err)
+		return newUpdateError(ErrIntegrity, PhaseVerify, err)
 	}
 
-	// 执行更新
+	// 执行更新，安装过程中取消时由平台更新器回滚
+	if err := f.checkCancelled(PhasePreCheck); err != nil {
+		return err
+	}
 	f.config.Logger.Log("开始执行更新操作...")
+	started := time.Now()
 	if err := f.updater.Update(newAppPath); err != nil {
+		err = newUpdateError(ErrInstallFailed, PhaseInstall, err)
 		// 更新失败
 		if f.config.EventEmitter != nil {
 			f.config.EventEmitter.EmitProgress(UpdateProgress{
 				Phase:      PhaseInstall,
 				Percentage: 0,
-				Message:    "更新失败",
+				MessageID:  MsgUpdateFailed,
+				Message:    f.config.localizer().T(MsgUpdateFailed),
 				Detail:     err.Error(),
 			})
 		}
 		f.config.Logger.Logf("更新操作失败: %v", err)
+		if errors.Is(err, ErrRolledBack) || errors.Is(err, ErrRollbackFailed) {
+			f.notify(f.config.Hooks.OnRollback, newAppPath, HookRollback, err)
+		}
 		return err
 	}
 
@@ -88,15 +136,27 @@ func (f *FastUpdater) Update(newAppPath string, WindowHide func(ctx context.Cont
 		f.config.EventEmitter.EmitProgress(UpdateProgress{
 			Phase:      PhaseComplete,
 			Percentage: 100,
-			Message:    PhaseMessages[PhaseComplete],
-			Detail:     "更新完成，准备重启...",
+			MessageID:  PhaseMessageID(PhaseComplete),
+			Message:    f.config.ProgressModel.Message(f.config.localizer(), PhaseComplete),
+			Detail:     f.config.localizer().T(MsgUpdateRestarting),
+			DetailID:   MsgUpdateRestarting,
 		})
 	}
 
-	// 更新成功，准备重启
+	// 记录等待新版本确认的更新，再按保留策略清理旧备份
+	backup := f.updateBackup(started)
+	f.describeBackup(backup)
+	f.markPendingHealth(backup)
+	f.pruneBackups(backup)
+
+	// 更新成功，准备重启；此后取消只会跳过重启，新版本在下次启动时生效
+	completed = true
+	f.complete(newAppPath, nil)
 	f.config.Logger.Log("更新成功，准备重启...")
 	// 延迟一下让用户看到提示
-	time.Sleep(1500 * time.Millisecond)
+	if err := f.wait(1500*time.Millisecond, PhaseComplete); err != nil {
+		return err
+	}
 
 	// 如果提供了隐藏窗口的函数，执行隐藏
 	if WindowHide != nil {
@@ -106,7 +166,9 @@ func (f *FastUpdater) Update(newAppPath string, WindowHide func(ctx context.Cont
 	}
 
 	// 延迟一下让用户看到提示
-	time.Sleep(500 * time.Millisecond)
+	if err := f.wait(500*time.Millisecond, PhaseComplete); err != nil {
+		return err
+	}
 
 	// 使用更新器的重启方法
 	if err := f.updater.Restart(); err != nil {
@@ -115,8 +177,148 @@ func (f *FastUpdater) Update(newAppPath string, WindowHide func(ctx context.Cont
 	}
 
 	f.config.Logger.Log("重启命令已执行，准备退出当前程序...")
-	time.Sleep(1 * time.Second)
+	// 新版本已经启动，此时取消只会提前结束等待，更新仍然成功，因此不返回取消错误
+	f.wait(1*time.Second, PhaseComplete)
 
 	f.config.Logger.Log("正在退出当前程序...")
 	return nil
 }
+
+// hookContext 构造钩子参数
+func (f *FastUpdater) hookContext(newAppPath string, point HookPoint) HookContext {
+	hc := f.config.hookContext(f.ctx, point)
+	hc.NewVersion = newAppPath
+	hc.Target = f.updater.GetCurrentExe()
+	return hc
+}
+
+// notify 调用通知类钩子
+func (f *FastUpdater) notify(fn func(HookContext), newAppPath string, point HookPoint, err error) {
+	if fn == nil {
+		return
+	}
+	hc := f.hookContext(newAppPath, point)
+	hc.Err = err
+	fn(hc)
+}
+
+// complete 通知更新结果，err 为 nil 表示成功
+func (f *FastUpdater) complete(newAppPath string, err error) {
+	f.notify(f.config.Hooks.OnComplete, newAppPath, HookComplete, err)
+	if f.config.OnUpdate != nil {
+		f.config.OnUpdate(err)
+	}
+}
+
+// checkCancelled ctx 已取消时返回 ErrCancelled
+func (f *FastUpdater) checkCancelled(phase UpdatePhase) error {
+	if err := f.ctx.Err(); err != nil {
+		f.config.Logger.Logf("更新已取消: %v", err)
+		return &UpdateError{Kind: ErrCancelled, Phase: phase, Err: f.config.localizer().wrap(MsgErrCancelled, err)}
+	}
+	return nil
+}
+
+// wait 等待 d，ctx 取消时提前返回 ErrCancelled
+func (f *FastUpdater) wait(d time.Duration, phase UpdatePhase) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-f.ctx.Done():
+		return f.checkCancelled(phase)
+	}
+}
+
+// checkVersion 确认 UpdateVersion 高于 CurrentVersion，任一版本为空时无法比较，记录日志后跳过
+// 拒绝时返回 Kind 为 ErrDowngrade 的 *UpdateError，版本号无法解析时 Kind 为 ErrInstallFailed。
+func (f *FastUpdater) checkVersion() error {
+	if f.config.AllowDowngrade {
+		return nil
+	}
+	if f.config.CurrentVersion == "" || f.config.UpdateVersion == "" {
+		f.config.Logger.Logf("警告: 未设置 CurrentVersion 或 UpdateVersion（当前 %q，更新 %q），跳过降级检查",
+			f.config.CurrentVersion, f.config.UpdateVersion)
+		return nil
+	}
+
+	l := f.config.localizer()
+	c, err := version.Compare(f.config.UpdateVersion, f.config.CurrentVersion)
+	if err != nil {
+		return newUpdateError(ErrInstallFailed, PhasePreCheck, l.wrap(MsgErrVersionCompare, err))
+	}
+	if c <= 0 {
+		return newUpdateError(ErrDowngrade, PhasePreCheck, l.errorOf(MsgErrDowngrade, ErrDowngrade, f.config.CurrentVersion, f.config.UpdateVersion))
+	}
+	return nil
+}
+
+// verifyPackage 校验更新包的 SHA-256、大小和签名
+// 下载实现自行校验（PackageVerifier）之后仍按 Config 校验摘要和签名；更新包为目录时无法整体签名，
+// 配置了 PublicKeys 时要求下载实现（如 FileDownloader）报告文件清单已通过签名校验。
+func (f *FastUpdater) verifyPackage(newAppPath string) error {
+	verifier, selfVerified := f.config.DownloadImpl.(PackageVerifier)
+	needSignature := len(f.config.PublicKeys) > 0
+	if !f.config.hasIntegrityCheck() && !needSignature && !selfVerified {
+		return nil
+	}
+
+	// 校验发生在备份之前，总体进度停留在下载结束的位置，避免进度条来回跳动
+	l := f.config.localizer()
+	emit := func(id MessageID) {
+		if f.config.EventEmitter != nil {
+			f.config.EventEmitter.EmitProgress(UpdateProgress{
+				Phase:      PhaseVerify,
+				Percentage: f.config.ProgressModel.CalculateProgress(PhaseDownload, 1, 1),
+				MessageID:  PhaseMessageID(PhaseVerify),
+				Message:    f.config.ProgressModel.Message(l, PhaseVerify),
+				Detail:     l.T(id),
+				DetailID:   id,
+			})
+		}
+	}
+
+	if selfVerified {
+		emit(MsgVerifyFile)
+		if err := verifier.VerifyPackage(newAppPath); err != nil {
+			emit(MsgVerifyFileFailed)
+			return err
+		}
+	}
+
+	if f.config.hasIntegrityCheck() {
+		emit(MsgVerifyPackage)
+		if err := VerifyPackage(newAppPath, f.config.PackageSHA256, f.config.PackageSize); err != nil {
+			emit(MsgVerifyPackageFailed)
+			return err
+		}
+	}
+
+	if needSignature {
+		emit(MsgVerifySignature)
+		if err := f.verifySignature(newAppPath); err != nil {
+			emit(MsgVerifySignatureFail)
+			return err
+		}
+	}
+
+	emit(MsgVerifyPassed)
+	f.config.Logger.Log("更新包校验通过")
+	return nil
+}
+
+// verifySignature 校验更新包签名，目录形式的更新包检查文件清单是否校验过签名
+func (f *FastUpdater) verifySignature(newAppPath string) error {
+	info, err := os.Stat(newAppPath)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return VerifyFileSignature(newAppPath, f.config.PackageSignature, f.config.PublicKeys)
+	}
+	if v, ok := f.config.DownloadImpl.(ManifestVerifier); ok && v.ManifestVerified() {
+		return nil
+	}
+	return f.config.localizer().errorOf(MsgErrManifestUnsigned, ErrSignature, newAppPath)
+}
@@ -0,0 +1,59 @@
+package hotupdater
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+// testLogger 把日志输出到测试日志
+type testLogger struct{ t *testing.T }
+
+func (l testLogger) Log(message string) { l.t.Log(message) }
+
+func (l testLogger) Logf(format string, args ...interface{}) { l.t.Log(fmt.Sprintf(format, args...)) }
+
+func TestCheckVersion(t *testing.T) {
+	cases := []struct {
+		current, update string
+		allow           bool
+		downgrade       bool
+	}{
+		{"1.0.0", "1.0.1", false, false},
+		{"v1.0.0", "1.1.0", false, false},
+		{"1.0.0-beta", "1.0.0", false, false},
+		{"1.0.1", "1.0.0", false, true},
+		{"1.0.0", "1.0.0", false, true},
+		{"1.0.0", "v1.0.0", false, true},
+		{"1.0.0", "1.0.0-rc.1", false, true},
+		{"1.0.1", "1.0.0", true, false},
+		{"1.0.0", "1.0.0", true, false},
+		{"", "1.0.0", false, false},
+		{"1.0.0", "", false, false},
+	}
+	for _, c := range cases {
+		f := &FastUpdater{config: Config{CurrentVersion: c.current, UpdateVersion: c.update, AllowDowngrade: c.allow, Logger: testLogger{t}}}
+		err := f.checkVersion()
+		if got := errors.Is(err, ErrDowngrade); got != c.downgrade {
+			t.Errorf("%q -> %q (AllowDowngrade=%v): 期望拒绝 %v，实际 %v", c.current, c.update, c.allow, c.downgrade, err)
+		}
+		if !c.downgrade && err != nil {
+			t.Errorf("%q -> %q: 不应返回错误: %v", c.current, c.update, err)
+		}
+		var ue *UpdateError
+		if c.downgrade && (!errors.As(err, &ue) || ue.Kind != ErrDowngrade || ue.Phase != PhasePreCheck) {
+			t.Errorf("%q -> %q: 应返回 precheck 阶段的 UpdateError，实际 %#v", c.current, c.update, err)
+		}
+	}
+
+	f := &FastUpdater{config: Config{CurrentVersion: "1.0.0", UpdateVersion: "garbage", Logger: testLogger{t}}}
+	err := f.checkVersion()
+	var ue *UpdateError
+	if !errors.As(err, &ue) || ue.Kind != ErrInstallFailed || ue.Phase != PhasePreCheck || errors.Is(err, ErrDowngrade) {
+		t.Fatalf("无法解析的版本号应返回比较失败: %v", err)
+	}
+	var le *LocalizedError
+	if !errors.As(err, &le) || le.ID != MsgErrVersionCompare {
+		t.Fatalf("错误应包含本地化信息: %v", err)
+	}
+}
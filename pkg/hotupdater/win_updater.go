@@ -7,6 +7,7 @@ import (
 	"context"
 	"fmt"
 	"os"
+	"os/exec"
 	"path/filepath"
 
 	lua "github.com/yuin/gopher-lua"
@@ -19,6 +20,7 @@ type WinUpdater struct {
 	luaState   *lua.LState
 	currentExe string
 	helper     *helper
+	restart    bool // 使用 Go 流水线在进程内完成更新后需要自行启动新版本
 }
 
 func newPlatformUpdater(config Config, ctx context.Context) Updater {
@@ -30,9 +32,9 @@ func newWinUpdater(config Config, ctx context.Context) *WinUpdater {
 	return &WinUpdater{
 		config:     config,
 		ctx:        ctx,
-		luaState:   lua.NewState(),
+		luaState:   NewLuaState(config.LuaSandbox),
 		currentExe: exe,
-		helper:     newHelper(config.Logger, config.EventEmitter),
+		helper:     newHelper(config),
 	}
 }
 
@@ -40,6 +42,14 @@ func (w *WinUpdater) Update(newVersion string) error {
 	w.sendLog("当前程序路径: %s", w.currentExe)
 	w.sendLog("新版本路径: %s", newVersion)
 
+	if w.config.Pipeline != nil {
+		if err := w.helper.runPipeline(w.ctx, w.config.Pipeline, w.config, w.currentExe, newVersion); err != nil {
+			return err
+		}
+		w.restart = true
+		return nil
+	}
+
 	// 构建更新参数
 	params := map[string]string{
 		"app_path":        w.currentExe,
@@ -50,11 +60,12 @@ func (w *WinUpdater) Update(newVersion string) error {
 		"script_path":     w.config.ScriptPath,
 		"current_version": w.config.CurrentVersion,
 		"update_version":  w.config.UpdateVersion,
+		"locale":          w.helper.l.Locale(),
 	}
 
 	// 执行更新脚本
-	if err := w.helper.executeLuaScript(w.luaState, w.config.ScriptPath, params); err != nil {
-		return fmt.Errorf("执行更新脚本失败: %v", err)
+	if err := w.helper.executeLuaScript(w.ctx, w.luaState, w.config.ScriptPath, params, w.config.LuaSandbox); err != nil {
+		return newUpdateError(ErrScriptFailed, w.helper.dispatcher.failedPhase(), w.helper.l.wrap(MsgErrScriptFailed, err))
 	}
 	return nil
 }
@@ -74,7 +85,12 @@ func (w *WinUpdater) GetConfig() Config {
 }
 
 func (w *WinUpdater) Restart() error {
-	// Windows 下不需要重启，批处理脚本会处理
+	if w.restart {
+		cmd := exec.Command(w.currentExe, os.Args[1:]...)
+		cmd.Dir = filepath.Dir(w.currentExe)
+		return cmd.Start()
+	}
+	// 脚本更新时不需要重启，批处理脚本或更新助手会处理
 	return nil
 }
 
@@ -0,0 +1,187 @@
+// Package version 提供语义化版本号的解析和比较
+//
+// 支持 "v" 前缀、预发布标签和构建元数据，例如 v1.0.5、1.2.0-beta.1、1.2.0+build.7。
+// 缺省的次版本号和修订号按 0 处理，例如 "1.2" 等同于 "1.2.0"；数字段允许前导零并按数值比较，"1.02" 等同于 "1.2"。
+package version
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Version 语义化版本号
+type Version struct {
+	Major      int
+	Minor      int
+	Patch      int
+	PreRelease []string // 预发布标签，按 "." 分段
+	Build      string   // 构建元数据，不参与比较
+	raw        string
+}
+
+// Parse 解析版本号
+func Parse(s string) (Version, error) {
+	raw := s
+	s = strings.TrimSpace(s)
+	s = strings.TrimPrefix(strings.TrimPrefix(s, "v"), "V")
+	if s == "" {
+		return Version{}, fmt.Errorf("版本号为空")
+	}
+
+	var v Version
+	v.raw = raw
+
+	if idx := strings.Index(s, "+"); idx != -1 {
+		v.Build = s[idx+1:]
+		s = s[:idx]
+	}
+	if idx := strings.Index(s, "-"); idx != -1 {
+		pre := s[idx+1:]
+		if pre == "" {
+			return Version{}, fmt.Errorf("无效的版本号: %s", raw)
+		}
+		v.PreRelease = strings.Split(pre, ".")
+		for _, id := range v.PreRelease {
+			if id == "" {
+				return Version{}, fmt.Errorf("无效的版本号: %s", raw)
+			}
+		}
+		s = s[:idx]
+	}
+
+	parts := strings.Split(s, ".")
+	if len(parts) > 3 {
+		return Version{}, fmt.Errorf("无效的版本号: %s", raw)
+	}
+	nums := []*int{&v.Major, &v.Minor, &v.Patch}
+	for i, p := range parts {
+		n, err := strconv.Atoi(p)
+		if err != nil || !isDigits(p) {
+			return Version{}, fmt.Errorf("无效的版本号: %s", raw)
+		}
+		*nums[i] = n
+	}
+	return v, nil
+}
+
+// MustParse 解析版本号，失败时 panic，用于常量初始化
+func MustParse(s string) Version {
+	v, err := Parse(s)
+	if err != nil {
+		panic(err)
+	}
+	return v
+}
+
+// String 返回规范化的版本号（不带 v 前缀）
+func (v Version) String() string {
+	s := fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Patch)
+	if len(v.PreRelease) > 0 {
+		s += "-" + strings.Join(v.PreRelease, ".")
+	}
+	if v.Build != "" {
+		s += "+" + v.Build
+	}
+	return s
+}
+
+// Original 返回解析前的原始字符串
+func (v Version) Original() string {
+	return v.raw
+}
+
+// IsPreRelease 是否为预发布版本
+func (v Version) IsPreRelease() bool {
+	return len(v.PreRelease) > 0
+}
+
+// Compare 比较两个版本，v < o 返回 -1，相等返回 0，v > o 返回 1
+// 按语义化版本规则，预发布版本低于对应的正式版本。
+func (v Version) Compare(o Version) int {
+	if c := compareInt(v.Major, o.Major); c != 0 {
+		return c
+	}
+	if c := compareInt(v.Minor, o.Minor); c != 0 {
+		return c
+	}
+	if c := compareInt(v.Patch, o.Patch); c != 0 {
+		return c
+	}
+	return comparePreRelease(v.PreRelease, o.PreRelease)
+}
+
+// LessThan 是否低于 o
+func (v Version) LessThan(o Version) bool {
+	return v.Compare(o) < 0
+}
+
+// Equal 是否与 o 相等（忽略构建元数据）
+func (v Version) Equal(o Version) bool {
+	return v.Compare(o) == 0
+}
+
+// Compare 解析并比较两个版本号字符串
+func Compare(a, b string) (int, error) {
+	va, err := Parse(a)
+	if err != nil {
+		return 0, err
+	}
+	vb, err := Parse(b)
+	if err != nil {
+		return 0, err
+	}
+	return va.Compare(vb), nil
+}
+
+// isDigits 是否只包含 0-9，Atoi 接受的正负号不允许出现在版本号中
+func isDigits(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return s != ""
+}
+
+func compareInt(a, b int) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// comparePreRelease 比较预发布标签：无标签 > 有标签，数字段按数值比较且低于字母段
+func comparePreRelease(a, b []string) int {
+	switch {
+	case len(a) == 0 && len(b) == 0:
+		return 0
+	case len(a) == 0:
+		return 1
+	case len(b) == 0:
+		return -1
+	}
+
+	for i := 0; i < len(a) && i < len(b); i++ {
+		na, errA := strconv.Atoi(a[i])
+		nb, errB := strconv.Atoi(b[i])
+		switch {
+		case errA == nil && errB == nil:
+			if c := compareInt(na, nb); c != 0 {
+				return c
+			}
+		case errA == nil:
+			return -1
+		case errB == nil:
+			return 1
+		default:
+			if c := strings.Compare(a[i], b[i]); c != 0 {
+				return c
+			}
+		}
+	}
+	return compareInt(len(a), len(b))
+}
@@ -0,0 +1,117 @@
+package version
+
+import "testing"
+
+func TestParse(t *testing.T) {
+	cases := []struct {
+		in   string
+		want string // 规范化结果，为空表示应解析失败
+	}{
+		{"1.2.3", "1.2.3"},
+		{"v1.2.3", "1.2.3"},
+		{"V1.2.3", "1.2.3"},
+		{" v1.2.3 ", "1.2.3"},
+		{"1.2", "1.2.0"},
+		{"1", "1.0.0"},
+		{"01.02.003", "1.2.3"},
+		{"1.0.0-beta.1", "1.0.0-beta.1"},
+		{"1.0.0+build.7", "1.0.0+build.7"},
+		{"1.0.0-rc.1+sha.abc", "1.0.0-rc.1+sha.abc"},
+		{"", ""},
+		{"v", ""},
+		{"abc", ""},
+		{"1.2.3.4", ""},
+		{"1..2", ""},
+		{"1.-2.3", ""},
+		{"1.+2.3", ""},
+		{"1.2.x", ""},
+		{"1.0.0-", ""},
+		{"1.0.0-alpha..1", ""},
+	}
+	for _, c := range cases {
+		v, err := Parse(c.in)
+		if c.want == "" {
+			if err == nil {
+				t.Errorf("Parse(%q) 应失败，得到 %s", c.in, v)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Parse(%q) 失败: %v", c.in, err)
+			continue
+		}
+		if v.String() != c.want {
+			t.Errorf("Parse(%q) = %s，期望 %s", c.in, v, c.want)
+		}
+		if v.Original() != c.in {
+			t.Errorf("Original() = %q，期望 %q", v.Original(), c.in)
+		}
+	}
+}
+
+func TestCompare(t *testing.T) {
+	cases := []struct {
+		a, b string
+		want int
+	}{
+		{"1.0.0", "1.0.0", 0},
+		{"v1.0.0", "1.0.0", 0},
+		{"1.0", "1.0.0", 0},
+		{"1", "1.0.0", 0},
+		{"1.02", "1.2.0", 0},
+		{"1.0.1", "1.0.0", 1},
+		{"1.1.0", "1.0.9", 1},
+		{"2.0.0", "1.99.99", 1},
+		{"1.10.0", "1.9.0", 1},
+		{"1.0.0+build.1", "1.0.0+build.2", 0},
+		{"1.0.0-beta+a", "1.0.0-beta+b", 0},
+
+		// 预发布版本的顺序
+		{"1.0.0-alpha", "1.0.0-alpha.1", -1},
+		{"1.0.0-alpha.1", "1.0.0-alpha.beta", -1},
+		{"1.0.0-alpha.beta", "1.0.0-beta", -1},
+		{"1.0.0-beta", "1.0.0-beta.2", -1},
+		{"1.0.0-beta.2", "1.0.0-beta.11", -1},
+		{"1.0.0-beta.11", "1.0.0-rc.1", -1},
+		{"1.0.0-rc.1", "1.0.0", -1},
+		{"1.0.0-1", "1.0.0-alpha", -1},
+		{"1.0.0", "1.0.0-alpha", 1},
+		{"1.0.1-alpha", "1.0.0", 1},
+	}
+	for _, c := range cases {
+		got, err := Compare(c.a, c.b)
+		if err != nil {
+			t.Errorf("Compare(%q, %q) 失败: %v", c.a, c.b, err)
+			continue
+		}
+		if got != c.want {
+			t.Errorf("Compare(%q, %q) = %d，期望 %d", c.a, c.b, got, c.want)
+		}
+		// 交换参数结果取反
+		if back, _ := Compare(c.b, c.a); back != -c.want {
+			t.Errorf("Compare(%q, %q) = %d，期望 %d", c.b, c.a, back, -c.want)
+		}
+	}
+
+	for _, bad := range [][2]string{{"", "1.0.0"}, {"1.0.0", "garbage"}} {
+		if _, err := Compare(bad[0], bad[1]); err == nil {
+			t.Errorf("Compare(%q, %q) 应失败", bad[0], bad[1])
+		}
+	}
+}
+
+func TestVersionHelpers(t *testing.T) {
+	v := MustParse("1.2.0-beta.1")
+	if !v.IsPreRelease() {
+		t.Error("1.2.0-beta.1 是预发布版本")
+	}
+	if !v.LessThan(MustParse("1.2.0")) || v.Equal(MustParse("1.2.0")) {
+		t.Error("预发布版本应低于正式版本")
+	}
+	defer func() {
+		if recover() == nil {
+			t.Error("MustParse 解析失败时应 panic")
+		}
+	}()
+	MustParse("not a version")
+}